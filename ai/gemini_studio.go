@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
 // GeminiStudioService はGoogle AI Studio API用のサービス
 type GeminiStudioService struct {
 	apiKey string
+	mu     sync.RWMutex
 	model  string
 	client *http.Client
 }
@@ -32,6 +34,22 @@ func NewGeminiStudioService(apiKey string, model string) *GeminiStudioService {
 	}
 }
 
+// SetModel は使用するモデルを変更します（設定の再読み込み時に使用）
+func (s *GeminiStudioService) SetModel(model string) {
+	if model == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.model = model
+}
+
+func (s *GeminiStudioService) currentModel() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.model
+}
+
 // GeminiRequest はAPI リクエストの構造体
 type GeminiRequest struct {
 	Contents []Content `json:"contents"`
@@ -81,7 +99,7 @@ type APIError struct {
 func (s *GeminiStudioService) AskGemini(ctx context.Context, question string, userID string) (string, error) {
 	// APIエンドポイント
 	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s",
-		s.model, s.apiKey)
+		s.currentModel(), s.apiKey)
 	
 	// リクエストボディの構築
 	prompt := fmt.Sprintf(`あなたは「Luna AI」です。Discord ボット「Luna」に統合された高性能AIアシスタントとして動作しています。
@@ -87,7 +87,7 @@ func (s *GeminiStudioService) OCRWithGemini(ctx context.Context, imageData []byt
 	
 	// APIエンドポイント
 	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s",
-		s.model, s.apiKey)
+		s.currentModel(), s.apiKey)
 	
 	// リクエストボディの構築
 	request := MultimodalRequest{
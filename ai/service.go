@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	aiplatform "cloud.google.com/go/aiplatform/apiv1"
@@ -16,6 +17,7 @@ import (
 )
 
 type Service struct {
+	mu             sync.RWMutex
 	config         *config.GoogleCloudConfig
 	predictionClient *aiplatform.PredictionClient
 	projectID      string
@@ -51,6 +53,27 @@ func NewService(cfg *config.GoogleCloudConfig) (*Service, error) {
 	}, nil
 }
 
+// SetModels は使用するGemini・Imagenモデルを変更します（設定の再読み込み時に使用）
+func (s *Service) SetModels(geminiModel, imagenModel string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg := *s.config
+	if geminiModel != "" {
+		cfg.GeminiModel = geminiModel
+	}
+	if imagenModel != "" {
+		cfg.ImagenModel = imagenModel
+	}
+	s.config = &cfg
+}
+
+func (s *Service) currentConfig() *config.GoogleCloudConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
 // AskGemini はGeminiモデルに質問を送信して回答を取得します
 func (s *Service) AskGemini(ctx context.Context, question string, userID string) (string, error) {
 	// タイムアウト設定
@@ -59,7 +82,7 @@ func (s *Service) AskGemini(ctx context.Context, question string, userID string)
 	
 	// モデルのエンドポイント
 	endpoint := fmt.Sprintf("projects/%s/locations/%s/publishers/google/models/%s",
-		s.projectID, s.location, s.config.GeminiModel)
+		s.projectID, s.location, s.currentConfig().GeminiModel)
 	
 	// Luna AI用の強化されたプロンプト
 	prompt := fmt.Sprintf(`あなたは「Luna AI」です。Discord ボット「Luna」に統合された高性能AIアシスタントとして動作しています。
@@ -165,7 +188,7 @@ func (s *Service) GenerateImage(ctx context.Context, prompt string, userID strin
 	
 	// モデルのエンドポイント
 	endpoint := fmt.Sprintf("projects/%s/locations/%s/publishers/google/models/%s",
-		s.projectID, s.location, s.config.ImagenModel)
+		s.projectID, s.location, s.currentConfig().ImagenModel)
 	
 	// Imagen 4用の強化されたプロンプト（日本語対応向上）
 	enhancedPrompt := fmt.Sprintf(`Generate a stunning, ultra-high-quality image based on this description: %s
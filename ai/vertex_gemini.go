@@ -3,6 +3,7 @@ package ai
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/vertexai/genai"
@@ -12,6 +13,7 @@ import (
 // VertexGeminiService は新しいVertex AI Gemini APIサービス
 type VertexGeminiService struct {
 	client    *genai.Client
+	mu        sync.RWMutex
 	model     *genai.GenerativeModel
 	projectID string
 	location  string
@@ -27,8 +29,18 @@ func NewVertexGeminiService(cfg *config.GoogleCloudConfig) (*VertexGeminiService
 		return nil, fmt.Errorf("failed to create genai client: %w", err)
 	}
 
+	return &VertexGeminiService{
+		client:    client,
+		model:     newGenerativeModel(client, cfg.GeminiModel),
+		projectID: cfg.ProjectID,
+		location:  cfg.Location,
+	}, nil
+}
+
+// newGenerativeModel は安全性設定と生成設定を適用したモデルを作成
+func newGenerativeModel(client *genai.Client, name string) *genai.GenerativeModel {
 	// モデルの初期化
-	model := client.GenerativeModel(cfg.GeminiModel)
+	model := client.GenerativeModel(name)
 
 	// 安全性設定
 	model.SafetySettings = []*genai.SafetySetting{
@@ -58,12 +70,25 @@ func NewVertexGeminiService(cfg *config.GoogleCloudConfig) (*VertexGeminiService
 		MaxOutputTokens: intPtr(2048),
 	}
 
-	return &VertexGeminiService{
-		client:    client,
-		model:     model,
-		projectID: cfg.ProjectID,
-		location:  cfg.Location,
-	}, nil
+	return model
+}
+
+// SetModel は使用するモデルを変更します（設定の再読み込み時に使用）
+func (s *VertexGeminiService) SetModel(name string) {
+	if name == "" {
+		return
+	}
+	model := newGenerativeModel(s.client, name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.model = model
+}
+
+func (s *VertexGeminiService) generativeModel() *genai.GenerativeModel {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.model
 }
 
 // AskGemini はGeminiに質問して回答を得る
@@ -88,7 +113,7 @@ func (s *VertexGeminiService) AskGemini(ctx context.Context, question string, us
 ユーザーの質問: %s`, userID, question)
 
 	// 回答を生成
-	resp, err := s.model.GenerateContent(ctx, genai.Text(prompt))
+	resp, err := s.generativeModel().GenerateContent(ctx, genai.Text(prompt))
 	if err != nil {
 		return "", fmt.Errorf("Gemini APIの呼び出しに失敗しました: %w", err)
 	}
@@ -142,7 +167,7 @@ func (s *VertexGeminiService) AskGeminiWithImage(ctx context.Context, question s
 質問: %s`, question)
 
 	// 画像とプロンプトで回答を生成
-	resp, err := s.model.GenerateContent(ctx,
+	resp, err := s.generativeModel().GenerateContent(ctx,
 		genai.Text(prompt),
 		genai.ImageData(mimeType, imageData),
 	)
@@ -239,7 +264,7 @@ func (s *VertexGeminiService) OCRWithGemini(ctx context.Context, imageData []byt
 ユーザーID: %s`, prompt, userID)
 
 	// 画像とプロンプトで回答を生成
-	resp, err := s.model.GenerateContent(ctx,
+	resp, err := s.generativeModel().GenerateContent(ctx,
 		genai.Text(fullPrompt),
 		genai.ImageData(mimeType, imageData),
 	)
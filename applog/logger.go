@@ -0,0 +1,76 @@
+package applog
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/Sumire-Labs/Luna/config"
+)
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// New は LoggingConfig に従って構造化ロガーを作成します。
+// debug が true の場合は level に関わらずデバッグログと呼び出し元を出力します。
+// 戻り値の io.Closer はログファイルを閉じるために使用します。
+func New(cfg config.LoggingConfig, debug bool) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+	if debug {
+		level = slog.LevelDebug
+	}
+
+	var out io.Writer
+	var closer io.Closer = nopCloser{}
+	switch strings.ToLower(cfg.Output) {
+	case "", "console":
+		out = os.Stdout
+	case "file", "both":
+		file, err := OpenRotatingFile(cfg.File, cfg.MaxSizeMB, cfg.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, closer = file, file
+		if strings.ToLower(cfg.Output) == "both" {
+			out = io.MultiWriter(os.Stdout, file)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown logging.output: %s (console, file, both)", cfg.Output)
+	}
+
+	opts := &slog.HandlerOptions{Level: level, AddSource: debug}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(out, opts)
+	case "json":
+		handler = slog.NewJSONHandler(out, opts)
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("unknown logging.format: %s (text, json)", cfg.Format)
+	}
+
+	return slog.New(handler), closer, nil
+}
+
+// ParseLevel は debug, info, warn, error のいずれかをログレベルに変換します
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown logging.level: %s (debug, info, warn, error)", level)
+}
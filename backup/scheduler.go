@@ -0,0 +1,125 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/lifecycle"
+)
+
+// ErrRunning は別のバックアップを作成中の場合のエラーです
+var ErrRunning = errors.New("backup is already running")
+
+// Scheduler は SQLite データベースを一定間隔でバックアップし、検証と古いバックアップの削除を行います
+type Scheduler struct {
+	cfg       config.BackupConfig
+	db        *database.Service
+	lifecycle *lifecycle.Manager
+	log       *slog.Logger
+
+	// mu はバックアップの同時実行を防ぐ（定期実行と /backup now）
+	mu sync.Mutex
+	// done は定期実行の終了時に閉じられる（Start していない場合は nil）
+	done chan struct{}
+}
+
+func NewScheduler(cfg config.BackupConfig, db *database.Service, lc *lifecycle.Manager, logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		cfg:       cfg,
+		db:        db,
+		lifecycle: lc,
+		log:       logger,
+	}
+}
+
+// Dir はバックアップの保存先を返します
+func (s *Scheduler) Dir() string {
+	return s.cfg.Dir
+}
+
+// Start は定期バックアップを開始します。前回のバックアップから間隔が空いている場合はすぐに作成します。
+func (s *Scheduler) Start() {
+	s.done = make(chan struct{})
+	go s.run(s.done)
+}
+
+func (s *Scheduler) run(done chan struct{}) {
+	defer close(done)
+
+	interval := time.Duration(s.cfg.IntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	wait := time.Duration(0)
+	if backups, err := database.ListBackups(s.cfg.Dir); err == nil && len(backups) > 0 {
+		wait = time.Until(backups[0].CreatedAt.Add(interval))
+	}
+
+	for {
+		if wait > 0 && !s.lifecycle.Sleep(wait) {
+			return
+		}
+		if _, err := s.Run(); err != nil && !errors.Is(err, ErrRunning) {
+			s.log.Error("Scheduled backup failed", "error", err)
+		}
+		wait = interval
+	}
+}
+
+// Run はバックアップを作成して検証し、保持数を超えた古いバックアップを削除します。
+// 検証に失敗したバックアップは削除します。
+func (s *Scheduler) Run() (*database.BackupFile, error) {
+	if !s.mu.TryLock() {
+		return nil, ErrRunning
+	}
+	defer s.mu.Unlock()
+
+	start := time.Now()
+	path := database.DefaultBackupPath(s.cfg.Dir, start)
+	if err := s.db.Backup(path); err != nil {
+		return nil, err
+	}
+	if err := database.VerifyBackup(path); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	s.log.Info("Database backed up", "path", path, "size", info.Size(), "latency", time.Since(start))
+
+	removed, err := database.PruneBackups(s.cfg.Dir, s.cfg.KeepDaily, s.cfg.KeepWeekly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune backups: %w", err)
+	}
+	for _, path := range removed {
+		s.log.Info("Old backup removed", "path", path)
+	}
+
+	return &database.BackupFile{Name: info.Name(), Path: path, Size: info.Size(), CreatedAt: start}, nil
+}
+
+// Stop は作成中のバックアップの完了を待ちます（データベースを閉じる前に呼び出す）
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.done != nil {
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	// /backup now で作成中のものも待つ
+	s.mu.Lock()
+	s.mu.Unlock()
+	return nil
+}
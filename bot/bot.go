@@ -2,64 +2,122 @@ package bot
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/Sumire-Labs/Luna/config"
 	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/shard"
+	"github.com/Sumire-Labs/Luna/worker"
 )
 
 type Bot struct {
 	session  *discordgo.Session
+	shards   *shard.Manager
+	mu       sync.RWMutex
 	config   *config.Config
 	db       *database.Service
+	writes   *database.WriteBuffer
+	pool     *worker.Pool
+	log      *slog.Logger
 	startTime time.Time
 }
 
-func New(session *discordgo.Session, cfg *config.Config, db *database.Service) *Bot {
+func New(shards *shard.Manager, cfg *config.Config, db *database.Service, writes *database.WriteBuffer, pool *worker.Pool, logger *slog.Logger) *Bot {
 	return &Bot{
-		session:  session,
+		session:  shards.Primary(),
+		shards:   shards,
 		config:   cfg,
 		db:       db,
+		writes:   writes,
+		pool:     pool,
+		log:      logger,
 		startTime: time.Now(),
 	}
 }
 
 func (b *Bot) Start() error {
-	b.session.AddHandler(b.onReady)
-	b.session.AddHandler(b.onGuildCreate)
-	b.session.AddHandler(b.onMessageCreate)
+	b.RegisterHandlers()
 
-	if err := b.session.Open(); err != nil {
+	if err := b.shards.Open(); err != nil {
 		return fmt.Errorf("failed to open Discord session: %w", err)
 	}
 
-	log.Println("Bot is now running. Press CTRL+C to exit.")
+	b.log.Info("Bot is now running. Press CTRL+C to exit.")
 	return nil
 }
 
+// RegisterHandlers はボット本体のイベントハンドラーを登録します
+func (b *Bot) RegisterHandlers() {
+	b.shards.AddHandler(worker.Handler(b.pool, "bot.ready", b.onReady))
+	b.shards.AddHandler(worker.Handler(b.pool, "bot.guild_create", b.onGuildCreate))
+	b.shards.AddHandler(worker.Handler(b.pool, "bot.message_create", b.onMessageCreate))
+}
+
+// Intents はギルド登録とメッセージ内の括弧カウントに必要なゲートウェイインテントを返します
+func (b *Bot) Intents() discordgo.Intent {
+	return discordgo.IntentGuilds | discordgo.IntentGuildMessages | discordgo.IntentMessageContent
+}
+
 func (b *Bot) Stop() error {
-	return b.session.Close()
+	return b.shards.Close()
 }
 
 func (b *Bot) onReady(s *discordgo.Session, event *discordgo.Ready) {
-	log.Printf("Logged in as: %v#%v", event.User.Username, event.User.Discriminator)
+	b.log.Info("Shard logged in", "shard", s.ShardID+1, "shards", b.shards.ShardCount(), "user", event.User.Username+"#"+event.User.Discriminator, "guilds", len(event.Guilds))
 	
-	status := b.config.Bot.StatusMessage
+	b.updateStatus(s, len(event.Guilds))
+}
+
+// ApplyConfig は再読み込みされた設定を反映し、ステータスが変わった場合は全シャードに送信します
+func (b *Bot) ApplyConfig(cfg *config.Config) {
+	b.mu.Lock()
+	old := b.config
+	b.config = cfg
+	b.mu.Unlock()
+
+	if old.Bot.StatusMessage == cfg.Bot.StatusMessage && old.Bot.ActivityType == cfg.Bot.ActivityType {
+		return
+	}
+
+	for _, s := range b.shards.Sessions() {
+		if s.DataReady {
+			b.updateStatus(s, 0)
+		}
+	}
+	b.log.Info("Bot status updated from reloaded config", "status", cfg.Bot.StatusMessage, "activity_type", cfg.Bot.ActivityType)
+}
+
+// updateStatus はシャードのステータスを設定します。readyGuilds は Ready に含まれていたギルド数です。
+func (b *Bot) updateStatus(s *discordgo.Session, readyGuilds int) {
+	cfg := b.GetConfig()
+
+	status := cfg.Bot.StatusMessage
 	if status == "" {
-		status = fmt.Sprintf("Luna Bot | %d servers", len(event.Guilds))
+		// 各シャードの Ready には担当分のギルドしか含まれないため、全シャードの合計を表示する
+		guildCount := b.shards.GuildCount()
+		if guildCount < readyGuilds {
+			guildCount = readyGuilds
+		}
+		status = fmt.Sprintf("Luna Bot | %d servers", guildCount)
+		if b.shards.ShardCount() > 1 {
+			status += fmt.Sprintf(" | Shard %d", s.ShardID+1)
+		}
 	}
 
-	s.UpdateStatusComplex(discordgo.UpdateStatusData{
+	if err := s.UpdateStatusComplex(discordgo.UpdateStatusData{
 		Activities: []*discordgo.Activity{
 			{
 				Name: status,
-				Type: discordgo.ActivityType(b.config.Bot.ActivityType),
+				Type: discordgo.ActivityType(cfg.Bot.ActivityType),
 			},
 		},
 		Status: "online",
-	})
+	}); err != nil {
+		b.log.Warn("Failed to update status", "shard", s.ShardID+1, "error", err)
+	}
 }
 
 func (b *Bot) onGuildCreate(s *discordgo.Session, event *discordgo.GuildCreate) {
@@ -67,9 +125,9 @@ func (b *Bot) onGuildCreate(s *discordgo.Session, event *discordgo.GuildCreate)
 		return
 	}
 
-	err := b.db.UpsertGuild(event.Guild.ID, event.Guild.Name, b.config.Bot.Prefix)
+	err := b.db.UpsertGuild(event.Guild.ID, event.Guild.Name, b.GetConfig().Bot.Prefix)
 	if err != nil {
-		log.Printf("Failed to upsert guild %s: %v", event.Guild.ID, err)
+		b.log.Error("Failed to upsert guild", "guild_id", event.Guild.ID, "error", err)
 	}
 }
 
@@ -78,16 +136,14 @@ func (b *Bot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate)
 		return
 	}
 
-	err := b.db.UpsertUser(
+	// メッセージごとの書き込みはまとめて定期的に反映する
+	b.writes.UpsertUser(
 		m.Author.ID,
 		m.Author.Username,
 		m.Author.Discriminator,
 		m.Author.Avatar,
 		m.Author.Bot,
 	)
-	if err != nil {
-		log.Printf("Failed to upsert user %s: %v", m.Author.ID, err)
-	}
 	
 	// Count bracket pairs in message
 	if m.GuildID != "" {
@@ -96,10 +152,7 @@ func (b *Bot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate)
 		
 		// Update database if any bracket pairs found
 		if totalPairs > 0 {
-			err := b.db.UpdateBracketUsage(m.GuildID, m.Author.ID, halfWidthPairs, fullWidthPairs)
-			if err != nil {
-				log.Printf("Failed to update bracket usage: %v", err)
-			}
+			b.writes.UpdateBracketUsage(m.GuildID, m.Author.ID, halfWidthPairs, fullWidthPairs)
 		}
 	}
 }
@@ -140,7 +193,13 @@ func (b *Bot) GetSession() *discordgo.Session {
 	return b.session
 }
 
+func (b *Bot) GetShards() *shard.Manager {
+	return b.shards
+}
+
 func (b *Bot) GetConfig() *config.Config {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 	return b.config
 }
 
@@ -8,6 +8,9 @@ import (
 	"github.com/bwmarrin/discordgo"
 	"github.com/Sumire-Labs/Luna/database"
 	"github.com/Sumire-Labs/Luna/embed"
+	"github.com/Sumire-Labs/Luna/lifecycle"
+	"github.com/Sumire-Labs/Luna/shard"
+	"github.com/Sumire-Labs/Luna/worker"
 )
 
 const (
@@ -16,21 +19,32 @@ const (
 )
 
 type Handler struct {
-	session *discordgo.Session
-	db      *database.Service
+	session   *discordgo.Session
+	shards    *shard.Manager
+	db        database.GuildSettingsRepository
+	pool      *worker.Pool
+	lifecycle *lifecycle.Manager
 }
 
-func NewHandler(session *discordgo.Session, db *database.Service) *Handler {
+func NewHandler(shards *shard.Manager, db database.GuildSettingsRepository, pool *worker.Pool, lc *lifecycle.Manager) *Handler {
 	return &Handler{
-		session: session,
-		db:      db,
+		session:   shards.Primary(),
+		shards:    shards,
+		db:        db,
+		pool:      pool,
+		lifecycle: lc,
 	}
 }
 
 // RegisterHandlers はbump関連のイベントハンドラーを登録します
 func (h *Handler) RegisterHandlers() {
-	h.session.AddHandler(h.onMessageCreate)
-	h.session.AddHandler(h.onInteractionCreate)
+	h.shards.AddHandler(worker.Handler(h.pool, "bump.message_create", h.onMessageCreate))
+	h.shards.AddHandler(worker.Handler(h.pool, "bump.interaction_create", h.onInteractionCreate))
+}
+
+// Intents はDISBOARDのメッセージ（埋め込み）を読むために必要なゲートウェイインテントを返します
+func (h *Handler) Intents() discordgo.Intent {
+	return discordgo.IntentGuilds | discordgo.IntentGuildMessages | discordgo.IntentMessageContent
 }
 
 // onMessageCreate はDISBOARDのbump成功メッセージを検知します
@@ -93,7 +107,10 @@ func (h *Handler) handleBumpSuccess(s *discordgo.Session, guildID, channelID str
 
 // scheduleBumpReminder は指定時間後にBumpリマインダーを送信します
 func (h *Handler) scheduleBumpReminder(guildID string, duration time.Duration) {
-	time.Sleep(duration)
+	// シャットダウン時は中断（次回起動時に CheckPendingReminders で再スケジュールされる）
+	if !h.lifecycle.Sleep(duration) {
+		return
+	}
 	
 	// 設定を再取得
 	settings, err := h.db.GetGuildSettings(guildID)
@@ -259,7 +276,7 @@ func (h *Handler) handleBumpSettingsSubmit(s *discordgo.Session, i *discordgo.In
 	}
 	
 	// 設定を保存
-	if err := h.db.UpsertGuildSettings(settings); err != nil {
+	if err := h.db.SaveGuildSettings(settings, worker.UserID(i), "bump"); err != nil {
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
@@ -0,0 +1,233 @@
+package cleanup
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/embed"
+	"github.com/Sumire-Labs/Luna/shard"
+	"github.com/Sumire-Labs/Luna/worker"
+)
+
+// dependency は設定が参照するチャンネル・ロール1つ分です。
+// 参照先が削除されると設定を空にし、disable があれば機能を無効にします。
+type dependency struct {
+	label   string // 通知に表示する設定名
+	feature string // 無効にする機能（featureNames のキー）
+	field   func(s *database.GuildSettings) *string
+	disable func(s *database.GuildSettings) bool // 機能を無効にした場合は true（既に無効なら false）
+}
+
+// channelDependencies は設定が参照するチャンネル（カテゴリを含む）です
+var channelDependencies = []dependency{
+	{
+		label: "ログチャンネル", feature: "logging",
+		field:   func(s *database.GuildSettings) *string { return &s.LogChannelID },
+		disable: func(s *database.GuildSettings) bool { return disable(&s.LoggingEnabled) },
+	},
+	{
+		label: "チケットカテゴリ", feature: "tickets",
+		field:   func(s *database.GuildSettings) *string { return &s.TicketCategoryID },
+		disable: func(s *database.GuildSettings) bool { return disable(&s.TicketEnabled) },
+	},
+	{
+		label: "チケットのログチャンネル", feature: "tickets",
+		field: func(s *database.GuildSettings) *string { return &s.TicketLogChannelID },
+	},
+	{
+		label: "チケットの記録チャンネル", feature: "tickets",
+		field: func(s *database.GuildSettings) *string { return &s.TicketTranscriptChannelID },
+	},
+	{
+		label: "Bump通知チャンネル", feature: "bump",
+		field:   func(s *database.GuildSettings) *string { return &s.BumpChannelID },
+		disable: func(s *database.GuildSettings) bool { return disable(&s.BumpEnabled) },
+	},
+	{
+		label: "モデレーションのログチャンネル", feature: "moderation",
+		field: func(s *database.GuildSettings) *string { return &s.ModerationLogChannelID },
+	},
+	{
+		label: "ウェルカムチャンネル", feature: "welcome",
+		field:   func(s *database.GuildSettings) *string { return &s.WelcomeChannelID },
+		disable: func(s *database.GuildSettings) bool { return disable(&s.WelcomeEnabled) },
+	},
+}
+
+// roleDependencies は設定が参照するロールです
+var roleDependencies = []dependency{
+	{
+		label: "チケットのサポートロール", feature: "tickets",
+		field:   func(s *database.GuildSettings) *string { return &s.TicketSupportRoleID },
+		disable: func(s *database.GuildSettings) bool { return disable(&s.TicketEnabled) },
+	},
+	{
+		label: "チケットの管理者ロール", feature: "tickets",
+		field: func(s *database.GuildSettings) *string { return &s.TicketAdminRoleID },
+	},
+	{
+		label: "Bump通知のメンションロール", feature: "bump",
+		field: func(s *database.GuildSettings) *string { return &s.BumpRoleID },
+	},
+	{
+		label: "ウェルカムロール", feature: "welcome",
+		field: func(s *database.GuildSettings) *string { return &s.WelcomeRoleID },
+	},
+}
+
+func disable(enabled *bool) bool {
+	if !*enabled {
+		return false
+	}
+	*enabled = false
+	return true
+}
+
+var featureNames = map[string]string{
+	"logging":    "ログ機能",
+	"tickets":    "チケット機能",
+	"bump":       "Bump通知",
+	"moderation": "モデレーション",
+	"welcome":    "ウェルカムメッセージ",
+}
+
+// Handler はボットがギルドから削除されたときのデータ削除の予約と、
+// 設定で使用しているチャンネル・ロールが削除されたときの設定の無効化を行います
+type Handler struct {
+	shards *shard.Manager
+	db     database.GuildSettingsRepository
+	pool   *worker.Pool
+	log    *slog.Logger
+}
+
+func NewHandler(shards *shard.Manager, db database.GuildSettingsRepository, pool *worker.Pool, logger *slog.Logger) *Handler {
+	return &Handler{
+		shards: shards,
+		db:     db,
+		pool:   pool,
+		log:    logger,
+	}
+}
+
+// RegisterHandlers はギルド・チャンネル・ロールの削除のイベントハンドラーを登録します
+func (h *Handler) RegisterHandlers() {
+	h.shards.AddHandler(worker.Handler(h.pool, "cleanup.guild_create", h.onGuildCreate))
+	h.shards.AddHandler(worker.Handler(h.pool, "cleanup.guild_delete", h.onGuildDelete))
+	h.shards.AddHandler(worker.Handler(h.pool, "cleanup.channel_delete", h.onChannelDelete))
+	h.shards.AddHandler(worker.Handler(h.pool, "cleanup.guild_role_delete", h.onGuildRoleDelete))
+}
+
+// Intents はギルド・チャンネル・ロールのイベントに必要なゲートウェイインテントを返します
+func (h *Handler) Intents() discordgo.Intent {
+	return discordgo.IntentGuilds
+}
+
+// onGuildCreate は再び追加されたギルドのデータ削除の予約を取り消します
+func (h *Handler) onGuildCreate(s *discordgo.Session, event *discordgo.GuildCreate) {
+	if event.Guild.Unavailable {
+		return
+	}
+	if err := h.db.CancelGuildRemoval(event.Guild.ID); err != nil {
+		h.log.Error("Failed to cancel guild removal", "guild_id", event.Guild.ID, "error", err)
+	}
+}
+
+// onGuildDelete はボットがギルドから削除された（キック・ギルドの削除）ときにデータの削除を予約します。
+// Unavailable の場合は Discord 側の障害のため何もしません。
+func (h *Handler) onGuildDelete(s *discordgo.Session, event *discordgo.GuildDelete) {
+	if event.Guild.Unavailable {
+		return
+	}
+	if err := h.db.MarkGuildRemoved(event.Guild.ID); err != nil {
+		h.log.Error("Failed to mark guild as removed", "guild_id", event.Guild.ID, "error", err)
+		return
+	}
+	h.log.Info("Bot removed from guild, data purge scheduled", "guild_id", event.Guild.ID)
+}
+
+func (h *Handler) onChannelDelete(s *discordgo.Session, c *discordgo.ChannelDelete) {
+	if c.GuildID == "" {
+		return
+	}
+	h.release(s, c.GuildID, c.ID, "チャンネル", "#"+c.Name, channelDependencies)
+}
+
+func (h *Handler) onGuildRoleDelete(s *discordgo.Session, r *discordgo.GuildRoleDelete) {
+	// 削除されたロールはハンドラーの実行前に State から取り除かれるため、名前は表示できない
+	h.release(s, r.GuildID, r.RoleID, "ロール", "ロール", roleDependencies)
+}
+
+// release は削除されたチャンネル・ロールを参照する設定を空にし、必要に応じて機能を無効にして管理者に通知します
+func (h *Handler) release(s *discordgo.Session, guildID, id, kind, name string, dependencies []dependency) {
+	settings, err := h.db.GetGuildSettings(guildID)
+	if err != nil {
+		h.log.Error("Failed to load guild settings", "guild_id", guildID, "error", err)
+		return
+	}
+
+	actorID := ""
+	if s.State != nil && s.State.User != nil {
+		actorID = s.State.User.ID
+	}
+
+	var lines []string
+	for _, dep := range dependencies {
+		field := dep.field(settings)
+		if *field != id {
+			continue
+		}
+		*field = ""
+		line := fmt.Sprintf("• %s の設定を解除しました", dep.label)
+		if dep.disable != nil && dep.disable(settings) {
+			line = fmt.Sprintf("• %s が削除されたため、**%s**を無効にしました", dep.label, featureNames[dep.feature])
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	// /config history ではボットによる "cleanup" の変更として表示され、元に戻すこともできる
+	if err := h.db.SaveGuildSettings(settings, actorID, "cleanup"); err != nil {
+		h.log.Error("Failed to release deleted resource from settings", "guild_id", guildID, "id", id, "error", err)
+		return
+	}
+	h.log.Info("Settings released deleted resource", "guild_id", guildID, "kind", kind, "id", id, "changes", len(lines))
+
+	h.notify(s, guildID, settings, embed.Warning(
+		fmt.Sprintf("設定で使用していた%sが削除されました", kind),
+		fmt.Sprintf("%s（`%s`）が削除されたため、次の設定を変更しました。\n\n%s\n\n`/config` から設定し直してください。",
+			name, id, strings.Join(lines, "\n")),
+	))
+}
+
+// notify はギルドの管理者に通知します。ログチャンネル → システムチャンネル → オーナーへのDM の順に送信を試みます。
+func (h *Handler) notify(s *discordgo.Session, guildID string, settings *database.GuildSettings, message *discordgo.MessageEmbed) {
+	var targets []string
+	if settings.LoggingEnabled && settings.LogChannelID != "" {
+		targets = append(targets, settings.LogChannelID)
+	}
+
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		guild, err = s.Guild(guildID)
+	}
+	if err == nil {
+		if guild.SystemChannelID != "" {
+			targets = append(targets, guild.SystemChannelID)
+		}
+		if dm, err := s.UserChannelCreate(guild.OwnerID); err == nil {
+			targets = append(targets, dm.ID)
+		}
+	}
+
+	for _, channelID := range targets {
+		if _, err := s.ChannelMessageSendEmbed(channelID, message); err == nil {
+			return
+		}
+	}
+	h.log.Warn("Failed to notify guild admins", "guild_id", guildID)
+}
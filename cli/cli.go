@@ -0,0 +1,69 @@
+// Package cli はゲートウェイに接続せずに実行する保守用のサブコマンド（luna migrate など）です。
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/replay"
+)
+
+// Usage はサブコマンドの一覧です
+const Usage = `Usage: luna [command]
+
+Commands:
+  (none)                      ボットを起動します
+  migrate status              未適用のマイグレーションを表示します
+  migrate up                  マイグレーションを実行します
+  commands list               登録済みのスラッシュコマンドを表示します
+  commands sync               スラッシュコマンドをDiscordに登録します
+  commands purge              スラッシュコマンドをDiscordから削除します
+  config check                設定ファイルを検証します
+  db backup [-o path]         データベースのバックアップを作成します
+  db restore <backup.db>      次回起動時にバックアップから復元します
+  db copy <luna.db>           SQLite のデータを設定中のデータベースにコピーします
+  guild export <id> [-o path] ギルドのデータをJSONで出力します
+  replay [flags]              記録したイベントを再生します
+`
+
+// Run はサブコマンドを実行します。config check は設定の読み込み前に RunConfig で実行します。
+func Run(cfg *config.Config, logger *slog.Logger, args []string) error {
+
+	switch args[0] {
+	case "migrate":
+		return runMigrate(cfg, logger, args[1:])
+	case "commands":
+		return runCommands(cfg, logger, args[1:])
+	case "db":
+		return runDB(cfg, logger, args[1:])
+	case "guild":
+		return runGuild(cfg, logger, args[1:])
+	case "replay":
+		return replay.Run(cfg, logger, args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(Usage)
+		return nil
+	}
+	fmt.Fprint(os.Stderr, Usage)
+	return fmt.Errorf("unknown command: %s", args[0])
+}
+
+// openDatabase はゲートウェイや他のサービスを起動せずにデータベースだけを開きます
+func openDatabase(cfg *config.Config, logger *slog.Logger) (*database.Service, error) {
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+	return database.NewService(db, logger), nil
+}
+
+// subcommand は "migrate status" の "status" のような2語目を返します
+func subcommand(args []string) (string, []string) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	return args[0], args[1:]
+}
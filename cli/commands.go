@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/di"
+)
+
+// runCommands は luna commands list|sync|purge を実行します。
+// コマンドは config.toml で有効なモジュールの分だけ登録されます。
+func runCommands(cfg *config.Config, logger *slog.Logger, args []string) error {
+	action, _ := subcommand(args)
+	switch action {
+	case "list", "sync", "purge":
+	default:
+		return fmt.Errorf("usage: luna commands list|sync|purge")
+	}
+
+	container, err := di.NewMaintenanceContainer(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer container.Cleanup()
+
+	registry := container.CommandRegistry
+
+	switch action {
+	case "sync":
+		registered, err := registry.SyncSlashCommands()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Synced %d global commands.\n", len(registered))
+
+	case "purge":
+		if err := registry.UnregisterSlashCommands(); err != nil {
+			return err
+		}
+		fmt.Println("Removed all global commands.")
+
+	case "list":
+		appID, err := registry.ApplicationID()
+		if err != nil {
+			return err
+		}
+		remote, err := container.Session.ApplicationCommands(appID, "")
+		if err != nil {
+			return fmt.Errorf("failed to get application commands: %w", err)
+		}
+
+		registered := make(map[string]bool, len(remote))
+		for _, cmd := range remote {
+			registered[cmd.Name] = true
+		}
+		local := make(map[string]bool)
+		for _, cmd := range registry.ApplicationCommands() {
+			local[cmd.Name] = true
+			mark := "  "
+			if !registered[cmd.Name] {
+				mark = "+ " // 未登録（sync で追加される）
+			}
+			fmt.Printf("%s/%-16s %s\n", mark, cmd.Name, cmd.Description)
+		}
+		for _, cmd := range remote {
+			if !local[cmd.Name] {
+				fmt.Printf("- /%-16s %s\n", cmd.Name, cmd.Description) // sync で削除される
+			}
+		}
+		fmt.Println("\n+ = not registered yet, - = registered but no longer provided (run `luna commands sync`)")
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/di"
+	"github.com/Sumire-Labs/Luna/interactions"
+)
+
+// RunConfig は luna config check を実行します。
+// 設定の読み込み自体を検証するため、main で config.Load より前に呼び出します。
+func RunConfig(args []string) error {
+	action, _ := subcommand(args)
+	if action != "check" {
+		return fmt.Errorf("usage: luna config check")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("config is invalid: %w", err)
+	}
+	fmt.Println("✓ Config is valid")
+	fmt.Printf("  Database: %s\n", databaseLabel(cfg.Database))
+	fmt.Printf("  Modules:  %s\n", strings.Join(di.EnabledModules(cfg), ", "))
+
+	warnings := configWarnings(cfg)
+	for _, w := range warnings {
+		fmt.Printf("⚠ %s\n", w)
+	}
+	if len(warnings) == 0 {
+		fmt.Println("No warnings.")
+	}
+	return nil
+}
+
+// configWarnings は起動はできるものの一部の機能が動作しない設定を返します
+func configWarnings(cfg *config.Config) []string {
+	var warnings []string
+
+	if cfg.Interactions.Enabled {
+		if _, err := interactions.ParsePublicKey(cfg.Interactions.PublicKey); err != nil {
+			warnings = append(warnings, fmt.Sprintf("interactions.enabled is true but interactions.public_key is invalid (%v)", err))
+		}
+	}
+
+	gc := cfg.GoogleCloud
+	if cfg.Features.EnableAI && !(gc.UseStudioAPI && gc.StudioAPIKey != "") && gc.ProjectID == "" {
+		warnings = append(warnings, "features.enable_ai is true but no AI backend is configured in [google_cloud]")
+	}
+	if gc.UseStudioAPI && gc.StudioAPIKey == "" {
+		warnings = append(warnings, "google_cloud.use_studio_api is true but google_cloud.studio_api_key is empty")
+	}
+
+	if cfg.Recorder.Enabled {
+		warnings = append(warnings, fmt.Sprintf("recorder is enabled; gateway events are written to %s", cfg.Recorder.Path))
+	}
+	if cfg.Bot.Debug {
+		warnings = append(warnings, "bot.debug is enabled")
+	}
+
+	return warnings
+}
+
+// databaseLabel は接続先を表示用に返します（PostgreSQL の接続文字列はパスワードを含むため表示しない）
+func databaseLabel(cfg config.DatabaseConfig) string {
+	if cfg.Driver != database.DriverPostgres {
+		return cfg.Path
+	}
+	if u, err := url.Parse(cfg.DSN); err == nil && u.Host != "" {
+		return "postgres://" + u.Host + u.Path
+	}
+	return "postgres"
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/di"
+)
+
+// runDB は luna db backup|restore|copy を実行します
+func runDB(cfg *config.Config, logger *slog.Logger, args []string) error {
+	action, rest := subcommand(args)
+	switch action {
+	case "backup":
+		return runBackup(cfg, logger, rest)
+	case "restore":
+		return runRestore(cfg, rest)
+	case "copy":
+		return runCopy(cfg, logger, rest)
+	}
+	return fmt.Errorf("usage: luna db backup [-o path] | luna db restore <backup.db> | luna db copy <luna.db>")
+}
+
+func runBackup(cfg *config.Config, logger *slog.Logger, args []string) error {
+	defaultPath := database.DefaultBackupPath(cfg.Backup.Dir, time.Now())
+	fs := flag.NewFlagSet("db backup", flag.ContinueOnError)
+	output := fs.String("o", defaultPath, "バックアップの出力先")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openDatabase(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.Backup(*output); err != nil {
+		return err
+	}
+	if err := database.VerifyBackup(*output); err != nil {
+		return err
+	}
+	fmt.Printf("Database backed up to %s\n", *output)
+	return nil
+}
+
+// runRestore はバックアップを検証し、次回のボット起動時に復元するよう予約します。
+// 実行中のボットのデータベースは置き換えません。
+func runRestore(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: luna db restore <backup.db>")
+	}
+	if cfg.Database.Driver == database.DriverPostgres {
+		return fmt.Errorf("restore is only supported for SQLite; use pg_restore for PostgreSQL")
+	}
+
+	if err := database.ScheduleRestore(cfg.Database.Path, args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Backup verified. %s will replace %s the next time the bot starts.\n", args[0], cfg.Database.Path)
+	return nil
+}
+
+// runCopy は既存の SQLite データベースの内容を config.toml のデータベース（PostgreSQL など）にコピーします。
+// コピー先のテーブルは先に作成し、既にある行はそのままにするため、繰り返し実行しても重複しません。
+func runCopy(cfg *config.Config, logger *slog.Logger, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: luna db copy <luna.db>")
+	}
+	source := args[0]
+	if cfg.Database.Driver != database.DriverPostgres && absPath(source) == absPath(cfg.Database.Path) {
+		return fmt.Errorf("source and destination are the same database: %s", source)
+	}
+
+	src, err := database.OpenSQLiteReadOnly(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	db, err := openDatabase(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := applyMigrations(db, di.ModuleMigrations(cfg)); err != nil {
+		return err
+	}
+
+	results, err := db.CopyFrom(src)
+	for _, r := range results {
+		if r.Skipped {
+			fmt.Printf("  %-16s skipped\n", r.Table)
+		} else if r.Orphaned > 0 {
+			fmt.Printf("  %-16s %d rows (%d orphaned rows skipped)\n", r.Table, r.Rows, r.Orphaned)
+		} else {
+			fmt.Printf("  %-16s %d rows\n", r.Table, r.Rows)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Copied %s into the %s database.\n", source, db.Driver())
+	return nil
+}
+
+func absPath(path string) string {
+	abs, _ := filepath.Abs(path)
+	return abs
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/Sumire-Labs/Luna/config"
+)
+
+// runGuild は luna guild export <id> を実行します
+func runGuild(cfg *config.Config, logger *slog.Logger, args []string) error {
+	action, rest := subcommand(args)
+	if action != "export" || len(rest) == 0 {
+		return fmt.Errorf("usage: luna guild export <id> [-o path]")
+	}
+
+	guildID := rest[0]
+	fs := flag.NewFlagSet("guild export", flag.ContinueOnError)
+	output := fs.String("o", "", "出力先（空の場合は標準出力）")
+	if err := fs.Parse(rest[1:]); err != nil {
+		return err
+	}
+
+	db, err := openDatabase(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	export, err := db.ExportGuild(guildID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	if err := os.WriteFile(*output, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Guild %s exported to %s\n", guildID, *output)
+	return nil
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/di"
+)
+
+// runMigrate は luna migrate status|up を実行します。
+// 対象は core と config.toml で有効なモジュールのマイグレーションです。
+func runMigrate(cfg *config.Config, logger *slog.Logger, args []string) error {
+	action, _ := subcommand(args)
+	if action != "status" && action != "up" {
+		return fmt.Errorf("usage: luna migrate status|up")
+	}
+
+	db, err := openDatabase(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrations := di.ModuleMigrations(cfg)
+	names := make([]string, 0, len(migrations))
+	for name := range migrations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pending := false
+	for _, name := range names {
+		status, err := db.SchemaStatus(name, migrations[name])
+		if err != nil {
+			return err
+		}
+
+		if status.UpToDate() {
+			fmt.Printf("  %-12s up to date\n", name)
+			continue
+		}
+		pending = true
+		if len(status.MissingTables) > 0 {
+			fmt.Printf("  %-12s missing tables: %v\n", name, status.MissingTables)
+		}
+		if len(status.MissingColumns) > 0 {
+			fmt.Printf("  %-12s missing columns: %v\n", name, status.MissingColumns)
+		}
+		for _, upgrade := range status.PendingUpgrades {
+			fmt.Printf("  %-12s pending upgrade %s\n", name, upgrade)
+		}
+	}
+
+	if action == "status" {
+		if pending {
+			fmt.Println("Run `luna migrate up` to apply the pending migrations.")
+		}
+		return nil
+	}
+
+	if err := applyMigrations(db, migrations); err != nil {
+		return err
+	}
+	fmt.Println("Migrations applied.")
+	return nil
+}
+
+// applyMigrations は core を最初に、続けて各モジュールのマイグレーションを実行します。
+// テーブルの作り直しなどの Upgrade は schema_migrations に記録され、一度だけ実行されます。
+func applyMigrations(db *database.Service, migrations map[string]database.Schema) error {
+	if err := db.Migrate(); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(migrations))
+	for name := range migrations {
+		if name != "core" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := db.MigrateModule(name, migrations[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
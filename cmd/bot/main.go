@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/Sumire-Labs/Luna/applog"
+	"github.com/Sumire-Labs/Luna/cli"
 	"github.com/Sumire-Labs/Luna/config"
 	"github.com/Sumire-Labs/Luna/di"
 )
@@ -14,21 +18,46 @@ import (
 func main() {
 	ctx := context.Background()
 
+	// 設定の検証は読み込みエラーも結果として表示するため、読み込み前に実行する
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := cli.RunConfig(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	logger, logFile, err := applog.New(cfg.Logging, cfg.Bot.Debug)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logFile.Close()
+
+	// 既存の log パッケージの出力も同じ形式・出力先に流す
+	slog.SetDefault(logger)
+
 	if cfg.Bot.Debug {
-		log.SetFlags(log.LstdFlags | log.Lshortfile)
-		log.Println("Debug mode enabled")
+		logger.Debug("Debug mode enabled")
+	}
+
+	// 保守用のサブコマンド（ゲートウェイには接続しない）
+	if len(os.Args) > 1 {
+		if err := cli.Run(cfg, logger, os.Args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "luna %s: %v\n", os.Args[1], err)
+			logFile.Close()
+			os.Exit(1)
+		}
+		return
 	}
 
-	container, err := di.NewContainer(ctx, cfg)
+	container, err := di.NewContainer(ctx, cfg, logger)
 	if err != nil {
 		log.Fatalf("Failed to initialize container: %v", err)
 	}
-	defer container.Cleanup()
 
 	if err := container.Bot.Start(); err != nil {
 		log.Fatalf("Failed to start bot: %v", err)
@@ -38,17 +67,21 @@ func main() {
 		log.Fatalf("Failed to register slash commands: %v", err)
 	}
 
-	log.Println("Luna Bot is now running. Press CTRL+C to exit.")
+	if container.Interactions != nil {
+		container.Interactions.Start()
+	}
+
+	logger.Info("Luna Bot is now running. Press CTRL+C to exit.")
 
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	<-sc
 
-	log.Println("Shutting down Luna Bot...")
+	logger.Info("Shutting down Luna Bot...")
 
-	if err := container.Bot.Stop(); err != nil {
-		log.Printf("Failed to stop bot gracefully: %v", err)
+	if err := container.Cleanup(); err != nil {
+		logger.Error("Failed to stop bot gracefully", "error", err)
 	}
 
-	log.Println("Luna Bot has been shut down successfully.")
+	logger.Info("Luna Bot has been shut down successfully.")
 }
\ No newline at end of file
@@ -11,10 +11,10 @@ import (
 )
 
 type ActivityCommand struct {
-	db *database.Service
+	db database.UsageRepository
 }
 
-func NewActivityCommand(db *database.Service) *ActivityCommand {
+func NewActivityCommand(db database.UsageRepository) *ActivityCommand {
 	return &ActivityCommand{
 		db: db,
 	}
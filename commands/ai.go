@@ -89,7 +89,7 @@ func (c *AICommand) Execute(ctx *Context) error {
 	ctx.DeferReply(false)
 	
 	// Geminiに質問
-	aiCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	aiCtx, cancel := context.WithTimeout(ctx.Ctx(), 30*time.Second)
 	defer cancel()
 	
 	var answer string
@@ -229,7 +229,7 @@ func (c *ImageCommand) Execute(ctx *Context) error {
 		ctx.EditReplyEmbed(translateEmbed.Build())
 		
 		// 翻訳リクエスト
-		translationCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		translationCtx, cancel := context.WithTimeout(ctx.Ctx(), 10*time.Second)
 		defer cancel()
 		
 		translationRequest := fmt.Sprintf(`Translate the following Japanese text to English for image generation. 
@@ -273,7 +273,7 @@ Keep the meaning and artistic intent. Only respond with the English translation,
 	ctx.EditReplyEmbed(startEmbed.Build())
 	
 	// Imagenで画像生成
-	aiCtx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	aiCtx, cancel := context.WithTimeout(ctx.Ctx(), 60*time.Second)
 	defer cancel()
 	
 	imageData, err := c.aiService.GenerateImage(aiCtx, fullPrompt, ctx.GetUser().ID)
@@ -308,7 +308,7 @@ Keep the meaning and artistic intent. Only respond with the English translation,
 	}
 	
 	// ファイル付きの応答編集はWebhookEditを使う必要がある
-	_, err = ctx.Session.InteractionResponseEdit(ctx.Interaction.Interaction, &discordgo.WebhookEdit{
+	err = ctx.EditReplyComplex(&discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{successEmbed.Build()},
 		Files:  []*discordgo.File{file},
 	})
@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/backup"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/embed"
+)
+
+// BackupCommand はボット管理者（bot.owners）がデータベースのバックアップを確認・作成・復元します。
+// 復元は実行中のデータベースを置き換えず、次回起動時に入れ替えます。
+type BackupCommand struct {
+	scheduler *backup.Scheduler
+	dbPath    string
+	owners    []string
+}
+
+func NewBackupCommand(scheduler *backup.Scheduler, dbPath string, owners []string) *BackupCommand {
+	return &BackupCommand{scheduler: scheduler, dbPath: dbPath, owners: owners}
+}
+
+func (c *BackupCommand) Name() string {
+	return "backup"
+}
+
+func (c *BackupCommand) Description() string {
+	return "データベースのバックアップを管理します（ボット管理者のみ）"
+}
+
+func (c *BackupCommand) Usage() string {
+	return "/backup <action> [file]"
+}
+
+func (c *BackupCommand) Category() string {
+	return "管理"
+}
+
+func (c *BackupCommand) Aliases() []string {
+	return []string{"バックアップ"}
+}
+
+func (c *BackupCommand) Permission() int64 {
+	return discordgo.PermissionAdministrator
+}
+
+func (c *BackupCommand) Options() []*discordgo.ApplicationCommandOption {
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "action",
+			Description: "実行するアクション",
+			Required:    true,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "📋 一覧", Value: "list"},
+				{Name: "💾 今すぐバックアップ", Value: "now"},
+				{Name: "♻️ 次回起動時に復元", Value: "restore"},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "file",
+			Description: "復元するバックアップのファイル名（例: luna-20250101-030000.db）",
+			Required:    false,
+		},
+	}
+}
+
+func (c *BackupCommand) Execute(ctx *Context) error {
+	user := ctx.GetUser()
+	if user == nil || !c.isOwner(user.ID) {
+		return ctx.ReplyEphemeral("❌ このコマンドはボット管理者のみ使用できます")
+	}
+
+	switch ctx.GetStringArg("action") {
+	case "list":
+		return c.showList(ctx)
+	case "now":
+		return c.backupNow(ctx)
+	case "restore":
+		return c.scheduleRestore(ctx, ctx.GetStringArg("file"))
+	}
+	return ctx.ReplyEphemeral("❌ 不正なアクションです")
+}
+
+func (c *BackupCommand) isOwner(userID string) bool {
+	for _, owner := range c.owners {
+		if owner == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *BackupCommand) showList(ctx *Context) error {
+	backups, err := database.ListBackups(c.scheduler.Dir())
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	builder := embed.New().
+		SetTitle("💾 バックアップ").
+		SetColor(embed.M3Colors.Primary)
+
+	if len(backups) == 0 {
+		builder.SetDescription("まだバックアップはありません")
+	} else {
+		var lines []string
+		for _, b := range backups {
+			lines = append(lines, fmt.Sprintf("`%s` <t:%d:R>（%s）", b.Name, b.CreatedAt.Unix(), formatSize(b.Size)))
+		}
+		builder.SetDescription(strings.Join(lines, "\n"))
+	}
+	if pending := database.PendingRestore(c.dbPath); pending != "" {
+		builder.AddField("♻️ 復元予定", fmt.Sprintf("次回起動時に `%s` を復元します", filepath.Base(pending)), false)
+	}
+
+	return ctx.ReplyEmbedEphemeral(builder.Build())
+}
+
+func (c *BackupCommand) backupNow(ctx *Context) error {
+	ctx.DeferReply(true)
+
+	file, err := c.scheduler.Run()
+	if errors.Is(err, backup.ErrRunning) {
+		return ctx.EditReply("⏳ 別のバックアップを作成中です。しばらくしてからもう一度お試しください")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return ctx.EditReplyEmbed(embed.Success("バックアップを作成しました", fmt.Sprintf("`%s`（%s）を作成し、整合性を確認しました", file.Name, formatSize(file.Size))))
+}
+
+func (c *BackupCommand) scheduleRestore(ctx *Context, name string) error {
+	if name == "" {
+		return ctx.ReplyEphemeral("❌ 復元するバックアップのファイル名を `file` に指定してください（`/backup list` で確認できます）")
+	}
+
+	backups, err := database.ListBackups(c.scheduler.Dir())
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+	for _, b := range backups {
+		if b.Name != name {
+			continue
+		}
+		if err := database.ScheduleRestore(c.dbPath, b.Path); err != nil {
+			return ctx.ReplyEphemeral(fmt.Sprintf("❌ `%s` は検証に失敗したため復元できません", name))
+		}
+		return ctx.ReplyEmbedEphemeral(embed.Warning("復元を予約しました",
+			fmt.Sprintf("次回起動時に `%s` でデータベースを置き換えます。\n現在のデータベースは `.pre-restore-日時` として残ります。\n反映するにはボットを再起動してください。", name)))
+	}
+	return ctx.ReplyEphemeral(fmt.Sprintf("❌ バックアップ `%s` が見つかりません", name))
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -10,10 +10,10 @@ import (
 )
 
 type BracketsCommand struct {
-	db *database.Service
+	db database.CounterRepository
 }
 
-func NewBracketsCommand(db *database.Service) *BracketsCommand {
+func NewBracketsCommand(db database.CounterRepository) *BracketsCommand {
 	return &BracketsCommand{
 		db: db,
 	}
@@ -1,6 +1,9 @@
 package commands
 
 import (
+	"context"
+	"time"
+
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -15,10 +18,22 @@ type Command interface {
 	Execute(ctx *Context) error
 }
 
+// Context はスラッシュコマンドとプレフィックスコマンドの両方で共通に使われる実行コンテキストです。
+// Interaction と Message のどちらか一方のみが設定されます。
 type Context struct {
 	Session     *discordgo.Session
 	Interaction *discordgo.InteractionCreate
+	Message     *discordgo.MessageCreate
 	Args        map[string]interface{}
+
+	// プレフィックスコマンドで送信した応答メッセージ（EditReply で編集される）
+	response *discordgo.Message
+	// プレフィックスコマンドで DeferReply(true) が呼ばれたか（後の応答も DM で送信する）
+	ephemeral bool
+	// コマンドを受信した時刻（ログの latency に使用）
+	received time.Time
+	// ワーカープールのタスクのコンテキスト（タイムアウト・シャットダウンで取り消される）
+	ctx context.Context
 }
 
 func NewContext(s *discordgo.Session, i *discordgo.InteractionCreate) *Context {
@@ -26,56 +41,167 @@ func NewContext(s *discordgo.Session, i *discordgo.InteractionCreate) *Context {
 		Session:     s,
 		Interaction: i,
 		Args:        make(map[string]interface{}),
+		received:    time.Now(),
 	}
 
-	if i.ApplicationCommandData().Options != nil {
-		for _, opt := range i.ApplicationCommandData().Options {
-			ctx.Args[opt.Name] = opt.Value
+	data := i.ApplicationCommandData()
+	if data.Options != nil {
+		for _, opt := range data.Options {
+			ctx.Args[opt.Name] = resolveOptionValue(opt, data.Resolved)
 		}
 	}
 
 	return ctx
 }
 
+// NewMessageContext はテキストメッセージからコンテキストを作成します。
+// 引数の解析は ParseArgs で事前に行っておく必要があります。
+func NewMessageContext(s *discordgo.Session, m *discordgo.MessageCreate, args map[string]interface{}) *Context {
+	if args == nil {
+		args = make(map[string]interface{})
+	}
+	return &Context{
+		Session:  s,
+		Message:  m,
+		Args:     args,
+		received: time.Now(),
+	}
+}
+
+// resolveOptionValue はユーザー・添付ファイルのオプションをプレフィックスコマンドと同じ型に揃えます
+func resolveOptionValue(opt *discordgo.ApplicationCommandInteractionDataOption, resolved *discordgo.ApplicationCommandInteractionDataResolved) interface{} {
+	id, ok := opt.Value.(string)
+	if !ok {
+		return opt.Value
+	}
+
+	switch opt.Type {
+	case discordgo.ApplicationCommandOptionUser:
+		if resolved != nil {
+			if user, ok := resolved.Users[id]; ok {
+				return user
+			}
+		}
+		return &discordgo.User{ID: id}
+	case discordgo.ApplicationCommandOptionAttachment:
+		if resolved != nil {
+			if attachment, ok := resolved.Attachments[id]; ok {
+				return attachment
+			}
+		}
+	}
+
+	return opt.Value
+}
+
+// logAttrs はログに付与するギルド・ユーザー・コマンドの属性を返します
+func (c *Context) logAttrs(command string) []any {
+	attrs := []any{"command", command, "guild_id", c.GetGuild()}
+	if user := c.GetUser(); user != nil {
+		attrs = append(attrs, "user_id", user.ID)
+	}
+	return attrs
+}
+
+// Ctx はコマンドの実行を取り消すためのコンテキストを返します。
+// AI・外部APIの呼び出しはこれを親にして、ワーカーのタイムアウトやシャットダウンで止まるようにします。
+func (c *Context) Ctx() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// IsInteraction はスラッシュコマンドから実行されたかを返します
+func (c *Context) IsInteraction() bool {
+	return c.Interaction != nil
+}
+
+// Respond は任意の応答データで返信します。
+// プレフィックスコマンドのエフェメラルの応答は他のメンバーに見えないように DM で送信し、
+// ボタンなどを含むもの（DM では操作できない）はスラッシュコマンドでの実行を案内します。
+func (c *Context) Respond(data *discordgo.InteractionResponseData) error {
+	if c.IsInteraction() {
+		return c.Session.InteractionRespond(c.Interaction.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: data,
+		})
+	}
+
+	if data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		return c.send(c.Message.ChannelID, data, c.Message.Reference())
+	}
+
+	if len(data.Components) > 0 {
+		return c.send(c.Message.ChannelID, &discordgo.InteractionResponseData{
+			Content: "🔒 この操作は他のメンバーに表示されないよう、スラッシュコマンドで実行してください",
+		}, c.Message.Reference())
+	}
+
+	dm, err := c.Session.UserChannelCreate(c.Message.Author.ID)
+	if err == nil {
+		err = c.send(dm.ID, data, nil)
+	}
+	if err != nil {
+		return c.send(c.Message.ChannelID, &discordgo.InteractionResponseData{
+			Content: "📩 DMを送信できませんでした。DMを許可するか、スラッシュコマンドで実行してください",
+		}, c.Message.Reference())
+	}
+	// DM に送信したことをリアクションで知らせる（失敗しても応答は届いている）
+	c.Session.MessageReactionAdd(c.Message.ChannelID, c.Message.ID, "📩")
+	return nil
+}
+
+// send はプレフィックスコマンドの応答をチャンネルに送信します
+func (c *Context) send(channelID string, data *discordgo.InteractionResponseData, reference *discordgo.MessageReference) error {
+	msg, err := c.Session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         data.Content,
+		Embeds:          data.Embeds,
+		Components:      data.Components,
+		Files:           data.Files,
+		AllowedMentions: data.AllowedMentions,
+		Reference:       reference,
+	})
+	if err != nil {
+		return err
+	}
+	c.response = msg
+	return nil
+}
+
 func (c *Context) Reply(content string) error {
-	return c.Session.InteractionRespond(c.Interaction.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: content,
-		},
+	return c.Respond(&discordgo.InteractionResponseData{
+		Content: content,
 	})
 }
 
 func (c *Context) ReplyEmbed(embed *discordgo.MessageEmbed) error {
-	return c.Session.InteractionRespond(c.Interaction.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Embeds: []*discordgo.MessageEmbed{embed},
-		},
+	return c.Respond(&discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{embed},
 	})
 }
 
 func (c *Context) ReplyEphemeral(content string) error {
-	return c.Session.InteractionRespond(c.Interaction.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: content,
-			Flags:   discordgo.MessageFlagsEphemeral,
-		},
+	return c.Respond(&discordgo.InteractionResponseData{
+		Content: content,
+		Flags:   discordgo.MessageFlagsEphemeral,
 	})
 }
 
 func (c *Context) ReplyEmbedEphemeral(embed *discordgo.MessageEmbed) error {
-	return c.Session.InteractionRespond(c.Interaction.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Embeds: []*discordgo.MessageEmbed{embed},
-			Flags:  discordgo.MessageFlagsEphemeral,
-		},
+	return c.Respond(&discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{embed},
+		Flags:  discordgo.MessageFlagsEphemeral,
 	})
 }
 
 func (c *Context) DeferReply(ephemeral bool) error {
+	if !c.IsInteraction() {
+		// テキストコマンドでは入力中表示で代用
+		c.ephemeral = ephemeral
+		return c.Session.ChannelTyping(c.Message.ChannelID)
+	}
+
 	var flags discordgo.MessageFlags
 	if ephemeral {
 		flags = discordgo.MessageFlagsEphemeral
@@ -89,31 +215,68 @@ func (c *Context) DeferReply(ephemeral bool) error {
 	})
 }
 
+// EditReplyComplex は応答を編集します。プレフィックスコマンドでまだ応答していない場合は新規に返信します。
+func (c *Context) EditReplyComplex(edit *discordgo.WebhookEdit) error {
+	if c.IsInteraction() {
+		_, err := c.Session.InteractionResponseEdit(c.Interaction.Interaction, edit)
+		return err
+	}
+
+	if c.response == nil {
+		data := &discordgo.InteractionResponseData{Files: edit.Files}
+		if c.ephemeral {
+			data.Flags = discordgo.MessageFlagsEphemeral
+		}
+		if edit.Content != nil {
+			data.Content = *edit.Content
+		}
+		if edit.Embeds != nil {
+			data.Embeds = *edit.Embeds
+		}
+		if edit.Components != nil {
+			data.Components = *edit.Components
+		}
+		return c.Respond(data)
+	}
+
+	msg, err := c.Session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         c.response.ID,
+		Channel:    c.response.ChannelID,
+		Content:    edit.Content,
+		Embeds:     edit.Embeds,
+		Components: edit.Components,
+		Files:      edit.Files,
+	})
+	if err != nil {
+		return err
+	}
+	c.response = msg
+	return nil
+}
+
 func (c *Context) EditReply(content string) error {
-	_, err := c.Session.InteractionResponseEdit(c.Interaction.Interaction, &discordgo.WebhookEdit{
+	return c.EditReplyComplex(&discordgo.WebhookEdit{
 		Content: &content,
 	})
-	return err
 }
 
 func (c *Context) EditReplyEmbed(embed *discordgo.MessageEmbed) error {
-	_, err := c.Session.InteractionResponseEdit(c.Interaction.Interaction, &discordgo.WebhookEdit{
+	return c.EditReplyComplex(&discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{embed},
 	})
-	return err
 }
 
 func (c *Context) ReplyWithComponents(embed *discordgo.MessageEmbed, components []discordgo.MessageComponent) error {
-	return c.Session.InteractionRespond(c.Interaction.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Embeds:     []*discordgo.MessageEmbed{embed},
-			Components: components,
-		},
+	return c.Respond(&discordgo.InteractionResponseData{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
 	})
 }
 
 func (c *Context) GetUser() *discordgo.User {
+	if !c.IsInteraction() {
+		return c.Message.Author
+	}
 	if c.Interaction.Member != nil {
 		return c.Interaction.Member.User
 	}
@@ -121,6 +284,9 @@ func (c *Context) GetUser() *discordgo.User {
 }
 
 func (c *Context) GetGuild() string {
+	if !c.IsInteraction() {
+		return c.Message.GuildID
+	}
 	if c.Interaction.GuildID != "" {
 		return c.Interaction.GuildID
 	}
@@ -128,6 +294,9 @@ func (c *Context) GetGuild() string {
 }
 
 func (c *Context) GetChannel() string {
+	if !c.IsInteraction() {
+		return c.Message.ChannelID
+	}
 	return c.Interaction.ChannelID
 }
 
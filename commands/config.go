@@ -1,14 +1,22 @@
 package commands
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/database"
 	"github.com/Sumire-Labs/Luna/embed"
+	"github.com/Sumire-Labs/Luna/features"
 )
 
-type ConfigCommand struct{}
+type ConfigCommand struct {
+	db   *database.Service
+	gate *features.Gate
+}
 
-func NewConfigCommand() *ConfigCommand {
-	return &ConfigCommand{}
+func NewConfigCommand(db *database.Service, gate *features.Gate) *ConfigCommand {
+	return &ConfigCommand{db: db, gate: gate}
 }
 
 func (c *ConfigCommand) Name() string {
@@ -20,7 +28,7 @@ func (c *ConfigCommand) Description() string {
 }
 
 func (c *ConfigCommand) Usage() string {
-	return "/config"
+	return "/config [action] [format] [file]"
 }
 
 func (c *ConfigCommand) Category() string {
@@ -36,7 +44,36 @@ func (c *ConfigCommand) Permission() int64 {
 }
 
 func (c *ConfigCommand) Options() []*discordgo.ApplicationCommandOption {
-	return []*discordgo.ApplicationCommandOption{}
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "action",
+			Description: "実行するアクション（省略時は設定パネル）",
+			Required:    false,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "⚙️ 設定パネル", Value: "panel"},
+				{Name: "📤 エクスポート", Value: "export"},
+				{Name: "📥 インポート", Value: "import"},
+				{Name: "📜 変更履歴", Value: "history"},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "format",
+			Description: "エクスポートするファイルの形式",
+			Required:    false,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "JSON", Value: "json"},
+				{Name: "TOML", Value: "toml"},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionAttachment,
+			Name:        "file",
+			Description: "インポートする設定ファイル",
+			Required:    false,
+		},
+	}
 }
 
 func (c *ConfigCommand) Execute(ctx *Context) error {
@@ -66,9 +103,168 @@ func (c *ConfigCommand) Execute(ctx *Context) error {
 		return ctx.ReplyEphemeral("❌ このコマンドを使用するには**サーバー管理**権限が必要です！")
 	}
 
+	switch ctx.GetStringArg("action") {
+	case "export":
+		return c.exportSettings(ctx)
+	case "import":
+		return c.importSettings(ctx)
+	case "history":
+		data, err := configHistoryResponse(c.db, ctx.GetGuild(), 0, "")
+		if err != nil {
+			return fmt.Errorf("failed to load config history: %w", err)
+		}
+		return ctx.Respond(data)
+	}
 	return c.showMainMenu(ctx)
 }
 
+// exportSettings はギルドの設定をファイルとして送信します
+func (c *ConfigCommand) exportSettings(ctx *Context) error {
+	guildID := ctx.GetGuild()
+	file, err := exportSettings(ctx.Session, c.db, guildID)
+	if err != nil {
+		return fmt.Errorf("failed to export settings: %w", err)
+	}
+
+	format := ctx.GetStringArg("format")
+	if format != "toml" {
+		format = "json"
+	}
+	data, err := encodeSettingsFile(file, format)
+	if err != nil {
+		return fmt.Errorf("failed to encode settings: %w", err)
+	}
+
+	builder := embed.New().
+		SetTitle("📤 設定のエクスポート").
+		SetDescription("`/config action:import` にこのファイルを添付すると、設定を復元したり別のサーバーに複製したりできます。\n別のサーバーでは、チャンネルとロールは同じ名前のものに置き換えられます。").
+		SetColor(embed.M3Colors.Primary).
+		AddField("チャンネル", fmt.Sprintf("%d件", len(file.Channels)), true).
+		AddField("ロール", fmt.Sprintf("%d件", len(file.Roles)), true).
+		AddField("機能モジュール", fmt.Sprintf("%d件", len(file.Modules)), true)
+
+	return ctx.Respond(&discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{builder.Build()},
+		Files: []*discordgo.File{{
+			Name:        fmt.Sprintf("luna-settings-%s.%s", guildID, format),
+			ContentType: "text/plain",
+			Reader:      strings.NewReader(string(data)),
+		}},
+		Flags: discordgo.MessageFlagsEphemeral,
+	})
+}
+
+// importSettings は添付された設定ファイルの変更点を表示し、適用の確認を求めます。
+// 確認メッセージにはIDを変換済みの設定ファイルを添付し、適用ボタンでそのファイルを保存します。
+func (c *ConfigCommand) importSettings(ctx *Context) error {
+	attachment := ctx.GetAttachmentArg("file")
+	if attachment == nil {
+		return ctx.ReplyEphemeral("❌ インポートする設定ファイルを `file` に添付してください")
+	}
+
+	guildID := ctx.GetGuild()
+	file, err := downloadSettingsFile(ctx.Session.Client, attachment)
+	if err != nil {
+		return ctx.ReplyEphemeral(fmt.Sprintf("❌ 設定ファイルを読み込めませんでした: %v", err))
+	}
+
+	imp, err := prepareSettingsImport(ctx.Session, c.db, c.gate, guildID, file)
+	if err != nil {
+		return fmt.Errorf("failed to prepare import: %w", err)
+	}
+	if len(imp.Changes) == 0 {
+		return ctx.ReplyEphemeral("✅ 現在の設定と同じため、変更はありません")
+	}
+
+	resolved, err := imp.resolvedFile(guildID)
+	if err != nil {
+		return err
+	}
+	data, err := encodeSettingsFile(resolved, "json")
+	if err != nil {
+		return err
+	}
+
+	return ctx.Respond(&discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{importPreviewEmbed(imp, guildID)},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Style:    discordgo.SuccessButton,
+						Label:    "✅ 適用する",
+						CustomID: "config_import_confirm",
+					},
+					discordgo.Button{
+						Style:    discordgo.SecondaryButton,
+						Label:    "❌ キャンセル",
+						CustomID: "config_import_cancel",
+					},
+				},
+			},
+		},
+		Files: []*discordgo.File{{
+			Name:        importPreviewFileName,
+			ContentType: "application/json",
+			Reader:      strings.NewReader(string(data)),
+		}},
+		Flags: discordgo.MessageFlagsEphemeral,
+	})
+}
+
+// importPreviewEmbed はインポートによる変更点の一覧を作成します
+func importPreviewEmbed(imp *settingsImport, guildID string) *discordgo.MessageEmbed {
+	source := imp.File.GuildName
+	if source == "" {
+		source = imp.File.GuildID
+	}
+	description := fmt.Sprintf("**%s** から書き出した設定です。以下の変更を適用しますか？", source)
+	if imp.File.GuildID != guildID {
+		description += "\n別のサーバーの設定のため、チャンネルとロールは同じ名前のものに置き換えました。"
+	}
+
+	// 埋め込みのフィールドは1024文字までのため、収まらない分は件数のみ表示する
+	var changes strings.Builder
+	for n, change := range imp.Changes {
+		line := fmt.Sprintf("`%s`: %s → %s\n", change.Key, displaySettingValue(change.Old), displaySettingValue(change.New))
+		if changes.Len()+len(line) > 980 {
+			fmt.Fprintf(&changes, "…ほか%d件", len(imp.Changes)-n)
+			break
+		}
+		changes.WriteString(line)
+	}
+
+	builder := embed.New().
+		SetTitle("📥 設定のインポート").
+		SetDescription(description).
+		SetColor(embed.M3Colors.Warning).
+		AddField(fmt.Sprintf("変更点（%d件）", len(imp.Changes)), changes.String(), false)
+
+	if len(imp.Unresolved) > 0 {
+		builder.AddField("⚠️ 見つからなかったチャンネル・ロール",
+			fmt.Sprintf("%s\nこれらの設定は未設定になります", strings.Join(imp.Unresolved, ", ")), false)
+	}
+
+	return builder.SetFooter("適用前の設定に戻すには、先に /config action:export で書き出してください", "").Build()
+}
+
+// canManageGuild はコンポーネント操作をしたメンバーがサーバー管理権限を持つかを返します
+func canManageGuild(i *discordgo.InteractionCreate) bool {
+	return i.Member != nil && i.Member.Permissions&(discordgo.PermissionManageGuild|discordgo.PermissionAdministrator) != 0
+}
+
+func displaySettingValue(value string) string {
+	switch value {
+	case "", "<nil>":
+		return "（未設定）"
+	case "true":
+		return "有効"
+	case "false":
+		return "無効"
+	}
+	return value
+}
+
 func (c *ConfigCommand) showMainMenu(ctx *Context) error {
 	embedBuilder := embed.New().
 		SetTitle("⚙️ サーバー設定パネル").
@@ -132,13 +328,10 @@ func (c *ConfigCommand) showMainMenu(ctx *Context) error {
 		},
 	}
 
-	return ctx.Session.InteractionRespond(ctx.Interaction.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Embeds:     []*discordgo.MessageEmbed{embedBuilder.Build()},
-			Components: components,
-			Flags:      discordgo.MessageFlagsEphemeral,
-		},
+	return ctx.Respond(&discordgo.InteractionResponseData{
+		Embeds:     []*discordgo.MessageEmbed{embedBuilder.Build()},
+		Components: components,
+		Flags:      discordgo.MessageFlagsEphemeral,
 	})
 }
 
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/embed"
+)
+
+// 設定変更履歴の1ページあたりの件数
+const configHistoryPageSize = 10
+
+// configHistoryResponse は設定変更履歴の1ページ分の表示を作成します。
+// notice は元に戻した結果などをページの上に表示するメッセージです。
+func configHistoryResponse(db *database.Service, guildID string, page int, notice string) (*discordgo.InteractionResponseData, error) {
+	total, err := db.CountConfigHistory(guildID)
+	if err != nil {
+		return nil, err
+	}
+	pages := (total + configHistoryPageSize - 1) / configHistoryPageSize
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+
+	changes, err := db.GetConfigHistory(guildID, configHistoryPageSize, page*configHistoryPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := embed.New().
+		SetTitle("📜 設定の変更履歴").
+		SetColor(embed.M3Colors.Primary)
+
+	if len(changes) == 0 {
+		builder.SetDescription("まだ設定の変更履歴はありません")
+		return &discordgo.InteractionResponseData{
+			Content:    notice,
+			Embeds:     []*discordgo.MessageEmbed{builder.Build()},
+			Components: []discordgo.MessageComponent{},
+			Flags:      discordgo.MessageFlagsEphemeral,
+		}, nil
+	}
+
+	var lines []string
+	options := make([]discordgo.SelectMenuOption, 0, len(changes))
+	for _, c := range changes {
+		lines = append(lines, fmt.Sprintf("`#%d` <t:%d:R> <@%s> **%s** `%s`\n　%s → %s",
+			c.ID, c.ChangedAt.Unix(), c.ActorID, c.Feature, c.Field,
+			shortSettingValue(c.OldValue), shortSettingValue(c.NewValue)))
+
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       fmt.Sprintf("#%d %s", c.ID, c.Field),
+			Value:       strconv.FormatInt(c.ID, 10),
+			Description: truncateLabel(fmt.Sprintf("%s に戻す", displaySettingValue(c.OldValue)), 100),
+			Emoji:       &discordgo.ComponentEmoji{Name: "↩️"},
+		})
+	}
+	builder.SetDescription(strings.Join(lines, "\n")).
+		SetFooter(fmt.Sprintf("%d / %d ページ（全%d件）・メニューから選ぶと変更前の値に戻します", page+1, pages, total), "")
+
+	return &discordgo.InteractionResponseData{
+		Content: notice,
+		Embeds:  []*discordgo.MessageEmbed{builder.Build()},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.SelectMenu{
+						CustomID:    fmt.Sprintf("config_history_revert_%d", page),
+						Placeholder: "↩️ 元に戻す変更を選択",
+						Options:     options,
+					},
+				},
+			},
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Style:    discordgo.SecondaryButton,
+						Label:    "◀ 前へ",
+						CustomID: fmt.Sprintf("config_history_page_%d", page-1),
+						Disabled: page == 0,
+					},
+					discordgo.Button{
+						Style:    discordgo.SecondaryButton,
+						Label:    "次へ ▶",
+						CustomID: fmt.Sprintf("config_history_page_%d", page+1),
+						Disabled: page+1 >= pages,
+					},
+				},
+			},
+		},
+		Flags: discordgo.MessageFlagsEphemeral,
+	}, nil
+}
+
+// revertConfigChange は履歴の変更を元に戻し、結果のメッセージを返します
+func revertConfigChange(db *database.Service, guildID, actorID, value string) string {
+	changeID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return "❌ 不正な履歴です"
+	}
+
+	change, err := db.RevertConfigChange(guildID, changeID, actorID)
+	switch {
+	case errors.Is(err, database.ErrSettingChanged):
+		return fmt.Sprintf("⚠️ `#%d` の項目はその後さらに変更されているため、元に戻せません", changeID)
+	case err != nil:
+		return "❌ 設定を元に戻せませんでした"
+	}
+	return fmt.Sprintf("↩️ `#%d` の `%s` を %s に戻しました", change.ID, change.Field, displaySettingValue(change.OldValue))
+}
+
+// shortSettingValue は履歴の一覧用に長い値（ウェルカムメッセージなど）を短くします
+func shortSettingValue(value string) string {
+	return truncateLabel(displaySettingValue(value), 40)
+}
+
+func truncateLabel(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
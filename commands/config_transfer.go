@@ -0,0 +1,430 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/features"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// settingsFileVersion は設定ファイルの形式のバージョンです
+const settingsFileVersion = 1
+
+// 設定ファイルとして読み込むサイズの上限
+const maxSettingsFileSize = 256 * 1024
+
+// インポートの確認メッセージに添付する、IDを変換済みの設定ファイル名
+const importPreviewFileName = "luna-settings-import.json"
+
+// ギルドに固有で他のサーバーに持ち込まない設定（settings_json は内部用）
+var nonPortableSettings = []string{"guild_id", "bump_last_time", "bump_reminder_sent", "settings_json", "created_at", "updated_at"}
+
+// SettingsFile はサーバー間で持ち運べるギルド設定のファイルです。
+// チャンネルとロールはIDと名前の対応を含み、別のサーバーへのインポート時に名前でIDを置き換えます。
+type SettingsFile struct {
+	Version    int                    `json:"version"`
+	GuildID    string                 `json:"guild_id"`
+	GuildName  string                 `json:"guild_name"`
+	ExportedAt time.Time              `json:"exported_at"`
+	Settings   map[string]interface{} `json:"settings"`
+	Modules    map[string]bool        `json:"modules"`
+	Channels   map[string]string      `json:"channels"` // チャンネルID → 名前
+	Roles      map[string]string      `json:"roles"`    // ロールID → 名前
+}
+
+// settingsChange はインポートで変更される設定の1項目です
+type settingsChange struct {
+	Key      string
+	Old, New string
+}
+
+// settingsImport はインポート内容を適用先のギルドに合わせて変換した結果です
+type settingsImport struct {
+	File       *SettingsFile
+	Settings   *database.GuildSettings
+	Changes    []settingsChange
+	Unresolved []string // 適用先に同じ名前のチャンネル・ロールがなかった設定
+}
+
+// settingsToMap は GuildSettings をJSONのキーで表したマップに変換します
+func settingsToMap(settings *database.GuildSettings) (map[string]interface{}, error) {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{})
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// referenceKind は設定キーが参照するものを返します（"channel"、"category"、"role"、または空）
+func referenceKind(key string) string {
+	switch {
+	case strings.HasSuffix(key, "_role_id"):
+		return "role"
+	case strings.HasSuffix(key, "_category_id"):
+		return "category"
+	case strings.HasSuffix(key, "_channel_id"):
+		return "channel"
+	}
+	return ""
+}
+
+// exportSettings はギルドの設定とモジュール設定をファイルの形式にまとめます
+func exportSettings(s *discordgo.Session, db *database.Service, guildID string) (*SettingsFile, error) {
+	settings, err := db.GetGuildSettings(guildID)
+	if err != nil {
+		return nil, err
+	}
+	values, err := settingsToMap(settings)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range nonPortableSettings {
+		delete(values, key)
+	}
+
+	modules, err := db.GetGuildModules(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &SettingsFile{
+		Version:    settingsFileVersion,
+		GuildID:    guildID,
+		ExportedAt: time.Now(),
+		Settings:   values,
+		Modules:    modules,
+		Channels:   make(map[string]string),
+		Roles:      make(map[string]string),
+	}
+	if guild, err := s.Guild(guildID); err == nil {
+		file.GuildName = guild.Name
+	}
+
+	channels, err := s.GuildChannels(guildID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channels: %w", err)
+	}
+	roles, err := s.GuildRoles(guildID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	for key, value := range values {
+		id, _ := value.(string)
+		if id == "" {
+			continue
+		}
+		switch referenceKind(key) {
+		case "channel", "category":
+			for _, ch := range channels {
+				if ch.ID == id {
+					file.Channels[id] = ch.Name
+				}
+			}
+		case "role":
+			for _, role := range roles {
+				if role.ID == id {
+					file.Roles[id] = role.Name
+				}
+			}
+		}
+	}
+
+	return file, nil
+}
+
+// encodeSettingsFile は設定ファイルをJSONまたはTOMLに変換します
+func encodeSettingsFile(file *SettingsFile, format string) ([]byte, error) {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil || format != "toml" {
+		return data, err
+	}
+
+	// TOMLはJSONのキーをそのまま使うため、マップを経由して変換する
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return toml.Marshal(integerValues(values))
+}
+
+// integerValues はJSONで float64 になった整数を int64 に戻します（TOMLで 24.0 と出力されないように）
+func integerValues(values map[string]interface{}) map[string]interface{} {
+	for key, value := range values {
+		switch v := value.(type) {
+		case float64:
+			if v == float64(int64(v)) {
+				values[key] = int64(v)
+			}
+		case map[string]interface{}:
+			integerValues(v)
+		}
+	}
+	return values
+}
+
+// decodeSettingsFile はJSONまたはTOMLの設定ファイルを読み込みます
+func decodeSettingsFile(data []byte) (*SettingsFile, error) {
+	var file SettingsFile
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		if err := json.Unmarshal(trimmed, &file); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	} else {
+		var values map[string]interface{}
+		if err := toml.Unmarshal(trimmed, &values); err != nil {
+			return nil, fmt.Errorf("invalid TOML: %w", err)
+		}
+		converted, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(converted, &file); err != nil {
+			return nil, fmt.Errorf("invalid settings file: %w", err)
+		}
+	}
+
+	if file.Version != settingsFileVersion {
+		return nil, fmt.Errorf("unsupported settings file version: %d", file.Version)
+	}
+	if file.Settings == nil {
+		return nil, fmt.Errorf("settings file has no settings")
+	}
+	return &file, nil
+}
+
+// downloadSettingsFile は添付ファイルをダウンロードして設定ファイルとして読み込みます
+func downloadSettingsFile(client *http.Client, attachment *discordgo.MessageAttachment) (*SettingsFile, error) {
+	if attachment.Size > maxSettingsFileSize {
+		return nil, fmt.Errorf("settings file is too large (%d bytes)", attachment.Size)
+	}
+
+	resp, err := client.Get(attachment.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download settings file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download settings file: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSettingsFileSize))
+	if err != nil {
+		return nil, err
+	}
+	return decodeSettingsFile(data)
+}
+
+// prepareSettingsImport はファイルの設定を現在の設定に重ね、変更点を求めます。
+// 別のサーバーから書き出したファイルの場合、チャンネルとロールのIDを名前で置き換えます。
+func prepareSettingsImport(s *discordgo.Session, db *database.Service, gate *features.Gate, guildID string, file *SettingsFile) (*settingsImport, error) {
+	current, err := db.GetGuildSettings(guildID)
+	if err != nil {
+		return nil, err
+	}
+	oldValues, err := settingsToMap(current)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &settingsImport{File: file}
+
+	var channels []*discordgo.Channel
+	var roles []*discordgo.Role
+	remap := file.GuildID != guildID
+	if remap {
+		if channels, err = s.GuildChannels(guildID); err != nil {
+			return nil, fmt.Errorf("failed to get channels: %w", err)
+		}
+		if roles, err = s.GuildRoles(guildID); err != nil {
+			return nil, fmt.Errorf("failed to get roles: %w", err)
+		}
+	}
+
+	overrides := make(map[string]interface{}, len(file.Settings))
+	for key, value := range file.Settings {
+		id, _ := value.(string)
+		if kind := referenceKind(key); remap && kind != "" && id != "" {
+			resolved := resolveReference(kind, id, file, channels, roles)
+			if resolved == "" {
+				result.Unresolved = append(result.Unresolved, key)
+			}
+			value = resolved
+		}
+		overrides[key] = value
+	}
+
+	settings, err := mergeSettings(oldValues, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings value: %w", err)
+	}
+	settings.GuildID = guildID
+	result.Settings = settings
+
+	// 型を揃えるため、変換後の値で比較する
+	applied, err := settingsToMap(settings)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range applied {
+		if isNonPortable(key) {
+			continue
+		}
+		old, new := fmt.Sprint(oldValues[key]), fmt.Sprint(value)
+		if old != new {
+			result.Changes = append(result.Changes, settingsChange{Key: key, Old: old, New: new})
+		}
+	}
+
+	modules, err := db.GetGuildModules(guildID)
+	if err != nil {
+		return nil, err
+	}
+	for name, enabled := range file.Modules {
+		if _, ok := gate.Lookup(name); !ok {
+			continue
+		}
+		current, set := modules[name]
+		if !set {
+			current = true
+		}
+		if current != enabled {
+			result.Changes = append(result.Changes, settingsChange{Key: "module." + name, Old: fmt.Sprint(current), New: fmt.Sprint(enabled)})
+		}
+	}
+
+	sort.Slice(result.Changes, func(i, j int) bool { return result.Changes[i].Key < result.Changes[j].Key })
+	sort.Strings(result.Unresolved)
+	return result, nil
+}
+
+// resolvedFile は適用先のギルドのIDに置き換えた設定ファイルを返します（確認メッセージに添付する）
+func (imp *settingsImport) resolvedFile(guildID string) (*SettingsFile, error) {
+	values, err := settingsToMap(imp.Settings)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range nonPortableSettings {
+		delete(values, key)
+	}
+
+	return &SettingsFile{
+		Version:    settingsFileVersion,
+		GuildID:    guildID,
+		GuildName:  imp.File.GuildName,
+		ExportedAt: imp.File.ExportedAt,
+		Settings:   values,
+		Modules:    imp.File.Modules,
+		Channels:   map[string]string{},
+		Roles:      map[string]string{},
+	}, nil
+}
+
+// applySettingsFile は適用先のIDに変換済みの設定ファイルを保存します
+func applySettingsFile(db *database.Service, gate *features.Gate, guildID, actorID string, file *SettingsFile) error {
+	if file.GuildID != guildID {
+		return fmt.Errorf("settings file is for guild %s", file.GuildID)
+	}
+
+	current, err := db.GetGuildSettings(guildID)
+	if err != nil {
+		return err
+	}
+	values, err := settingsToMap(current)
+	if err != nil {
+		return err
+	}
+	settings, err := mergeSettings(values, file.Settings)
+	if err != nil {
+		return err
+	}
+	settings.GuildID = guildID
+	if err := db.SaveGuildSettings(settings, actorID, "import"); err != nil {
+		return err
+	}
+
+	for name, enabled := range file.Modules {
+		if _, ok := gate.Lookup(name); !ok {
+			continue
+		}
+		if err := gate.SetEnabled(guildID, name, enabled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeSettings は現在の設定にファイルの値を重ねます。不明なキーと持ち込まない設定は無視します。
+func mergeSettings(current, overrides map[string]interface{}) (*database.GuildSettings, error) {
+	merged := make(map[string]interface{}, len(current))
+	for key, value := range current {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		if _, known := current[key]; known && !isNonPortable(key) {
+			merged[key] = value
+		}
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	settings := &database.GuildSettings{}
+	if err := json.Unmarshal(data, settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func isNonPortable(key string) bool {
+	for _, k := range nonPortableSettings {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveReference は元のサーバーのIDを、適用先で同じ名前のチャンネル・ロールのIDに置き換えます
+func resolveReference(kind, id string, file *SettingsFile, channels []*discordgo.Channel, roles []*discordgo.Role) string {
+	switch kind {
+	case "role":
+		name, ok := file.Roles[id]
+		if !ok {
+			return ""
+		}
+		for _, role := range roles {
+			if role.Name == name {
+				return role.ID
+			}
+		}
+	case "channel", "category":
+		name, ok := file.Channels[id]
+		if !ok {
+			return ""
+		}
+		for _, ch := range channels {
+			isCategory := ch.Type == discordgo.ChannelTypeGuildCategory
+			if ch.Name == name && isCategory == (kind == "category") {
+				return ch.ID
+			}
+		}
+	}
+	return ""
+}
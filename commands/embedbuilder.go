@@ -89,13 +89,10 @@ func (c *EmbedBuilderCommand) showMainMenu(ctx *Context) error {
 		},
 	}
 
-	return ctx.Session.InteractionRespond(ctx.Interaction.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Embeds:     []*discordgo.MessageEmbed{embedBuilder.Build()},
-			Components: components,
-			Flags:      discordgo.MessageFlagsEphemeral,
-		},
+	return ctx.Respond(&discordgo.InteractionResponseData{
+		Embeds:     []*discordgo.MessageEmbed{embedBuilder.Build()},
+		Components: components,
+		Flags:      discordgo.MessageFlagsEphemeral,
 	})
 }
 
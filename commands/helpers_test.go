@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/discordtest"
+)
+
+// testEnv は偽の Discord サーバーと一時的な SQLite データベースを組み合わせたテスト環境です
+type testEnv struct {
+	srv     *discordtest.Server
+	session *discordgo.Session
+	db      *database.Service
+	log     *slog.Logger
+
+	owner   *discordgo.User
+	guild   *discordgo.Guild
+	channel *discordgo.Channel
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	srv := discordtest.NewServer()
+	t.Cleanup(srv.Close)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	conn, err := database.Connect(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "luna.db"), MaxConnections: 1})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	db := database.NewService(conn, logger)
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	owner := srv.AddUser("owner", false)
+	guild := srv.AddGuild("Test", owner)
+	// ボットがメッセージを送れるように管理者ロールを付与する
+	admin := srv.AddRole(guild.ID, "Luna", discordgo.PermissionAdministrator)
+	srv.AddMember(guild.ID, srv.BotUser, admin.ID)
+	channel := srv.AddChannel(guild.ID, "general", discordgo.ChannelTypeGuildText, "")
+
+	if err := db.UpsertGuild(guild.ID, guild.Name, "!"); err != nil {
+		t.Fatalf("upsert guild: %v", err)
+	}
+
+	return &testEnv{
+		srv:     srv,
+		session: srv.Session(),
+		db:      db,
+		log:     logger,
+		owner:   owner,
+		guild:   guild,
+		channel: channel,
+	}
+}
+
+// countRequests は指定したメソッドとパスのリクエスト数を返します
+func (e *testEnv) countRequests(method, path string) int {
+	count := 0
+	for _, req := range e.srv.Requests() {
+		if req.Method == method && req.Path == path {
+			count++
+		}
+	}
+	return count
+}
+
+// waitFor は条件が満たされるまで待機します
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -2,7 +2,7 @@ package commands
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
@@ -10,7 +10,9 @@ import (
 	"github.com/Sumire-Labs/Luna/config"
 	"github.com/Sumire-Labs/Luna/database"
 	"github.com/Sumire-Labs/Luna/embed"
+	"github.com/Sumire-Labs/Luna/features"
 	"github.com/Sumire-Labs/Luna/services"
+	"github.com/Sumire-Labs/Luna/worker"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -18,13 +20,19 @@ type InteractionHandler struct {
 	session *discordgo.Session
 	config  *config.Config
 	db      *database.Service
+	writes  *database.WriteBuffer
+	gate    *features.Gate
+	log     *slog.Logger
 }
 
-func NewInteractionHandler(session *discordgo.Session, cfg *config.Config, db *database.Service) *InteractionHandler {
+func NewInteractionHandler(session *discordgo.Session, cfg *config.Config, db *database.Service, writes *database.WriteBuffer, gate *features.Gate, logger *slog.Logger) *InteractionHandler {
 	return &InteractionHandler{
 		session: session,
 		config:  cfg,
 		db:      db,
+		writes:  writes,
+		gate:    gate,
+		log:     logger,
 	}
 }
 
@@ -34,7 +42,7 @@ func (h *InteractionHandler) HandleComponentInteraction(s *discordgo.Session, i
 	}
 
 	customID := i.MessageComponentData().CustomID
-	log.Printf("HandleComponentInteraction called with customID: %s", customID)
+	h.log.Debug("Component interaction received", "custom_id", customID, "guild_id", i.GuildID, "user_id", worker.UserID(i))
 
 	switch {
 	// War Thunder BR roulette interactions
@@ -101,19 +109,40 @@ func (h *InteractionHandler) HandleComponentInteraction(s *discordgo.Session, i
 	case customID == "config_reset_cancel":
 		h.handleResetCancel(s, i)
 
+	// 設定のインポート確認
+	case customID == "config_import_confirm":
+		h.handleImportConfirm(s, i)
+	case customID == "config_import_cancel":
+		h.handleImportCancel(s, i)
+
+	// 設定の変更履歴
+	case strings.HasPrefix(customID, "config_history_page_"):
+		page, _ := strconv.Atoi(strings.TrimPrefix(customID, "config_history_page_"))
+		h.handleConfigHistory(s, i, page, "")
+	case strings.HasPrefix(customID, "config_history_revert_"):
+		page, _ := strconv.Atoi(strings.TrimPrefix(customID, "config_history_revert_"))
+		h.handleConfigHistoryRevert(s, i, page)
+
+	// 個人データの削除確認
+	case strings.HasPrefix(customID, privacyDeleteConfirmPrefix):
+		h.handlePrivacyDeleteConfirm(s, i, strings.TrimPrefix(customID, privacyDeleteConfirmPrefix))
+	case customID == "privacy_delete_cancel":
+		h.handlePrivacyDeleteCancel(s, i)
+
 	// その他
 	case strings.HasPrefix(customID, "ticket_setup_"):
 		h.handleTicketSetupStep(s, i, customID)
-	case strings.HasPrefix(customID, "ticket_close_"):
-		h.handleTicketClose(s, i, customID)
-	case strings.HasPrefix(customID, "ticket_transcript_"):
-		h.handleTicketTranscript(s, i, customID)
+	// ticket_close_ より先に確認・キャンセルを判定する
 	case strings.HasPrefix(customID, "ticket_close_confirm_"):
 		h.handleTicketCloseConfirm(s, i, customID)
 	case customID == "ticket_close_cancel":
 		h.handleTicketCloseCancel(s, i)
+	case strings.HasPrefix(customID, "ticket_close_"):
+		h.handleTicketClose(s, i, customID)
+	case strings.HasPrefix(customID, "ticket_transcript_"):
+		h.handleTicketTranscript(s, i, customID)
 	default:
-		log.Printf("Unhandled customID: %s", customID)
+		h.log.Warn("Unhandled component interaction", "custom_id", customID, "guild_id", i.GuildID)
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
@@ -150,7 +179,14 @@ func (h *InteractionHandler) handleLoggingSetup(s *discordgo.Session, i *discord
 	if i.Member != nil && i.Member.User != nil {
 		userID = i.Member.User.ID
 	}
-	log.Printf("handleLoggingSetup called for guild: %s, user: %s", i.GuildID, userID)
+	h.log.Debug("Logging setup requested", "guild_id", i.GuildID, "user_id", userID)
+
+	// 設定済みの保存日数は入力欄に表示する
+	retentionValue := ""
+	if settings, err := h.db.GetGuildSettings(i.GuildID); err == nil && settings.LogMessageRetentionDays > 0 {
+		retentionValue = strconv.Itoa(settings.LogMessageRetentionDays)
+	}
+	cacheConfig := h.config.MessageCache
 	
 	modal := discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseModal,
@@ -183,13 +219,26 @@ func (h *InteractionHandler) handleLoggingSetup(s *discordgo.Session, i *discord
 						},
 					},
 				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "log_message_retention",
+							Label:       "編集・削除ログ用にメッセージを保存する日数",
+							Style:       discordgo.TextInputShort,
+							Placeholder: fmt.Sprintf("1〜%d（空欄の場合は %d日）", cacheConfig.MaxRetentionDays, cacheConfig.RetentionDays),
+							Required:    false,
+							MaxLength:   3,
+							Value:       retentionValue,
+						},
+					},
+				},
 			},
 		},
 	}
 
 	err := s.InteractionRespond(i.Interaction, &modal)
 	if err != nil {
-		log.Printf("Failed to respond to logging setup interaction: %v", err)
+		h.log.Error("Failed to respond to logging setup interaction", "guild_id", i.GuildID, "error", err)
 		// フォールバックレスポンス
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -503,11 +552,10 @@ func (h *InteractionHandler) handleTicketSetupModal(s *discordgo.Session, i *dis
 	settings.TicketLogChannelID = logChannelID
 	settings.TicketAutoCloseHours = autoCloseHours
 
-	// Debug log
-	log.Printf("Saving ticket settings for guild %s: %+v", guildID, settings)
+	h.log.Debug("Saving ticket settings", "guild_id", guildID, "settings", settings)
 
 	// Save settings
-	if err := h.db.UpsertGuildSettings(settings); err != nil {
+	if err := h.db.SaveGuildSettings(settings, worker.UserID(i), "tickets"); err != nil {
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
@@ -566,11 +614,19 @@ func (h *InteractionHandler) handleTicketSetupModal(s *discordgo.Session, i *dis
 	})
 }
 
+// messageRetentionLabel は編集・削除ログ用にメッセージを保存する日数の表示です（0 の場合は既定値）
+func messageRetentionLabel(days, defaultDays int) string {
+	if days == 0 {
+		return fmt.Sprintf("%d日間（既定値）", defaultDays)
+	}
+	return fmt.Sprintf("%d日間", days)
+}
+
 func (h *InteractionHandler) handleLoggingSetupModal(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	data := i.ModalSubmitData()
 	guildID := i.GuildID
 
-	var logChannelID string
+	var logChannelID, retentionValue string
 
 	for _, component := range data.Components {
 		actionsRow, ok := component.(*discordgo.ActionsRow)
@@ -587,6 +643,8 @@ func (h *InteractionHandler) handleLoggingSetupModal(s *discordgo.Session, i *di
 			switch textInput.CustomID {
 			case "log_channel":
 				logChannelID = value
+			case "log_message_retention":
+				retentionValue = strings.TrimSpace(value)
 			// log_description は無視（参考用なので）
 			}
 		}
@@ -604,6 +662,24 @@ func (h *InteractionHandler) handleLoggingSetupModal(s *discordgo.Session, i *di
 		return
 	}
 
+	// メッセージの保存日数（空欄の場合は 0 = config.toml の既定値）
+	retentionDays := 0
+	maxRetentionDays := h.config.MessageCache.MaxRetentionDays
+	if retentionValue != "" {
+		days, err := strconv.Atoi(retentionValue)
+		if err != nil || days < 1 || (maxRetentionDays > 0 && days > maxRetentionDays) {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: fmt.Sprintf("❌ メッセージの保存日数は 1〜%d の数字で入力してください！", maxRetentionDays),
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+			return
+		}
+		retentionDays = days
+	}
+
 	// チャンネルの存在確認
 	channel, err := s.Channel(logChannelID)
 	if err != nil || channel.Type != discordgo.ChannelTypeGuildText {
@@ -682,6 +758,7 @@ func (h *InteractionHandler) handleLoggingSetupModal(s *discordgo.Session, i *di
 	// ログ設定を更新
 	settings.LoggingEnabled = true
 	settings.LogChannelID = logChannelID
+	settings.LogMessageRetentionDays = retentionDays
 
 	// すべてのログイベントを自動で有効にする
 	settings.LogMessageEdits = true
@@ -696,7 +773,7 @@ func (h *InteractionHandler) handleLoggingSetupModal(s *discordgo.Session, i *di
 	settings.LogNicknameChanges = true
 
 	// 設定を保存
-	if err := h.db.UpsertGuildSettings(settings); err != nil {
+	if err := h.db.SaveGuildSettings(settings, worker.UserID(i), "logging"); err != nil {
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
@@ -713,6 +790,7 @@ func (h *InteractionHandler) handleLoggingSetupModal(s *discordgo.Session, i *di
 		SetDescription("すべてのログイベントが自動で有効になりました。").
 		SetColor(embed.M3Colors.Success).
 		AddField("📍 ログチャンネル", fmt.Sprintf("<#%s>", logChannelID), false).
+		AddField("🗂️ メッセージの保存", messageRetentionLabel(retentionDays, h.config.MessageCache.RetentionDays), false).
 		AddField("📋 有効なイベント", strings.Join([]string{
 			"✅ メッセージ編集/削除",
 			"✅ メンバー参加/退出", 
@@ -834,7 +912,8 @@ func (h *InteractionHandler) handleResetConfirm(s *discordgo.Session, i *discord
 	guildID := i.GuildID
 
 	// Reset the feature
-	if err := h.db.ResetGuildSettings(guildID, feature); err != nil {
+	if err := h.db.ResetGuildSettings(guildID, feature, worker.UserID(i)); err != nil {
+		h.log.Error("Failed to reset guild settings", "guild_id", guildID, "feature", feature, "error", err)
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseUpdateMessage,
 			Data: &discordgo.InteractionResponseData{
@@ -873,6 +952,149 @@ func (h *InteractionHandler) handleResetCancel(s *discordgo.Session, i *discordg
 	})
 }
 
+// handlePrivacyDeleteConfirm は /privacy action:delete を実行したユーザー本人のデータを削除します
+func (h *InteractionHandler) handlePrivacyDeleteConfirm(s *discordgo.Session, i *discordgo.InteractionCreate, requesterID string) {
+	userID := worker.UserID(i)
+	if userID != requesterID {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ 自分のデータを削除するには `/privacy action:delete` を実行してください",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		return
+	}
+
+	result, err := erasePersonalData(h.db, h.writes, userID)
+	if err != nil {
+		h.log.Error("Failed to erase user data", "user_id", userID, "error", err)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    "❌ データの削除に失敗しました。時間をおいてもう一度お試しください",
+				Embeds:     []*discordgo.MessageEmbed{},
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		return
+	}
+
+	h.log.Info("User data erased", "user_id", userID)
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed.Success("🗑️ データを削除しました", result)},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+}
+
+func (h *InteractionHandler) handlePrivacyDeleteCancel(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    "❌ 削除をキャンセルしました。",
+			Embeds:     []*discordgo.MessageEmbed{},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+}
+
+// handleImportConfirm は確認メッセージに添付された変換済みの設定ファイルを適用します
+func (h *InteractionHandler) handleImportConfirm(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	fail := func(content string) {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:     content,
+				Embeds:      []*discordgo.MessageEmbed{},
+				Components:  []discordgo.MessageComponent{},
+				Attachments: &[]*discordgo.MessageAttachment{},
+			},
+		})
+	}
+
+	if !canManageGuild(i) {
+		fail("❌ 設定のインポートには**サーバー管理**権限が必要です！")
+		return
+	}
+
+	var attachment *discordgo.MessageAttachment
+	if i.Message != nil {
+		for _, a := range i.Message.Attachments {
+			if a.Filename == importPreviewFileName {
+				attachment = a
+			}
+		}
+	}
+	if attachment == nil {
+		fail("❌ インポートする設定が見つかりませんでした。もう一度 `/config action:import` を実行してください")
+		return
+	}
+
+	file, err := downloadSettingsFile(s.Client, attachment)
+	if err == nil {
+		err = applySettingsFile(h.db, h.gate, i.GuildID, worker.UserID(i), file)
+	}
+	if err != nil {
+		h.log.Error("Failed to import settings", "guild_id", i.GuildID, "error", err)
+		fail("❌ 設定のインポートに失敗しました！")
+		return
+	}
+
+	h.log.Info("Guild settings imported", "guild_id", i.GuildID, "user_id", worker.UserID(i), "source_guild", file.GuildName)
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:      []*discordgo.MessageEmbed{embed.Success("✅ 設定をインポートしました", "`/config` の「📋 設定確認」で内容を確認できます。")},
+			Components:  []discordgo.MessageComponent{},
+			Attachments: &[]*discordgo.MessageAttachment{},
+		},
+	})
+}
+
+func (h *InteractionHandler) handleImportCancel(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:     "❌ インポートをキャンセルしました。",
+			Embeds:      []*discordgo.MessageEmbed{},
+			Components:  []discordgo.MessageComponent{},
+			Attachments: &[]*discordgo.MessageAttachment{},
+		},
+	})
+}
+
+// handleConfigHistory は設定変更履歴のページを表示します
+func (h *InteractionHandler) handleConfigHistory(s *discordgo.Session, i *discordgo.InteractionCreate, page int, notice string) {
+	data, err := configHistoryResponse(h.db, i.GuildID, page, notice)
+	if err != nil {
+		h.log.Error("Failed to load config history", "guild_id", i.GuildID, "error", err)
+		data = &discordgo.InteractionResponseData{Content: "❌ 変更履歴を読み込めませんでした"}
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: data,
+	})
+}
+
+// handleConfigHistoryRevert は選択された変更を元に戻し、履歴を表示し直します
+func (h *InteractionHandler) handleConfigHistoryRevert(s *discordgo.Session, i *discordgo.InteractionCreate, page int) {
+	values := i.MessageComponentData().Values
+	if len(values) == 0 {
+		return
+	}
+
+	notice := "❌ 設定を元に戻すには**サーバー管理**権限が必要です！"
+	if canManageGuild(i) {
+		notice = revertConfigChange(h.db, i.GuildID, worker.UserID(i), values[0])
+		h.log.Info("Config change reverted", "guild_id", i.GuildID, "user_id", worker.UserID(i), "change", values[0], "result", notice)
+	}
+	h.handleConfigHistory(s, i, page, notice)
+}
+
 func (h *InteractionHandler) handleTicketSetupStep(s *discordgo.Session, i *discordgo.InteractionCreate, customID string) {
 	// Handle different ticket setup steps
 	// This can be expanded for multi-step setup processes
@@ -1823,7 +2045,7 @@ func (h *InteractionHandler) handleBumpSettingsSubmit(s *discordgo.Session, i *d
 	settings.BumpEnabled = true
 	
 	// 設定を保存
-	if err := h.db.UpsertGuildSettings(settings); err != nil {
+	if err := h.db.SaveGuildSettings(settings, worker.UserID(i), "bump"); err != nil {
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
@@ -1882,7 +2104,7 @@ func (h *InteractionHandler) handleTicketPanelSetup(s *discordgo.Session, i *dis
 		},
 	})
 	if err != nil {
-		log.Printf("Failed to defer interaction: %v", err)
+		h.log.Error("Failed to defer interaction", "guild_id", i.GuildID, "error", err)
 		return
 	}
 
@@ -2313,12 +2535,12 @@ func (h *InteractionHandler) handleTicketCloseConfirm(s *discordgo.Session, i *d
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("Panic recovered in channel delete goroutine: %v", r)
+				h.log.Error("Panic recovered in channel delete goroutine", "guild_id", i.GuildID, "channel_id", channelID, "panic", r)
 			}
 		}()
 		time.Sleep(2 * time.Second)
 		if _, err := s.ChannelDelete(channelID); err != nil {
-			log.Printf("Failed to delete ticket channel %s: %v", channelID, err)
+			h.log.Error("Failed to delete ticket channel", "guild_id", i.GuildID, "channel_id", channelID, "error", err)
 		}
 	}()
 
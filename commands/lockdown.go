@@ -2,17 +2,39 @@ package commands
 
 import (
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/database"
 	"github.com/Sumire-Labs/Luna/embed"
+	"github.com/Sumire-Labs/Luna/lifecycle"
+	"github.com/Sumire-Labs/Luna/shard"
 )
 
-type LockdownCommand struct{}
+// 凍結で @everyone から外す権限
+const freezePermissions = discordgo.PermissionSendMessages | discordgo.PermissionAddReactions
 
-func NewLockdownCommand() *LockdownCommand {
-	return &LockdownCommand{}
+// 自動解除の理由
+const scheduledUnlockReason = "自動解除（スケジュール）"
+
+// LockdownCommand はチャンネルのロックとサーバーの凍結を行います。
+// 自動解除の予定はデータベースに保存し、再起動後も CheckPendingLockdowns で再開します。
+type LockdownCommand struct {
+	shards    *shard.Manager
+	db        *database.Service
+	lifecycle *lifecycle.Manager
+	log       *slog.Logger
+}
+
+func NewLockdownCommand(shards *shard.Manager, db *database.Service, lc *lifecycle.Manager, logger *slog.Logger) *LockdownCommand {
+	return &LockdownCommand{
+		shards:    shards,
+		db:        db,
+		lifecycle: lc,
+		log:       logger,
+	}
 }
 
 func (c *LockdownCommand) Name() string {
@@ -153,16 +175,17 @@ func (c *LockdownCommand) executeLock(ctx *Context, target, reason string, durat
 		return ctx.EditReply(fmt.Sprintf("❌ チャンネル取得に失敗: %v", err))
 	}
 
-	successCount := 0
+	var locked []string
 	failedChannels := []string{}
 
 	for _, channel := range channels {
 		if c.lockChannel(ctx.Session, channel, reason) {
-			successCount++
+			locked = append(locked, channel.ID)
 		} else {
 			failedChannels = append(failedChannels, channel.Name)
 		}
 	}
+	successCount := len(locked)
 
 	// 結果メッセージ
 	resultEmbed := embed.New().
@@ -184,12 +207,13 @@ func (c *LockdownCommand) executeLock(ctx *Context, target, reason string, durat
 
 	resultEmbed.AddField("📋 理由", reason, false)
 
-	if duration > 0 {
-		unlockTime := time.Now().Add(time.Duration(duration) * time.Minute)
-		resultEmbed.AddField("⏰ 自動解除", fmt.Sprintf("<t:%d:R>", unlockTime.Unix()), true)
-		
+	if duration > 0 && successCount > 0 {
 		// 自動解除をスケジュール
-		go c.scheduleUnlock(ctx, target, duration)
+		c.schedule(ctx, resultEmbed, &database.LockdownSchedule{
+			Action:    database.LockdownUnlock,
+			TargetIDs: locked,
+			UnlockAt:  time.Now().Add(time.Duration(duration) * time.Minute),
+		})
 	}
 
 	resultEmbed.SetFooter(fmt.Sprintf("実行者: %s", ctx.GetUser().Username), ctx.GetUser().AvatarURL("64"))
@@ -203,18 +227,30 @@ func (c *LockdownCommand) executeUnlock(ctx *Context, target, reason string) err
 		return ctx.EditReply(fmt.Sprintf("❌ チャンネル取得に失敗: %v", err))
 	}
 
-	successCount := 0
+	var unlocked []string
 	failedChannels := []string{}
 
 	for _, channel := range channels {
-		if c.unlockChannel(ctx.Session, channel) {
-			successCount++
+		if c.unlockChannel(ctx.Session, channel.ID, channel.GuildID) {
+			unlocked = append(unlocked, channel.ID)
 		} else {
 			failedChannels = append(failedChannels, channel.Name)
 		}
 	}
 
-	// 結果メッセージ
+	// 手動で解除したチャンネルは自動解除の予定から外す
+	if err := c.db.RemoveLockdownTargets(ctx.GetGuild(), unlocked); err != nil {
+		c.log.Warn("Failed to update lockdown schedules", "guild_id", ctx.GetGuild(), "error", err)
+	}
+
+	resultEmbed := unlockResultEmbed(len(unlocked), failedChannels, reason)
+	resultEmbed.SetFooter(fmt.Sprintf("実行者: %s", ctx.GetUser().Username), ctx.GetUser().AvatarURL("64"))
+
+	return ctx.EditReplyEmbed(resultEmbed.Build())
+}
+
+// unlockResultEmbed はロック解除の結果です
+func unlockResultEmbed(successCount int, failedChannels []string, reason string) *embed.Builder {
 	resultEmbed := embed.New().
 		SetTitle("🔓 ロック解除完了").
 		SetColor(embed.M3Colors.Success).
@@ -233,9 +269,7 @@ func (c *LockdownCommand) executeUnlock(ctx *Context, target, reason string) err
 	}
 
 	resultEmbed.AddField("📋 理由", reason, false)
-	resultEmbed.SetFooter(fmt.Sprintf("実行者: %s", ctx.GetUser().Username), ctx.GetUser().AvatarURL("64"))
-
-	return ctx.EditReplyEmbed(resultEmbed.Build())
+	return resultEmbed
 }
 
 func (c *LockdownCommand) executeFreeze(ctx *Context, reason string, duration int) error {
@@ -251,9 +285,7 @@ func (c *LockdownCommand) executeFreeze(ctx *Context, reason string, duration in
 	}
 
 	// 権限を大幅に制限
-	newPermissions := everyoneRole.Permissions
-	newPermissions &^= discordgo.PermissionSendMessages
-	newPermissions &^= discordgo.PermissionAddReactions
+	newPermissions := everyoneRole.Permissions &^ freezePermissions
 
 	_, err = ctx.Session.GuildRoleEdit(ctx.GetGuild(), everyoneRole.ID, &discordgo.RoleParams{
 		Permissions: &newPermissions,
@@ -272,11 +304,12 @@ func (c *LockdownCommand) executeFreeze(ctx *Context, reason string, duration in
 		SetTimestamp()
 
 	if duration > 0 {
-		unfreezeTime := time.Now().Add(time.Duration(duration) * time.Minute)
-		resultEmbed.AddField("⏰ 自動解除", fmt.Sprintf("<t:%d:R>", unfreezeTime.Unix()), true)
-		
-		// 自動解除をスケジュール
-		go c.scheduleUnfreeze(ctx, duration, everyoneRole.Permissions)
+		// 自動解除をスケジュール（凍結前に持っていた権限だけを戻す）
+		c.schedule(ctx, resultEmbed, &database.LockdownSchedule{
+			Action:      database.LockdownUnfreeze,
+			Permissions: everyoneRole.Permissions,
+			UnlockAt:    time.Now().Add(time.Duration(duration) * time.Minute),
+		})
 	}
 
 	resultEmbed.SetFooter(fmt.Sprintf("実行者: %s", ctx.GetUser().Username), ctx.GetUser().AvatarURL("64"))
@@ -296,9 +329,7 @@ func (c *LockdownCommand) executeUnfreeze(ctx *Context, reason string) error {
 	}
 
 	// 権限を復元
-	newPermissions := everyoneRole.Permissions
-	newPermissions |= discordgo.PermissionSendMessages
-	newPermissions |= discordgo.PermissionAddReactions
+	newPermissions := everyoneRole.Permissions | freezePermissions
 
 	_, err = ctx.Session.GuildRoleEdit(ctx.GetGuild(), everyoneRole.ID, &discordgo.RoleParams{
 		Permissions: &newPermissions,
@@ -308,6 +339,10 @@ func (c *LockdownCommand) executeUnfreeze(ctx *Context, reason string) error {
 		return ctx.EditReply(fmt.Sprintf("❌ サーバー凍結解除に失敗: %v", err))
 	}
 
+	if err := c.db.CancelLockdownSchedules(ctx.GetGuild(), database.LockdownUnfreeze); err != nil {
+		c.log.Warn("Failed to cancel unfreeze schedules", "guild_id", ctx.GetGuild(), "error", err)
+	}
+
 	resultEmbed := embed.New().
 		SetTitle("🔥 サーバー凍結解除完了").
 		SetDescription("サーバーの凍結が解除されました。通常の活動が再開できます。").
@@ -372,9 +407,9 @@ func (c *LockdownCommand) lockChannel(s *discordgo.Session, channel *discordgo.C
 	return err == nil
 }
 
-func (c *LockdownCommand) unlockChannel(s *discordgo.Session, channel *discordgo.Channel) bool {
+func (c *LockdownCommand) unlockChannel(s *discordgo.Session, channelID, guildID string) bool {
 	// @everyone の送信権限拒否を削除
-	err := s.ChannelPermissionDelete(channel.ID, channel.GuildID)
+	err := s.ChannelPermissionDelete(channelID, guildID)
 	return err == nil
 }
 
@@ -387,16 +422,108 @@ func (c *LockdownCommand) findEveryoneRole(roles []*discordgo.Role) *discordgo.R
 	return nil
 }
 
-func (c *LockdownCommand) scheduleUnlock(ctx *Context, target string, duration int) {
-	time.Sleep(time.Duration(duration) * time.Minute)
-	
-	// 自動解除を実行
-	c.executeUnlock(ctx, target, "自動解除（スケジュール）")
+// schedule は自動解除の予定を保存して待機を開始し、結果メッセージに解除時刻を追加します
+func (c *LockdownCommand) schedule(ctx *Context, resultEmbed *embed.Builder, schedule *database.LockdownSchedule) {
+	schedule.GuildID = ctx.GetGuild()
+	schedule.ChannelID = ctx.GetChannel()
+
+	if err := c.db.AddLockdownSchedule(schedule); err != nil {
+		c.log.Error("Failed to save lockdown schedule", "guild_id", schedule.GuildID, "action", schedule.Action, "error", err)
+		resultEmbed.AddField("⚠️ 自動解除", "自動解除の予約に失敗しました。手動で解除してください", true)
+		return
+	}
+
+	resultEmbed.AddField("⏰ 自動解除", fmt.Sprintf("<t:%d:R>", schedule.UnlockAt.Unix()), true)
+	go c.waitSchedule(schedule.ID, schedule.UnlockAt)
 }
 
-func (c *LockdownCommand) scheduleUnfreeze(ctx *Context, duration int, originalPermissions int64) {
-	time.Sleep(time.Duration(duration) * time.Minute)
-	
-	// 自動解除を実行
-	c.executeUnfreeze(ctx, "自動解除（スケジュール）")
-}
\ No newline at end of file
+// CheckPendingLockdowns は起動時に保存されている自動解除の予定を再開します（期限を過ぎたものはすぐに解除する）
+func (c *LockdownCommand) CheckPendingLockdowns() {
+	schedules, err := c.db.GetLockdownSchedules()
+	if err != nil {
+		c.log.Error("Failed to load lockdown schedules", "error", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		go c.waitSchedule(schedule.ID, schedule.UnlockAt)
+	}
+}
+
+// waitSchedule は解除時刻まで待機し、予定が手動で解除されていなければ解除します
+func (c *LockdownCommand) waitSchedule(id int64, unlockAt time.Time) {
+	// シャットダウン時は中断（次回起動時に CheckPendingLockdowns で再スケジュールされる）
+	if !c.lifecycle.Sleep(time.Until(unlockAt)) {
+		return
+	}
+
+	schedule, err := c.db.TakeLockdownSchedule(id)
+	if err != nil {
+		c.log.Error("Failed to load lockdown schedule", "schedule_id", id, "error", err)
+		return
+	}
+	if schedule == nil {
+		return
+	}
+
+	s := c.shards.ForGuild(schedule.GuildID)
+
+	var resultEmbed *embed.Builder
+	switch schedule.Action {
+	case database.LockdownUnlock:
+		var unlocked int
+		var failed []string
+		for _, channelID := range schedule.TargetIDs {
+			if c.unlockChannel(s, channelID, schedule.GuildID) {
+				unlocked++
+			} else {
+				failed = append(failed, fmt.Sprintf("<#%s>", channelID))
+			}
+		}
+		resultEmbed = unlockResultEmbed(unlocked, failed, scheduledUnlockReason)
+
+	case database.LockdownUnfreeze:
+		if err := c.restoreEveryone(s, schedule.GuildID, schedule.Permissions); err != nil {
+			c.log.Error("Scheduled unfreeze failed", "guild_id", schedule.GuildID, "error", err)
+			resultEmbed = embed.New().
+				SetTitle("❌ サーバー凍結の自動解除に失敗しました").
+				SetDescription("`/lockdown action:unfreeze` で手動で解除してください。").
+				SetColor(embed.M3Colors.Error).
+				SetTimestamp()
+		} else {
+			resultEmbed = embed.New().
+				SetTitle("🔥 サーバー凍結解除完了").
+				SetDescription("サーバーの凍結が解除されました。通常の活動が再開できます。").
+				SetColor(embed.M3Colors.Success).
+				AddField("📋 理由", scheduledUnlockReason, false).
+				SetTimestamp()
+		}
+
+	default:
+		c.log.Warn("Unknown lockdown schedule action", "guild_id", schedule.GuildID, "action", schedule.Action)
+		return
+	}
+
+	c.log.Info("Lockdown lifted by schedule", "guild_id", schedule.GuildID, "action", schedule.Action)
+	if _, err := s.ChannelMessageSendEmbed(schedule.ChannelID, resultEmbed.Build()); err != nil {
+		c.log.Warn("Failed to send lockdown result", "guild_id", schedule.GuildID, "channel_id", schedule.ChannelID, "error", err)
+	}
+}
+
+// restoreEveryone は凍結で外した権限のうち、凍結前に @everyone が持っていたものだけを戻します
+func (c *LockdownCommand) restoreEveryone(s *discordgo.Session, guildID string, original int64) error {
+	roles, err := s.GuildRoles(guildID)
+	if err != nil {
+		return err
+	}
+	everyoneRole := c.findEveryoneRole(roles)
+	if everyoneRole == nil {
+		return fmt.Errorf("@everyone role not found")
+	}
+
+	newPermissions := everyoneRole.Permissions | original&freezePermissions
+	_, err = s.GuildRoleEdit(guildID, everyoneRole.ID, &discordgo.RoleParams{
+		Permissions: &newPermissions,
+	})
+	return err
+}
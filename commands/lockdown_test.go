@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/discordtest"
+	"github.com/Sumire-Labs/Luna/lifecycle"
+	"github.com/Sumire-Labs/Luna/shard"
+)
+
+func newTestLockdown(t *testing.T, env *testEnv) *LockdownCommand {
+	t.Helper()
+
+	lc := lifecycle.NewManager(time.Second, env.log)
+	t.Cleanup(func() { lc.Shutdown() })
+	return NewLockdownCommand(shard.NewManagerFromSessions(env.session), env.db, lc, env.log)
+}
+
+// everyoneDeniesSend はチャンネルで @everyone の発言が拒否されているかを返します
+func everyoneDeniesSend(channel *discordgo.Channel) bool {
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.ID == channel.GuildID && overwrite.Deny&discordgo.PermissionSendMessages != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLockdownLockSchedulesUnlock(t *testing.T) {
+	env := newTestEnv(t)
+	cmd := newTestLockdown(t, env)
+
+	i := env.srv.CommandInteraction(env.guild.ID, env.channel.ID, env.owner, "lockdown",
+		discordtest.StringOption("action", "lock"),
+		discordtest.IntOption("duration", 30),
+	)
+	if err := cmd.Execute(NewContext(env.session, i)); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	if !everyoneDeniesSend(env.srv.Channel(env.channel.ID)) {
+		t.Fatal("channel was not locked")
+	}
+
+	schedules, err := env.db.GetLockdownSchedules()
+	if err != nil {
+		t.Fatalf("get schedules: %v", err)
+	}
+	if len(schedules) != 1 || schedules[0].Action != database.LockdownUnlock ||
+		len(schedules[0].TargetIDs) != 1 || schedules[0].TargetIDs[0] != env.channel.ID {
+		t.Fatalf("schedules = %+v, want one unlock of %s", schedules, env.channel.ID)
+	}
+
+	// 手動で解除した場合は予定から外れる
+	i = env.srv.CommandInteraction(env.guild.ID, env.channel.ID, env.owner, "lockdown",
+		discordtest.StringOption("action", "unlock"),
+	)
+	if err := cmd.Execute(NewContext(env.session, i)); err != nil {
+		t.Fatalf("execute unlock: %v", err)
+	}
+	if everyoneDeniesSend(env.srv.Channel(env.channel.ID)) {
+		t.Fatal("channel is still locked")
+	}
+	if schedules, _ := env.db.GetLockdownSchedules(); len(schedules) != 0 {
+		t.Errorf("schedules after manual unlock = %d, want 0", len(schedules))
+	}
+}
+
+func TestLockdownResumesOverdueSchedules(t *testing.T) {
+	env := newTestEnv(t)
+	cmd := newTestLockdown(t, env)
+
+	// 再起動前にロックと凍結が行われ、どちらも解除時刻を過ぎた状態を再現する
+	for _, action := range []string{"lock", "freeze"} {
+		i := env.srv.CommandInteraction(env.guild.ID, env.channel.ID, env.owner, "lockdown",
+			discordtest.StringOption("action", action),
+		)
+		if err := cmd.Execute(NewContext(env.session, i)); err != nil {
+			t.Fatalf("execute %s: %v", action, err)
+		}
+	}
+	original := int64(discordgo.PermissionViewChannel | discordgo.PermissionSendMessages | discordgo.PermissionAddReactions | discordgo.PermissionReadMessageHistory)
+	overdue := time.Now().Add(-time.Minute)
+	for _, schedule := range []*database.LockdownSchedule{
+		{GuildID: env.guild.ID, ChannelID: env.channel.ID, Action: database.LockdownUnlock, TargetIDs: []string{env.channel.ID}, UnlockAt: overdue},
+		{GuildID: env.guild.ID, ChannelID: env.channel.ID, Action: database.LockdownUnfreeze, Permissions: original, UnlockAt: overdue},
+	} {
+		if err := env.db.AddLockdownSchedule(schedule); err != nil {
+			t.Fatalf("add schedule: %v", err)
+		}
+	}
+	if everyone := env.srv.Guild(env.guild.ID).Roles[0]; everyone.Permissions&discordgo.PermissionSendMessages != 0 {
+		t.Fatal("guild was not frozen")
+	}
+	before := len(env.srv.Messages(env.channel.ID))
+
+	newTestLockdown(t, env).CheckPendingLockdowns()
+
+	waitFor(t, 5*time.Second, func() bool {
+		return len(env.srv.Messages(env.channel.ID)) == before+2
+	})
+	if everyoneDeniesSend(env.srv.Channel(env.channel.ID)) {
+		t.Error("channel is still locked")
+	}
+	if everyone := env.srv.Guild(env.guild.ID).Roles[0]; everyone.Permissions != original {
+		t.Errorf("@everyone permissions = %d, want %d", everyone.Permissions, original)
+	}
+	if schedules, _ := env.db.GetLockdownSchedules(); len(schedules) != 0 {
+		t.Errorf("schedules after resume = %d, want 0", len(schedules))
+	}
+}
@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/embed"
+	"github.com/Sumire-Labs/Luna/features"
+)
+
+// ModuleCommand はサーバーごとに機能モジュールを有効・無効にします
+type ModuleCommand struct {
+	gate *features.Gate
+}
+
+func NewModuleCommand(gate *features.Gate) *ModuleCommand {
+	return &ModuleCommand{gate: gate}
+}
+
+func (c *ModuleCommand) Name() string {
+	return "module"
+}
+
+func (c *ModuleCommand) Description() string {
+	return "このサーバーで使用する機能を切り替えます"
+}
+
+func (c *ModuleCommand) Usage() string {
+	return "/module <action> [module]"
+}
+
+func (c *ModuleCommand) Category() string {
+	return "管理"
+}
+
+func (c *ModuleCommand) Aliases() []string {
+	return []string{"modules", "機能"}
+}
+
+func (c *ModuleCommand) Permission() int64 {
+	return discordgo.PermissionManageGuild
+}
+
+func (c *ModuleCommand) Options() []*discordgo.ApplicationCommandOption {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+	for _, m := range c.gate.Modules() {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  fmt.Sprintf("%s - %s", m.Name, m.Description),
+			Value: m.Name,
+		})
+	}
+
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "action",
+			Description: "実行するアクション",
+			Required:    true,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "📋 一覧", Value: "list"},
+				{Name: "✅ 有効化", Value: "enable"},
+				{Name: "⛔ 無効化", Value: "disable"},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "module",
+			Description: "対象の機能",
+			Required:    false,
+			Choices:     choices,
+		},
+	}
+}
+
+func (c *ModuleCommand) Execute(ctx *Context) error {
+	guildID := ctx.GetGuild()
+	if guildID == "" {
+		return ctx.ReplyEphemeral("❌ このコマンドはサーバー内でのみ使用できます！")
+	}
+
+	action := ctx.GetStringArg("action")
+	if action == "list" {
+		return c.showList(ctx, guildID)
+	}
+
+	name := ctx.GetStringArg("module")
+	module, ok := c.gate.Lookup(name)
+	if !ok {
+		return ctx.ReplyEphemeral("❌ 機能を指定してください")
+	}
+	if !module.Enabled {
+		return ctx.ReplyEphemeral(fmt.Sprintf("❌ **%s** はボットの設定で無効になっているため、切り替えできません", module.Name))
+	}
+
+	var enabled bool
+	switch action {
+	case "enable":
+		enabled = true
+	case "disable":
+		enabled = false
+	default:
+		return ctx.ReplyEphemeral("❌ 不正なアクションです")
+	}
+
+	if err := c.gate.SetEnabled(guildID, module.Name, enabled); err != nil {
+		return fmt.Errorf("failed to save module setting: %w", err)
+	}
+
+	if enabled {
+		return ctx.ReplyEmbed(embed.Success("機能を有効化しました", fmt.Sprintf("**%s**（%s）をこのサーバーで有効にしました", module.Name, module.Description)))
+	}
+	return ctx.ReplyEmbed(embed.Success("機能を無効化しました", fmt.Sprintf("**%s**（%s）をこのサーバーで無効にしました", module.Name, module.Description)))
+}
+
+func (c *ModuleCommand) showList(ctx *Context, guildID string) error {
+	builder := embed.New().
+		SetTitle("🧩 機能モジュール").
+		SetDescription("`/module enable` と `/module disable` で切り替えられます").
+		SetColor(embed.M3Colors.Primary)
+
+	for _, m := range c.gate.Modules() {
+		status := "✅ 有効"
+		switch {
+		case !m.Enabled:
+			status = "🚫 ボットの設定で無効"
+		case !c.gate.Enabled(guildID, m.Name):
+			status = "⛔ 無効"
+		}
+		builder.AddField(fmt.Sprintf("%s - %s", m.Name, m.Description), status, false)
+	}
+
+	return ctx.ReplyEmbedEphemeral(builder.Build())
+}
@@ -123,9 +123,7 @@ func (c *OCRCommand) Execute(ctx *Context) error {
 	}
 	
 	// 処理中メッセージ
-	defer ctx.Session.InteractionRespond(ctx.Interaction.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
-	})
+	defer ctx.DeferReply(false)
 	
 	// 進行状況メッセージ
 	progressEmbed := embed.New().
@@ -136,12 +134,12 @@ func (c *OCRCommand) Execute(ctx *Context) error {
 		AddField("🎯 処理モード", c.getModeDescription(mode), false).
 		SetFooter("解析には10秒〜30秒程度かかる場合があります", "")
 	
-	ctx.Session.InteractionResponseEdit(ctx.Interaction.Interaction, &discordgo.WebhookEdit{
+	ctx.EditReplyComplex(&discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{progressEmbed.Build()},
 	})
 	
 	// 画像をダウンロード
-	aiCtx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+	aiCtx, cancel := context.WithTimeout(ctx.Ctx(), 45*time.Second)
 	defer cancel()
 	
 	imageData, mimeType, err := ai.DownloadImage(aiCtx, finalImageURL)
@@ -154,7 +152,7 @@ func (c *OCRCommand) Execute(ctx *Context) error {
 			AddField("📋 対応形式", strings.Join(ai.GetSupportedImageTypes(), ", "), false).
 			SetFooter("ファイルサイズは20MB以下にしてください", "")
 		
-		_, _ = ctx.Session.InteractionResponseEdit(ctx.Interaction.Interaction, &discordgo.WebhookEdit{
+		_ = ctx.EditReplyComplex(&discordgo.WebhookEdit{
 			Embeds: &[]*discordgo.MessageEmbed{errorEmbed.Build()},
 		})
 		return nil
@@ -181,7 +179,7 @@ func (c *OCRCommand) Execute(ctx *Context) error {
 			AddField("💡 ヒント", "画像が鮮明でない、またはテキストが判読困難な可能性があります", false).
 			SetFooter("別の画像で再度お試しください", "")
 		
-		_, _ = ctx.Session.InteractionResponseEdit(ctx.Interaction.Interaction, &discordgo.WebhookEdit{
+		_ = ctx.EditReplyComplex(&discordgo.WebhookEdit{
 			Embeds: &[]*discordgo.MessageEmbed{errorEmbed.Build()},
 		})
 		return nil
@@ -201,7 +199,7 @@ func (c *OCRCommand) Execute(ctx *Context) error {
 		AddField("📄 抽出結果", result, false).
 		SetFooter(fmt.Sprintf("処理者: %s • Model: Gemini 2.5 Pro", ctx.GetUser().Username), ctx.GetUser().AvatarURL(""))
 	
-	_, err = ctx.Session.InteractionResponseEdit(ctx.Interaction.Interaction, &discordgo.WebhookEdit{
+	err = ctx.EditReplyComplex(&discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{successEmbed.Build()},
 	})
 	
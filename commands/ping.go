@@ -59,6 +59,11 @@ func (c *PingCommand) Execute(ctx *Context) error {
 		AddField("💓 WebSocket ハートビート", fmt.Sprintf("`%dms`", heartbeat), true).
 		AddBlankField(true)
 
+	// シャーディング時はこのサーバーを担当しているシャードを表示
+	if ctx.Session.ShardCount > 1 {
+		embedBuilder.AddField("🧩 シャード", fmt.Sprintf("`%d / %d`", ctx.Session.ShardID+1, ctx.Session.ShardCount), false)
+	}
+
 	quality := c.getConnectionQuality(apiLatency)
 	embedBuilder.AddField("📊 接続品質", quality, false)
 
@@ -0,0 +1,246 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	userMentionPattern    = regexp.MustCompile(`^<@!?(\d+)>$`)
+	channelMentionPattern = regexp.MustCompile(`^<#(\d+)>$`)
+	roleMentionPattern    = regexp.MustCompile(`^<@&(\d+)>$`)
+	snowflakePattern      = regexp.MustCompile(`^\d{15,21}$`)
+)
+
+// SplitArgs はコマンド引数をスペースで分割します。
+// ダブルクォート・シングルクォートで囲まれた部分は1つの引数として扱い、バックスラッシュでエスケープできます。
+func SplitArgs(input string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	escaped := false
+
+	for _, r := range input {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("閉じられていない引用符があります")
+	}
+	if escaped {
+		current.WriteRune('\\')
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// ParseArgs はテキスト引数をコマンドのオプション定義に従って解析します。
+// `name:value` 形式の名前付き引数と、オプションの定義順に割り当てる位置引数の両方に対応します。
+// 値の型はスラッシュコマンドと同じ（数値は float64、ユーザーは *discordgo.User など）に揃えます。
+func ParseArgs(s *discordgo.Session, m *discordgo.MessageCreate, options []*discordgo.ApplicationCommandOption, tokens []string) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	byName := make(map[string]*discordgo.ApplicationCommandOption, len(options))
+	for _, opt := range options {
+		byName[opt.Name] = opt
+	}
+
+	// 名前付き引数を先に取り出す
+	var positional []string
+	for _, token := range tokens {
+		if name, value, ok := strings.Cut(token, ":"); ok {
+			if opt, exists := byName[strings.ToLower(name)]; exists && value != "" {
+				parsed, err := parseOptionValue(s, m, opt, value)
+				if err != nil {
+					return nil, err
+				}
+				args[opt.Name] = parsed
+				continue
+			}
+		}
+		positional = append(positional, token)
+	}
+
+	attachmentIndex := 0
+	for idx, opt := range options {
+		if _, done := args[opt.Name]; done {
+			continue
+		}
+
+		if opt.Type == discordgo.ApplicationCommandOptionAttachment {
+			if attachmentIndex < len(m.Attachments) {
+				args[opt.Name] = m.Attachments[attachmentIndex]
+				attachmentIndex++
+			}
+			continue
+		}
+
+		if len(positional) == 0 {
+			continue
+		}
+
+		value := positional[0]
+		positional = positional[1:]
+
+		// 最後の文字列オプションは残りの引数をすべて受け取る
+		if opt.Type == discordgo.ApplicationCommandOptionString && len(opt.Choices) == 0 && isLastUnfilled(options[idx+1:], args) {
+			value = strings.Join(append([]string{value}, positional...), " ")
+			positional = nil
+		}
+
+		parsed, err := parseOptionValue(s, m, opt, value)
+		if err != nil {
+			return nil, err
+		}
+		args[opt.Name] = parsed
+	}
+
+	if len(positional) > 0 {
+		return nil, fmt.Errorf("余分な引数があります: %s", strings.Join(positional, " "))
+	}
+
+	for _, opt := range options {
+		if _, ok := args[opt.Name]; opt.Required && !ok {
+			return nil, fmt.Errorf("必須の引数 `%s` が指定されていません", opt.Name)
+		}
+	}
+
+	return args, nil
+}
+
+func isLastUnfilled(rest []*discordgo.ApplicationCommandOption, args map[string]interface{}) bool {
+	for _, opt := range rest {
+		if _, done := args[opt.Name]; !done && opt.Type != discordgo.ApplicationCommandOptionAttachment {
+			return false
+		}
+	}
+	return true
+}
+
+func parseOptionValue(s *discordgo.Session, m *discordgo.MessageCreate, opt *discordgo.ApplicationCommandOption, value string) (interface{}, error) {
+	switch opt.Type {
+	case discordgo.ApplicationCommandOptionString:
+		if len(opt.Choices) > 0 {
+			return matchChoice(opt, value)
+		}
+		if opt.MaxLength > 0 && len([]rune(value)) > opt.MaxLength {
+			return nil, fmt.Errorf("`%s` は%d文字以内で指定してください", opt.Name, opt.MaxLength)
+		}
+		return value, nil
+
+	case discordgo.ApplicationCommandOptionInteger, discordgo.ApplicationCommandOptionNumber:
+		if len(opt.Choices) > 0 {
+			return matchChoice(opt, value)
+		}
+		num, err := strconv.ParseFloat(value, 64)
+		if err != nil || (opt.Type == discordgo.ApplicationCommandOptionInteger && num != float64(int64(num))) {
+			return nil, fmt.Errorf("`%s` には数値を指定してください: %s", opt.Name, value)
+		}
+		if opt.MinValue != nil && num < *opt.MinValue {
+			return nil, fmt.Errorf("`%s` は%v以上で指定してください", opt.Name, *opt.MinValue)
+		}
+		if opt.MaxValue != 0 && num > opt.MaxValue {
+			return nil, fmt.Errorf("`%s` は%v以下で指定してください", opt.Name, opt.MaxValue)
+		}
+		return num, nil
+
+	case discordgo.ApplicationCommandOptionBoolean:
+		switch strings.ToLower(value) {
+		case "true", "yes", "on", "1", "はい":
+			return true, nil
+		case "false", "no", "off", "0", "いいえ":
+			return false, nil
+		}
+		return nil, fmt.Errorf("`%s` には true または false を指定してください", opt.Name)
+
+	case discordgo.ApplicationCommandOptionUser, discordgo.ApplicationCommandOptionMentionable:
+		id := value
+		if match := userMentionPattern.FindStringSubmatch(value); match != nil {
+			id = match[1]
+		} else if !snowflakePattern.MatchString(value) {
+			return nil, fmt.Errorf("`%s` にはユーザーのメンションまたはIDを指定してください", opt.Name)
+		}
+		for _, user := range m.Mentions {
+			if user.ID == id {
+				return user, nil
+			}
+		}
+		user, err := s.User(id)
+		if err != nil {
+			return nil, fmt.Errorf("ユーザーが見つかりません: %s", value)
+		}
+		return user, nil
+
+	case discordgo.ApplicationCommandOptionChannel:
+		if match := channelMentionPattern.FindStringSubmatch(value); match != nil {
+			return match[1], nil
+		}
+		if snowflakePattern.MatchString(value) {
+			return value, nil
+		}
+		return nil, fmt.Errorf("`%s` にはチャンネルのメンションまたはIDを指定してください", opt.Name)
+
+	case discordgo.ApplicationCommandOptionRole:
+		if match := roleMentionPattern.FindStringSubmatch(value); match != nil {
+			return match[1], nil
+		}
+		if snowflakePattern.MatchString(value) {
+			return value, nil
+		}
+		return nil, fmt.Errorf("`%s` にはロールのメンションまたはIDを指定してください", opt.Name)
+	}
+
+	return value, nil
+}
+
+// matchChoice は選択肢の値または表示名（大文字小文字を区別しない）に一致するものを返します
+func matchChoice(opt *discordgo.ApplicationCommandOption, value string) (interface{}, error) {
+	names := make([]string, 0, len(opt.Choices))
+	for _, choice := range opt.Choices {
+		choiceValue := fmt.Sprintf("%v", choice.Value)
+		if strings.EqualFold(choiceValue, value) || strings.EqualFold(choice.Name, value) {
+			switch v := choice.Value.(type) {
+			case int:
+				return float64(v), nil
+			case int64:
+				return float64(v), nil
+			}
+			return choice.Value, nil
+		}
+		names = append(names, choiceValue)
+	}
+	return nil, fmt.Errorf("`%s` は次のいずれかを指定してください: %s", opt.Name, strings.Join(names, ", "))
+}
@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{input: "", want: nil},
+		{input: "  a   b\tc ", want: []string{"a", "b", "c"}},
+		{input: `"hello world" x`, want: []string{"hello world", "x"}},
+		{input: `'it"s' ok`, want: []string{`it"s`, "ok"}},
+		{input: `a\ b c`, want: []string{"a b", "c"}},
+		{input: `say:"こんにちは 世界"`, want: []string{"say:こんにちは 世界"}},
+		{input: `""`, want: []string{""}},
+		{input: `end\`, want: []string{`end\`}},
+		{input: `"unterminated`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := SplitArgs(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SplitArgs(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitArgs(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	member := &discordgo.User{ID: "123456789012345678", Username: "member"}
+	minAmount := 1.0
+	options := []*discordgo.ApplicationCommandOption{
+		{Type: discordgo.ApplicationCommandOptionInteger, Name: "amount", Required: true, MinValue: &minAmount, MaxValue: 100},
+		{Type: discordgo.ApplicationCommandOptionUser, Name: "user"},
+		{Type: discordgo.ApplicationCommandOptionString, Name: "filter", Choices: []*discordgo.ApplicationCommandOptionChoice{
+			{Name: "Bots", Value: "bots"},
+			{Name: "Links", Value: "links"},
+		}},
+		{Type: discordgo.ApplicationCommandOptionString, Name: "reason"},
+	}
+	message := &discordgo.MessageCreate{Message: &discordgo.Message{Mentions: []*discordgo.User{member}}}
+
+	tests := []struct {
+		name    string
+		tokens  []string
+		options []*discordgo.ApplicationCommandOption
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "positional",
+			tokens: []string{"10", "<@123456789012345678>", "Bots", "spam", "again"},
+			want:   map[string]interface{}{"amount": 10.0, "user": member, "filter": "bots", "reason": "spam again"},
+		},
+		{
+			name:   "named in any order",
+			tokens: []string{"reason:test", "amount:5", "<@!123456789012345678>"},
+			want:   map[string]interface{}{"amount": 5.0, "reason": "test", "user": member},
+		},
+		{
+			name:   "unknown name is positional",
+			tokens: []string{"3", "note:hello"},
+			options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionInteger, Name: "amount"},
+				{Type: discordgo.ApplicationCommandOptionString, Name: "reason"},
+			},
+			want: map[string]interface{}{"amount": 3.0, "reason": "note:hello"},
+		},
+		{
+			name:   "channel and role mentions",
+			tokens: []string{"<#111111111111111111>", "<@&222222222222222222>"},
+			options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionChannel, Name: "channel"},
+				{Type: discordgo.ApplicationCommandOptionRole, Name: "role"},
+			},
+			want: map[string]interface{}{"channel": "111111111111111111", "role": "222222222222222222"},
+		},
+		{
+			name:   "boolean",
+			tokens: []string{"はい"},
+			options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionBoolean, Name: "enabled"},
+			},
+			want: map[string]interface{}{"enabled": true},
+		},
+		{name: "missing required", tokens: []string{}, wantErr: true},
+		{name: "not an integer", tokens: []string{"1.5"}, wantErr: true},
+		{name: "below minimum", tokens: []string{"0"}, wantErr: true},
+		{name: "above maximum", tokens: []string{"101"}, wantErr: true},
+		{name: "invalid choice", tokens: []string{"5", "<@123456789012345678>", "images"}, wantErr: true},
+		{name: "invalid user", tokens: []string{"5", "someone"}, wantErr: true},
+		{
+			name:   "extra arguments",
+			tokens: []string{"1", "2"},
+			options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionInteger, Name: "amount"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.options
+			if opts == nil {
+				opts = options
+			}
+			got, err := ParseArgs(nil, message, opts, tt.tokens)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/embed"
+)
+
+// 削除の確認ボタン（実行したユーザーのIDを付ける）
+const privacyDeleteConfirmPrefix = "privacy_delete_confirm_"
+
+// recorderNotice はエクスポート・削除の対象外のデータの説明です。
+// 不具合調査用のイベント記録（[recorder]）はデータベースではなくファイルに保存され、ユーザー単位で検索できないため含めません。
+const recorderNotice = "運営者が不具合調査のためにイベント記録を有効にしている場合、その記録ファイルは対象外です（運営者が管理し、`recorder.redact_fields` の項目は伏せられます）"
+
+// PrivacyCommand はユーザー自身のデータのエクスポート（DMに送信）と削除を行います
+type PrivacyCommand struct {
+	db     *database.Service
+	writes *database.WriteBuffer
+}
+
+func NewPrivacyCommand(db *database.Service, writes *database.WriteBuffer) *PrivacyCommand {
+	return &PrivacyCommand{db: db, writes: writes}
+}
+
+func (c *PrivacyCommand) Name() string {
+	return "privacy"
+}
+
+func (c *PrivacyCommand) Description() string {
+	return "Luna が保存しているあなたのデータをエクスポート・削除します"
+}
+
+func (c *PrivacyCommand) Usage() string {
+	return "/privacy <export|delete>"
+}
+
+func (c *PrivacyCommand) Category() string {
+	return "ユーティリティ"
+}
+
+func (c *PrivacyCommand) Aliases() []string {
+	return []string{"プライバシー"}
+}
+
+func (c *PrivacyCommand) Permission() int64 {
+	return 0
+}
+
+func (c *PrivacyCommand) Options() []*discordgo.ApplicationCommandOption {
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "action",
+			Description: "実行するアクション",
+			Required:    true,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "📦 データをエクスポート（DMに送信）", Value: "export"},
+				{Name: "🗑️ データを削除", Value: "delete"},
+			},
+		},
+	}
+}
+
+func (c *PrivacyCommand) Execute(ctx *Context) error {
+	user := ctx.GetUser()
+	if user == nil {
+		return ctx.ReplyEphemeral("❌ ユーザー情報を取得できませんでした")
+	}
+
+	switch ctx.GetStringArg("action") {
+	case "export":
+		return c.export(ctx, user)
+	case "delete":
+		return c.confirmDelete(ctx, user)
+	}
+	return ctx.ReplyEphemeral("❌ 不正なアクションです")
+}
+
+// export はユーザーIDに結びついたデータをJSONファイルにしてDMで送信します
+func (c *PrivacyCommand) export(ctx *Context, user *discordgo.User) error {
+	ctx.DeferReply(true)
+
+	// まだ書き込まれていないユーザー情報・集計も含める
+	if err := c.writes.Flush(); err != nil {
+		return fmt.Errorf("failed to flush buffered writes: %w", err)
+	}
+
+	export, err := c.db.ExportUser(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to export user data: %w", err)
+	}
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	channel, err := ctx.Session.UserChannelCreate(user.ID)
+	if err == nil {
+		_, err = ctx.Session.ChannelMessageSendComplex(channel.ID, &discordgo.MessageSend{
+			Embeds: []*discordgo.MessageEmbed{embed.Info("📦 あなたのデータ",
+				fmt.Sprintf("Luna が保存しているあなたのデータです（%s）。\n削除するには `/privacy action:delete` を実行してください。\n\n%s", summarizeExport(export), recorderNotice))},
+			Files: []*discordgo.File{{
+				Name:        fmt.Sprintf("luna-data-%s.json", user.ID),
+				ContentType: "application/json",
+				Reader:      bytes.NewReader(data),
+			}},
+		})
+	}
+	if err != nil {
+		return ctx.EditReply("❌ DMを送信できませんでした。サーバーメンバーからのDMを許可してから、もう一度お試しください")
+	}
+	return ctx.EditReply("📬 データをDMに送信しました")
+}
+
+func (c *PrivacyCommand) confirmDelete(ctx *Context, user *discordgo.User) error {
+	return ctx.Respond(&discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{embed.Warning("🗑️ データを削除しますか？",
+			"Luna が保存しているあなたのデータを削除します。この操作は元に戻せません。\n\n"+
+				"• ユーザー情報・個人設定・コマンドの実行履歴は削除されます\n"+
+				"• かっこの集計はランキングから外れ、サーバー全体の集計にのみ残ります\n"+
+				"• チケットのメッセージは内容を消去し、送信者を匿名にします\n"+
+				"• 編集・削除ログ用に保存しているメッセージは削除されます\n"+
+				"• "+recorderNotice+"\n\n"+
+				"削除後もボットを使用すると、その分は新たに記録されます。\n"+
+				"先にデータを確認する場合は `/privacy action:export` を実行してください。")},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Style:    discordgo.DangerButton,
+						Label:    "🗑️ 削除する",
+						CustomID: privacyDeleteConfirmPrefix + user.ID,
+					},
+					discordgo.Button{
+						Style:    discordgo.SecondaryButton,
+						Label:    "❌ キャンセル",
+						CustomID: "privacy_delete_cancel",
+					},
+				},
+			},
+		},
+		Flags: discordgo.MessageFlagsEphemeral,
+	})
+}
+
+// erasePersonalData はユーザーのデータを削除し、結果のメッセージを返します
+func erasePersonalData(db *database.Service, writes *database.WriteBuffer, userID string) (string, error) {
+	// バッファに残った分を先に書き込み、削除後に書き戻されないようにする
+	if err := writes.Flush(); err != nil {
+		return "", err
+	}
+
+	counts, err := db.DeleteUser(userID)
+	if err != nil {
+		return "", err
+	}
+
+	var tables []string
+	for table := range counts {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var lines []string
+	for _, table := range tables {
+		if counts[table] > 0 {
+			lines = append(lines, fmt.Sprintf("`%s`: %d件", table, counts[table]))
+		}
+	}
+	if len(lines) == 0 {
+		return "保存されているデータはありませんでした。", nil
+	}
+	return "次のデータを削除・匿名化しました。\n" + strings.Join(lines, "\n"), nil
+}
+
+// summarizeExport はエクスポートに含まれるテーブルごとの件数を返します
+func summarizeExport(export *database.UserExport) string {
+	var tables []string
+	for table := range export.Tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var parts []string
+	for _, table := range tables {
+		if n := len(export.Tables[table]); n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d件", table, n))
+		}
+	}
+	if len(parts) == 0 {
+		return "保存されているデータはありません"
+	}
+	return strings.Join(parts, "、")
+}
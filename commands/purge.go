@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -110,7 +111,7 @@ func (c *PurgeCommand) Execute(ctx *Context) error {
 	ctx.DeferReply(true)
 
 	// メッセージを取得
-	messages, err := ctx.Session.ChannelMessages(ctx.GetChannel(), amount+50, "", "", "")
+	messages, err := ctx.Session.ChannelMessages(ctx.GetChannel(), amount+50, "", "", "", discordgo.WithContext(ctx.Ctx()))
 	if err != nil {
 		return ctx.EditReply("❌ メッセージの取得に失敗しました")
 	}
@@ -140,7 +141,7 @@ func (c *PurgeCommand) Execute(ctx *Context) error {
 	}
 
 	// バルク削除の実行
-	deletedCount, err := c.bulkDeleteMessages(ctx.Session, ctx.GetChannel(), messagesToDelete)
+	deletedCount, err := c.bulkDeleteMessages(ctx.Ctx(), ctx.Session, ctx.GetChannel(), messagesToDelete)
 	if err != nil {
 		return ctx.EditReply(fmt.Sprintf("❌ メッセージ削除中にエラーが発生しました: %v", err))
 	}
@@ -227,7 +228,8 @@ func (c *PurgeCommand) matchesFilter(msg *discordgo.Message, targetUser *discord
 	return true
 }
 
-func (c *PurgeCommand) bulkDeleteMessages(s *discordgo.Session, channelID string, messages []*discordgo.Message) (int, error) {
+// bulkDeleteMessages はメッセージを削除します。taskCtx が取り消された場合は残りを削除せずに中断します。
+func (c *PurgeCommand) bulkDeleteMessages(taskCtx context.Context, s *discordgo.Session, channelID string, messages []*discordgo.Message) (int, error) {
 	if len(messages) == 0 {
 		return 0, nil
 	}
@@ -255,15 +257,19 @@ func (c *PurgeCommand) bulkDeleteMessages(s *discordgo.Session, channelID string
 				end = len(messageIDs)
 			}
 
+			if taskCtx.Err() != nil {
+				return deletedCount, taskCtx.Err()
+			}
+
 			batch := messageIDs[i:end]
 			if len(batch) >= 2 {
-				err := s.ChannelMessagesBulkDelete(channelID, batch)
+				err := s.ChannelMessagesBulkDelete(channelID, batch, discordgo.WithContext(taskCtx))
 				if err == nil {
 					deletedCount += len(batch)
 				}
 			} else if len(batch) == 1 {
 				// 1件の場合は個別削除
-				err := s.ChannelMessageDelete(channelID, batch[0])
+				err := s.ChannelMessageDelete(channelID, batch[0], discordgo.WithContext(taskCtx))
 				if err == nil {
 					deletedCount++
 				}
@@ -271,7 +277,7 @@ func (c *PurgeCommand) bulkDeleteMessages(s *discordgo.Session, channelID string
 		}
 	} else if len(messageIDs) == 1 {
 		// 1件の場合は個別削除
-		err := s.ChannelMessageDelete(channelID, messageIDs[0])
+		err := s.ChannelMessageDelete(channelID, messageIDs[0], discordgo.WithContext(taskCtx))
 		if err == nil {
 			deletedCount++
 		}
@@ -279,7 +285,10 @@ func (c *PurgeCommand) bulkDeleteMessages(s *discordgo.Session, channelID string
 
 	// 古いメッセージは個別削除
 	for _, msg := range singleDeletes {
-		err := s.ChannelMessageDelete(channelID, msg.ID)
+		if taskCtx.Err() != nil {
+			return deletedCount, taskCtx.Err()
+		}
+		err := s.ChannelMessageDelete(channelID, msg.ID, discordgo.WithContext(taskCtx))
 		if err == nil {
 			deletedCount++
 		}
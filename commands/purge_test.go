@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/discordtest"
+)
+
+func TestPurgeDeletesMatchingMessages(t *testing.T) {
+	env := newTestEnv(t)
+	bot := env.srv.AddUser("other-bot", true)
+	env.srv.AddMember(env.guild.ID, bot)
+
+	for i := 0; i < 3; i++ {
+		env.srv.AddMessage(env.channel.ID, env.owner, "hello")
+		env.srv.AddMessage(env.channel.ID, bot, "beep")
+	}
+
+	i := env.srv.CommandInteraction(env.guild.ID, env.channel.ID, env.owner, "purge",
+		discordtest.IntOption("amount", 10),
+		discordtest.StringOption("filter", "bots"),
+	)
+	if err := NewPurgeCommand().Execute(NewContext(env.session, i)); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	remaining := env.srv.Messages(env.channel.ID)
+	if len(remaining) != 3 {
+		t.Fatalf("remaining messages = %d, want 3", len(remaining))
+	}
+	for _, msg := range remaining {
+		if msg.Author.ID != env.owner.ID {
+			t.Errorf("bot message %s was not deleted", msg.ID)
+		}
+	}
+
+	if n := env.countRequests("POST", "/channels/"+env.channel.ID+"/messages/bulk-delete"); n != 1 {
+		t.Errorf("bulk-delete requests = %d, want 1", n)
+	}
+
+	record := env.srv.Interaction(i.ID)
+	if record.ResponseType != discordgo.InteractionResponseDeferredChannelMessageWithSource || !record.Ephemeral() {
+		t.Errorf("response = %d (ephemeral %v), want ephemeral deferred reply", record.ResponseType, record.Ephemeral())
+	}
+	if record.Original == nil || len(record.Original.Embeds) == 0 {
+		t.Fatal("result embed was not sent")
+	}
+}
+
+func TestPurgeRequiresManageMessages(t *testing.T) {
+	env := newTestEnv(t)
+	member := env.srv.AddUser("member", false)
+	env.srv.AddMember(env.guild.ID, member)
+	env.srv.AddMessage(env.channel.ID, env.owner, "hello")
+
+	i := env.srv.CommandInteraction(env.guild.ID, env.channel.ID, member, "purge", discordtest.IntOption("amount", 10))
+	if err := NewPurgeCommand().Execute(NewContext(env.session, i)); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	if n := len(env.srv.Messages(env.channel.ID)); n != 1 {
+		t.Errorf("remaining messages = %d, want 1", n)
+	}
+	if record := env.srv.Interaction(i.ID); !record.Ephemeral() {
+		t.Error("permission error should be ephemeral")
+	}
+}
@@ -1,31 +1,57 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
 	"sync"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/Sumire-Labs/Luna/config"
 	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/features"
+	"github.com/Sumire-Labs/Luna/shard"
+	"github.com/Sumire-Labs/Luna/worker"
+)
+
+// 機能ごとに必要なゲートウェイインテント
+const (
+	// チケットはチャンネルとロールのキャッシュを使用
+	TicketIntents = discordgo.IntentGuilds
+	// OCRは直前のメッセージの添付ファイルを参照する
+	AIIntents = discordgo.IntentGuilds | discordgo.IntentGuildMessages | discordgo.IntentMessageContent
 )
 
 type Registry struct {
 	session           *discordgo.Session
+	shards            *shard.Manager
 	config            *config.Config
 	db                *database.Service
 	commands          map[string]Command
+	modules           map[string]string // コマンド名 → 所属する機能モジュール
+	gate              *features.Gate
+	log               *slog.Logger
 	interactionHandler *InteractionHandler
+	pool              *worker.Pool
 	mutex             sync.RWMutex
 }
 
-func NewRegistry(session *discordgo.Session, cfg *config.Config, db *database.Service) *Registry {
+func NewRegistry(shards *shard.Manager, cfg *config.Config, db *database.Service, writes *database.WriteBuffer, pool *worker.Pool, gate *features.Gate, logger *slog.Logger) *Registry {
 	return &Registry{
-		session:            session,
+		session:            shards.Primary(),
+		shards:             shards,
 		config:             cfg,
 		db:                 db,
+		pool:               pool,
 		commands:           make(map[string]Command),
-		interactionHandler: NewInteractionHandler(session, cfg, db),
+		modules:            make(map[string]string),
+		gate:               gate,
+		log:                logger,
+		interactionHandler: NewInteractionHandler(shards.Primary(), cfg, db, writes, gate, logger),
 	}
 }
 
@@ -49,6 +75,19 @@ func (r *Registry) Register(cmd Command) error {
 	return nil
 }
 
+// RegisterModule は機能モジュールに属するコマンドを登録します。
+// モジュールがギルドで無効にされている場合、そのギルドではコマンドを実行しません。
+func (r *Registry) RegisterModule(module string, cmd Command) error {
+	if err := r.Register(cmd); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.modules[cmd.Name()] = module
+	return nil
+}
+
 func (r *Registry) Get(name string) (Command, bool) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
@@ -74,7 +113,30 @@ func (r *Registry) GetAll() []Command {
 	return cmds
 }
 
+// Intents はテキストコマンドの受信に必要なゲートウェイインテントを返します
+func (r *Registry) Intents() discordgo.Intent {
+	return discordgo.IntentGuilds |
+		discordgo.IntentGuildMessages |
+		discordgo.IntentDirectMessages |
+		discordgo.IntentMessageContent
+}
+
+// RegisterSlashCommands はスラッシュコマンドを同期し、コマンドのハンドラーを登録します
 func (r *Registry) RegisterSlashCommands() error {
+	if _, err := r.SyncSlashCommands(); err != nil {
+		return err
+	}
+
+	r.shards.AddHandler(r.handleInteraction)
+	r.shards.AddHandler(worker.Handler(r.pool, "interaction.component", r.interactionHandler.HandleComponentInteraction))
+	r.shards.AddHandler(worker.Handler(r.pool, "interaction.modal_submit", r.interactionHandler.HandleModalSubmit))
+	r.shards.AddHandler(worker.HandlerContext(r.pool, "command.prefix", r.handleMessage))
+
+	return nil
+}
+
+// ApplicationCommands は登録済みのコマンドをDiscordに送信する形式で返します
+func (r *Registry) ApplicationCommands() []*discordgo.ApplicationCommand {
 	applicationCommands := make([]*discordgo.ApplicationCommand, 0)
 
 	for _, cmd := range r.GetAll() {
@@ -88,26 +150,47 @@ func (r *Registry) RegisterSlashCommands() error {
 		applicationCommands = append(applicationCommands, appCmd)
 	}
 
+	sort.Slice(applicationCommands, func(i, j int) bool {
+		return applicationCommands[i].Name < applicationCommands[j].Name
+	})
+	return applicationCommands
+}
+
+// SyncSlashCommands はグローバルコマンドを登録済みのコマンドで置き換えます。
+// 無効にしたモジュールのコマンドなど、登録されていないコマンドはDiscordから削除されます。
+func (r *Registry) SyncSlashCommands() ([]*discordgo.ApplicationCommand, error) {
+	appID, err := r.ApplicationID()
+	if err != nil {
+		return nil, err
+	}
+
 	// Use global commands (guildID = "")
-	registeredCommands := make([]*discordgo.ApplicationCommand, len(applicationCommands))
-	for i, cmd := range applicationCommands {
-		registered, err := r.session.ApplicationCommandCreate(
-			r.session.State.User.ID,
-			"", // Empty string for global commands
-			cmd,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to register command %s: %w", cmd.Name, err)
-		}
-		registeredCommands[i] = registered
-		log.Printf("Registered global slash command: %s", cmd.Name)
+	registered, err := r.session.ApplicationCommandBulkOverwrite(appID, "", r.ApplicationCommands())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sync slash commands: %w", err)
 	}
 
-	r.session.AddHandler(r.handleInteraction)
-	r.session.AddHandler(r.interactionHandler.HandleComponentInteraction)
-	r.session.AddHandler(r.interactionHandler.HandleModalSubmit)
+	for _, cmd := range registered {
+		r.log.Info("Registered global slash command", "command", cmd.Name)
+	}
+	return registered, nil
+}
 
-	return nil
+// ApplicationID はコマンドの登録に使うアプリケーションIDを返します。
+// ゲートウェイに接続していない場合（luna commands）は設定またはAPIから取得します。
+func (r *Registry) ApplicationID() (string, error) {
+	if r.session.State != nil && r.session.State.User != nil {
+		return r.session.State.User.ID, nil
+	}
+	if r.config.Discord.AppID != "" {
+		return r.config.Discord.AppID, nil
+	}
+
+	app, err := r.session.Application("@me")
+	if err != nil {
+		return "", fmt.Errorf("failed to get application: %w", err)
+	}
+	return app.ID, nil
 }
 
 func (r *Registry) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -118,62 +201,216 @@ func (r *Registry) handleInteraction(s *discordgo.Session, i *discordgo.Interact
 	cmdName := i.ApplicationCommandData().Name
 	cmd, ok := r.Get(cmdName)
 	if !ok {
-		log.Printf("Unknown command: %s", cmdName)
+		r.log.Warn("Unknown command", "command", cmdName, "guild_id", i.GuildID)
 		return
 	}
 
 	ctx := NewContext(s, i)
 
-	go func() {
-		execErr := cmd.Execute(ctx)
-		if execErr != nil {
-			log.Printf("Error executing command %s: %v", cmdName, execErr)
-			
-			errorMsg := fmt.Sprintf("An error occurred while executing the command: %v", execErr)
-			if ctx.Interaction.Interaction.AppID != "" {
-				ctx.EditReply(errorMsg)
-			} else {
-				ctx.Reply(errorMsg)
-			}
+	r.submit(cmd, cmdName, ctx)
+}
+
+// Dispatch はゲートウェイ以外（HTTPインタラクションエンドポイント）で受信したインタラクションを
+// ゲートウェイと同じハンドラーに振り分けます。ワーカープールに投入できなかった場合はエラーを返します。
+func (r *Registry) Dispatch(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		// 混雑時の返信はHTTPレスポンスとして返す必要があるため、ここでは返信せずにエラーを返す
+		cmdName := i.ApplicationCommandData().Name
+		cmd, ok := r.Get(cmdName)
+		if !ok {
+			return fmt.Errorf("unknown command: %s", cmdName)
 		}
+		return r.enqueue(cmd, cmdName, NewContext(s, i))
+	case discordgo.InteractionMessageComponent:
+		return r.pool.Submit(worker.GuildID(i), "interaction.component", func(context.Context) {
+			r.interactionHandler.HandleComponentInteraction(s, i)
+		})
+	case discordgo.InteractionModalSubmit:
+		return r.pool.Submit(worker.GuildID(i), "interaction.modal_submit", func(context.Context) {
+			r.interactionHandler.HandleModalSubmit(s, i)
+		})
+	default:
+		return fmt.Errorf("unsupported interaction type: %d", i.Type)
+	}
+}
+
+// handleMessage はプレフィックス付きのテキストメッセージをコマンドとして実行します
+func (r *Registry) handleMessage(taskCtx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.Bot {
+		return
+	}
 
-		guildID := ""
-		if i.GuildID != "" {
-			guildID = i.GuildID
+	body, ok := r.stripPrefix(s, m)
+	if !ok {
+		return
+	}
+
+	tokens, err := SplitArgs(body)
+	if err != nil || len(tokens) == 0 {
+		return
+	}
+
+	cmdName := strings.ToLower(tokens[0])
+	cmd, ok := r.Get(cmdName)
+	if !ok {
+		return
+	}
+
+	ctx := NewMessageContext(s, m, nil)
+	ctx.ctx = taskCtx
+
+	if m.GuildID == "" && !*r.getDMPermission(cmd) {
+		ctx.Reply("❌ このコマンドはサーバー内でのみ使用できます！")
+		return
+	}
+
+	if perms := cmd.Permission(); perms != 0 && m.GuildID != "" {
+		userPerms, err := s.UserChannelPermissions(m.Author.ID, m.ChannelID)
+		if err != nil || (userPerms&perms != perms && userPerms&discordgo.PermissionAdministrator == 0) {
+			ctx.Reply("❌ このコマンドを実行する権限がありません！")
+			return
+		}
+	}
+
+	args, err := ParseArgs(s, m, cmd.Options(), tokens[1:])
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("❌ %v\n使い方: `%s`", err, cmd.Usage()))
+		return
+	}
+	ctx.Args = args
+
+	r.execute(cmd, cmd.Name(), ctx)
+}
+
+// stripPrefix はギルドのプレフィックスまたはボットへのメンションを取り除いた本文を返します
+func (r *Registry) stripPrefix(s *discordgo.Session, m *discordgo.MessageCreate) (string, bool) {
+	prefix := r.config.Bot.Prefix
+	if m.GuildID != "" {
+		if guildPrefix, err := r.db.GetGuildPrefix(m.GuildID); err == nil && guildPrefix != "" {
+			prefix = guildPrefix
 		}
+	}
 
-		user := ctx.GetUser()
-		if user != nil {
-			var errorMessage string
-			if execErr != nil {
-				errorMessage = execErr.Error()
+	if prefix != "" && strings.HasPrefix(m.Content, prefix) {
+		return strings.TrimPrefix(m.Content, prefix), true
+	}
+
+	if s.State != nil && s.State.User != nil {
+		for _, mention := range []string{"<@" + s.State.User.ID + ">", "<@!" + s.State.User.ID + ">"} {
+			if strings.HasPrefix(m.Content, mention) {
+				return strings.TrimPrefix(m.Content, mention), true
 			}
-			
-			r.db.LogCommand(
-				guildID,
-				user.ID,
-				cmdName,
-				fmt.Sprintf("%v", ctx.Args),
-				execErr == nil,
-				errorMessage,
-			)
 		}
-	}()
+	}
+
+	return "", false
+}
+
+// submit はコマンドをワーカープールに投入します。混雑している場合はその旨を返信します。
+func (r *Registry) submit(cmd Command, cmdName string, ctx *Context) {
+	err := r.enqueue(cmd, cmdName, ctx)
+	if errors.Is(err, worker.ErrPoolClosed) {
+		ctx.ReplyEphemeral("🔄 ボットを再起動しています。しばらくしてから再度お試しください。")
+	} else if err != nil {
+		r.log.Warn("Rejected command", append(ctx.logAttrs(cmdName), "error", err)...)
+		ctx.ReplyEphemeral("⏳ 現在混み合っています。しばらくしてから再度お試しください。")
+	}
+}
+
+// enqueue はコマンドをワーカープールに投入します
+func (r *Registry) enqueue(cmd Command, cmdName string, ctx *Context) error {
+	return r.pool.Submit(ctx.GetGuild(), "command."+cmdName, func(taskCtx context.Context) {
+		ctx.ctx = taskCtx
+		r.execute(cmd, cmdName, ctx)
+	})
+}
+
+// execute はコマンドを実行し、エラー応答と使用履歴の記録を行います
+func (r *Registry) execute(cmd Command, cmdName string, ctx *Context) {
+	r.mutex.RLock()
+	module, inModule := r.modules[cmd.Name()]
+	r.mutex.RUnlock()
+	if inModule && !r.gate.Enabled(ctx.GetGuild(), module) {
+		ctx.ReplyEphemeral("❌ この機能はこのサーバーでは無効になっています。")
+		return
+	}
+
+	execErr := cmd.Execute(ctx)
+	attrs := append(ctx.logAttrs(cmdName), "latency", time.Since(ctx.received))
+	if execErr != nil {
+		r.log.Error("Command failed", append(attrs, "error", execErr)...)
+
+		errorMsg := fmt.Sprintf("An error occurred while executing the command: %v", execErr)
+		if !ctx.IsInteraction() || ctx.Interaction.Interaction.AppID != "" {
+			ctx.EditReply(errorMsg)
+		} else {
+			ctx.Reply(errorMsg)
+		}
+	} else {
+		r.log.Info("Command executed", attrs...)
+	}
+
+	user := ctx.GetUser()
+	if user != nil {
+		var errorMessage string
+		if execErr != nil {
+			errorMessage = execErr.Error()
+		}
+
+		// command_usage は users を参照するため、初めてのユーザーは先に登録する
+		// （メッセージ経由の登録はまとめて書き込まれるため、まだ反映されていない場合がある）
+		if err := r.db.UpsertUser(user.ID, user.Username, user.Discriminator, user.Avatar, user.Bot); err != nil {
+			r.log.Error("Failed to upsert user", append(attrs, "error", err)...)
+		}
+		err := r.db.LogCommand(
+			ctx.GetGuild(),
+			user.ID,
+			cmdName,
+			r.formatArgs(cmd.Name(), ctx.Args),
+			execErr == nil,
+			errorMessage,
+		)
+		if err != nil {
+			r.log.Error("Failed to log command usage", append(attrs, "error", err)...)
+		}
+	}
+}
+
+// formatArgs はコマンド履歴に記録する引数を返します。
+// retention.redact_commands のコマンドは、利用者が入力した文章などを残さないよう値を伏せて引数名だけを記録します。
+func (r *Registry) formatArgs(cmdName string, args map[string]interface{}) string {
+	for _, name := range r.config.Retention.RedactCommands {
+		if name != cmdName {
+			continue
+		}
+		redacted := make(map[string]interface{}, len(args))
+		for key := range args {
+			redacted[key] = "[redacted]"
+		}
+		return fmt.Sprintf("%v", redacted)
+	}
+	return fmt.Sprintf("%v", args)
 }
 
 func (r *Registry) UnregisterSlashCommands() error {
+	appID, err := r.ApplicationID()
+	if err != nil {
+		return err
+	}
+
 	// Use global commands (guildID = "")
-	commands, err := r.session.ApplicationCommands(r.session.State.User.ID, "")
+	commands, err := r.session.ApplicationCommands(appID, "")
 	if err != nil {
 		return fmt.Errorf("failed to get application commands: %w", err)
 	}
 
 	for _, cmd := range commands {
-		err := r.session.ApplicationCommandDelete(r.session.State.User.ID, "", cmd.ID)
+		err := r.session.ApplicationCommandDelete(appID, "", cmd.ID)
 		if err != nil {
 			return fmt.Errorf("failed to delete command %s: %w", cmd.Name, err)
 		}
-		log.Printf("Unregistered global slash command: %s", cmd.Name)
+		r.log.Info("Unregistered global slash command", "command", cmd.Name)
 	}
 
 	return nil
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/features"
+)
+
+func TestTicketCreateAndClose(t *testing.T) {
+	env := newTestEnv(t)
+	handler := NewInteractionHandler(env.session, &config.Config{}, env.db, nil, features.NewGate(env.db, env.log), env.log)
+
+	category := env.srv.AddChannel(env.guild.ID, "tickets", discordgo.ChannelTypeGuildCategory, "")
+	logChannel := env.srv.AddChannel(env.guild.ID, "ticket-log", discordgo.ChannelTypeGuildText, "")
+	support := env.srv.AddRole(env.guild.ID, "support", 0)
+	admin := env.srv.AddRole(env.guild.ID, "admin", 0)
+
+	settings, err := env.db.GetGuildSettings(env.guild.ID)
+	if err != nil {
+		t.Fatalf("get settings: %v", err)
+	}
+	settings.TicketEnabled = true
+	settings.TicketCategoryID = category.ID
+	settings.TicketSupportRoleID = support.ID
+	settings.TicketAdminRoleID = admin.ID
+	settings.TicketLogChannelID = logChannel.ID
+	if err := env.db.UpsertGuildSettings(settings); err != nil {
+		t.Fatalf("upsert settings: %v", err)
+	}
+
+	user := env.srv.AddUser("member", false)
+	env.srv.AddMember(env.guild.ID, user)
+
+	// パネルのボタンでモーダルが開く
+	i := env.srv.ComponentInteraction(env.guild.ID, env.channel.ID, user, nil, "ticket_create")
+	handler.HandleComponentInteraction(env.session, i)
+
+	record := env.srv.Interaction(i.ID)
+	if record.ResponseType != discordgo.InteractionResponseModal {
+		t.Fatalf("response type = %d, want modal", record.ResponseType)
+	}
+	var modal struct {
+		CustomID string `json:"custom_id"`
+	}
+	if err := json.Unmarshal(record.Data, &modal); err != nil || modal.CustomID != "ticket_create_modal" {
+		t.Fatalf("modal custom_id = %q (%v), want ticket_create_modal", modal.CustomID, err)
+	}
+
+	// モーダルを送信するとカテゴリー内にチケットチャンネルが作成される
+	i = env.srv.ModalSubmitInteraction(env.guild.ID, env.channel.ID, user, "ticket_create_modal", map[string]string{
+		"ticket_subject":     "ログインできない",
+		"ticket_description": "パスワードを変更してからログインできません",
+	})
+	handler.HandleModalSubmit(env.session, i)
+
+	var ticket *discordgo.Channel
+	for _, channel := range env.srv.Channels(env.guild.ID) {
+		if strings.HasPrefix(channel.Name, "ticket-") && channel.ID != logChannel.ID {
+			ticket = channel
+		}
+	}
+	if ticket == nil {
+		t.Fatal("ticket channel was not created")
+	}
+	if ticket.ParentID != category.ID {
+		t.Errorf("ticket parent = %s, want %s", ticket.ParentID, category.ID)
+	}
+
+	overwrites := make(map[string]*discordgo.PermissionOverwrite)
+	for _, overwrite := range ticket.PermissionOverwrites {
+		overwrites[overwrite.ID] = overwrite
+	}
+	if o := overwrites[env.guild.ID]; o == nil || o.Deny&discordgo.PermissionViewChannel == 0 {
+		t.Error("@everyone can view the ticket channel")
+	}
+	for _, id := range []string{user.ID, support.ID, admin.ID} {
+		if o := overwrites[id]; o == nil || o.Allow&discordgo.PermissionViewChannel == 0 {
+			t.Errorf("%s cannot view the ticket channel", id)
+		}
+	}
+
+	messages := env.srv.Messages(ticket.ID)
+	if len(messages) != 1 {
+		t.Fatalf("ticket messages = %d, want 1", len(messages))
+	}
+	if record := env.srv.Interaction(i.ID); record.Original == nil || !strings.Contains(record.Original.Content, ticket.ID) {
+		t.Error("ticket creation result does not mention the channel")
+	}
+
+	// 作成者は閉じるボタンから確認を経てチケットを閉じられる
+	i = env.srv.ComponentInteraction(env.guild.ID, ticket.ID, user, messages[0], "ticket_close_"+ticket.ID)
+	handler.HandleComponentInteraction(env.session, i)
+	if record := env.srv.Interaction(i.ID); !record.Ephemeral() || len(record.Response.Components) == 0 {
+		t.Fatal("close confirmation was not shown")
+	}
+
+	i = env.srv.ComponentInteraction(env.guild.ID, ticket.ID, user, nil, "ticket_close_confirm_"+ticket.ID)
+	handler.HandleComponentInteraction(env.session, i)
+
+	logs := env.srv.Messages(logChannel.ID)
+	if len(logs) != 1 || len(logs[0].Embeds) == 0 {
+		t.Fatalf("log messages = %d, want 1 embed", len(logs))
+	}
+	waitFor(t, 5*time.Second, func() bool {
+		return env.srv.Channel(ticket.ID) == nil
+	})
+}
+
+func TestTicketCloseRequiresPermission(t *testing.T) {
+	env := newTestEnv(t)
+	handler := NewInteractionHandler(env.session, &config.Config{}, env.db, nil, features.NewGate(env.db, env.log), env.log)
+
+	settings, err := env.db.GetGuildSettings(env.guild.ID)
+	if err != nil {
+		t.Fatalf("get settings: %v", err)
+	}
+	settings.TicketEnabled = true
+	settings.TicketSupportRoleID = env.srv.AddRole(env.guild.ID, "support", 0).ID
+	if err := env.db.UpsertGuildSettings(settings); err != nil {
+		t.Fatalf("upsert settings: %v", err)
+	}
+
+	ticket := env.srv.AddChannel(env.guild.ID, "ticket-1234", discordgo.ChannelTypeGuildText, "")
+	other := env.srv.AddUser("other", false)
+	env.srv.AddMember(env.guild.ID, other)
+
+	i := env.srv.ComponentInteraction(env.guild.ID, ticket.ID, other, nil, "ticket_close_"+ticket.ID)
+	handler.HandleComponentInteraction(env.session, i)
+
+	record := env.srv.Interaction(i.ID)
+	if !record.Ephemeral() || len(record.Response.Components) != 0 {
+		t.Fatal("close confirmation was shown to a member without permission")
+	}
+	if env.srv.Channel(ticket.ID) == nil {
+		t.Fatal("ticket channel was deleted")
+	}
+}
@@ -99,7 +99,7 @@ func (c *TranslateCommand) Execute(ctx *Context) error {
 	prompt := c.createTranslatePrompt(text, language)
 	
 	// Geminiで翻訳
-	aiCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	aiCtx, cancel := context.WithTimeout(ctx.Ctx(), 30*time.Second)
 	defer cancel()
 	
 	translation, err := c.geminiStudio.AskGemini(aiCtx, prompt, ctx.GetUser().ID)
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// 再起動せずに反映できる設定キー（前方一致）。これ以外の変更は再起動が必要です。
+var liveKeys = []string{
+	"bot.status_message",
+	"bot.activity_type",
+	"google_cloud.gemini_model",
+	"google_cloud.imagen_model",
+	"features.",
+}
+
+// Watcher は設定ファイルの変更を監視し、検証済みの新しい Config を購読者に通知します
+type Watcher struct {
+	log         *slog.Logger
+	mu          sync.RWMutex
+	current     *Config
+	subscribers []func(old, new *Config)
+}
+
+// Watch は設定ファイルの監視を開始します。環境変数から読み込んだ場合は監視しません。
+func Watch(initial *Config, logger *slog.Logger) *Watcher {
+	w := &Watcher{current: initial, log: logger}
+
+	if viper.ConfigFileUsed() == "" {
+		return w
+	}
+
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		w.reload()
+	})
+	viper.WatchConfig()
+
+	w.log.Info("Watching config file for changes", "path", viper.ConfigFileUsed())
+	return w
+}
+
+// Current は最新の検証済み設定を返します
+func (w *Watcher) Current() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Subscribe は設定が変更されたときに呼ばれる関数を登録します
+func (w *Watcher) Subscribe(fn func(old, new *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+func (w *Watcher) reload() {
+	var cfg Config
+	if err := viper.Unmarshal(&cfg); err != nil {
+		w.log.Warn("Ignoring config change, failed to parse", "error", err)
+		return
+	}
+	if err := validateConfig(&cfg); err != nil {
+		w.log.Warn("Ignoring invalid config change", "error", err)
+		return
+	}
+
+	w.mu.Lock()
+	old := w.current
+	changed := Diff(old, &cfg)
+	if len(changed) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	w.current = &cfg
+	subscribers := append([]func(old, new *Config){}, w.subscribers...)
+	w.mu.Unlock()
+
+	var live, restart []string
+	for _, key := range changed {
+		if RequiresRestart(key) {
+			restart = append(restart, key)
+		} else {
+			live = append(live, key)
+		}
+	}
+	if len(live) > 0 {
+		w.log.Info("Config reloaded", "keys", strings.Join(live, ", "))
+	}
+	if len(restart) > 0 {
+		w.log.Warn("These config changes require a restart to take effect", "keys", strings.Join(restart, ", "))
+	}
+
+	for _, fn := range subscribers {
+		fn(old, &cfg)
+	}
+}
+
+// RequiresRestart は設定キーの変更に再起動が必要かを返します
+func RequiresRestart(key string) bool {
+	for _, live := range liveKeys {
+		if key == live || (strings.HasSuffix(live, ".") && strings.HasPrefix(key, live)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff は値が異なる設定キー（例: "bot.status_message"）の一覧を返します
+func Diff(old, new *Config) []string {
+	var changed []string
+	diffStruct("", reflect.ValueOf(*old), reflect.ValueOf(*new), &changed)
+	return changed
+}
+
+func diffStruct(prefix string, a, b reflect.Value, changed *[]string) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		if prefix != "" {
+			key = fmt.Sprintf("%s.%s", prefix, key)
+		}
+
+		av, bv := a.Field(i), b.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			diffStruct(key, av, bv, changed)
+			continue
+		}
+		if !reflect.DeepEqual(av.Interface(), bv.Interface()) {
+			*changed = append(*changed, key)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSettingChanged は元に戻そうとした項目が、その後さらに変更されている場合のエラーです
+var ErrSettingChanged = errors.New("setting has been changed since")
+
+// 監査ログに記録しない内部用の項目
+var unauditedSettings = map[string]bool{
+	"guild_id":           true,
+	"bump_last_time":     true,
+	"bump_reminder_sent": true,
+	"settings_json":      true,
+	"created_at":         true,
+	"updated_at":         true,
+}
+
+// ConfigChange は設定の変更1項目分の監査ログです
+type ConfigChange struct {
+	ID        int64
+	GuildID   string
+	ActorID   string
+	Feature   string
+	Field     string // GuildSettings のJSONキー（例: "log_channel_id"）
+	OldValue  string
+	NewValue  string
+	ChangedAt time.Time
+}
+
+// SaveGuildSettings は設定を保存し、変更された項目を実行者とともに監査ログに記録します
+func (s *Service) SaveGuildSettings(settings *GuildSettings, actorID, feature string) error {
+	old, err := s.GetGuildSettings(settings.GuildID)
+	if err != nil {
+		// 読み込めない場合は既定値からの変更として記録する
+		old = &GuildSettings{GuildID: settings.GuildID}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	defer s.settings.invalidate(settings.GuildID)
+
+	if err := s.upsertGuildSettings(tx, settings); err != nil {
+		return err
+	}
+
+	for _, change := range diffGuildSettings(old, settings) {
+		_, err := tx.Exec(`
+			INSERT INTO config_audit (guild_id, actor_id, feature, field, old_value, new_value)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, settings.GuildID, actorID, feature, change.Field, change.OldValue, change.NewValue)
+		if err != nil {
+			return fmt.Errorf("failed to record config change: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetConfigHistory はギルドの設定変更履歴を新しい順に返します
+func (s *Service) GetConfigHistory(guildID string, limit, offset int) ([]ConfigChange, error) {
+	rows, err := s.db.Query(`
+		SELECT id, guild_id, actor_id, feature, field, old_value, new_value, changed_at
+		FROM config_audit
+		WHERE guild_id = ?
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, guildID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []ConfigChange
+	for rows.Next() {
+		var c ConfigChange
+		var oldValue, newValue sql.NullString
+		if err := rows.Scan(&c.ID, &c.GuildID, &c.ActorID, &c.Feature, &c.Field, &oldValue, &newValue, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		c.OldValue, c.NewValue = oldValue.String, newValue.String
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// CountConfigHistory はギルドの設定変更履歴の件数を返します
+func (s *Service) CountConfigHistory(guildID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM config_audit WHERE guild_id = ?`, guildID).Scan(&count)
+	return count, err
+}
+
+// RevertConfigChange は監査ログの1項目を変更前の値に戻し、その操作も監査ログに記録します。
+// 項目がその後さらに変更されている場合は ErrSettingChanged を返します。
+func (s *Service) RevertConfigChange(guildID string, changeID int64, actorID string) (*ConfigChange, error) {
+	var c ConfigChange
+	var oldValue, newValue sql.NullString
+	err := s.db.QueryRow(`
+		SELECT id, guild_id, actor_id, feature, field, old_value, new_value, changed_at
+		FROM config_audit
+		WHERE id = ? AND guild_id = ?
+	`, changeID, guildID).Scan(&c.ID, &c.GuildID, &c.ActorID, &c.Feature, &c.Field, &oldValue, &newValue, &c.ChangedAt)
+	if err != nil {
+		return nil, err
+	}
+	c.OldValue, c.NewValue = oldValue.String, newValue.String
+
+	settings, err := s.GetGuildSettings(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	field, ok := settingsField(settings, c.Field)
+	if !ok {
+		return nil, fmt.Errorf("unknown setting: %s", c.Field)
+	}
+	if formatSettingValue(field) != c.NewValue {
+		return nil, ErrSettingChanged
+	}
+	if err := parseSettingValue(field, c.OldValue); err != nil {
+		return nil, err
+	}
+
+	if err := s.SaveGuildSettings(settings, actorID, c.Feature); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// diffGuildSettings は値が異なる項目を求めます
+func diffGuildSettings(old, new *GuildSettings) []ConfigChange {
+	var changes []ConfigChange
+
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := settingKey(t.Field(i))
+		if unauditedSettings[key] {
+			continue
+		}
+
+		before, after := formatSettingValue(ov.Field(i)), formatSettingValue(nv.Field(i))
+		if before != after {
+			changes = append(changes, ConfigChange{Field: key, OldValue: before, NewValue: after})
+		}
+	}
+	return changes
+}
+
+func settingKey(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("json"), ",")[0]
+}
+
+// settingsField はJSONキーに対応するフィールドを返します
+func settingsField(settings *GuildSettings, key string) (reflect.Value, bool) {
+	v := reflect.ValueOf(settings).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if settingKey(t.Field(i)) == key && !unauditedSettings[key] {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func formatSettingValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func parseSettingValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported setting type: %s", v.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import "testing"
+
+func TestResetGuildSettingsRecordsChanges(t *testing.T) {
+	s := newTestService(t)
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	mustExec(t, s, `INSERT INTO guilds (id, name) VALUES ('1', 'g')`)
+
+	settings, err := s.GetGuildSettings("1")
+	if err != nil {
+		t.Fatalf("get settings: %v", err)
+	}
+	settings.LoggingEnabled = true
+	settings.LogChannelID = "10"
+	settings.WelcomeEnabled = true
+	if err := s.SaveGuildSettings(settings, "2", "logging"); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	if err := s.ResetGuildSettings("1", "logging", "3"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+
+	settings, err = s.GetGuildSettings("1")
+	if err != nil {
+		t.Fatalf("get settings: %v", err)
+	}
+	if settings.LoggingEnabled || settings.LogChannelID != "" {
+		t.Errorf("logging settings were not reset: %+v", settings)
+	}
+	// 他の機能の設定は変更しない
+	if !settings.WelcomeEnabled {
+		t.Error("welcome settings were reset")
+	}
+
+	history, err := s.GetConfigHistory("1", 10, 0)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	fields := map[string]bool{}
+	for _, change := range history {
+		if change.ActorID == "3" {
+			fields[change.Field] = true
+		}
+	}
+	if len(fields) != 2 || !fields["logging_enabled"] || !fields["log_channel_id"] {
+		t.Errorf("reset changes = %v, want logging_enabled and log_channel_id", fields)
+	}
+
+	if err := s.ResetGuildSettings("1", "unknown", "3"); err == nil {
+		t.Error("unknown feature should fail")
+	}
+}
@@ -0,0 +1,233 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupNamePattern は DefaultBackupPath のファイル名です
+var backupNamePattern = regexp.MustCompile(`^luna-(\d{8}-\d{6})\.db$`)
+
+// BackupFile はバックアップディレクトリ内のバックアップ1つ分です
+type BackupFile struct {
+	Name      string
+	Path      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// ListBackups は dir 内のバックアップを新しい順に返します（DefaultBackupPath の名前のファイルのみ）
+func ListBackups(dir string) ([]BackupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []BackupFile
+	for _, entry := range entries {
+		match := backupNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		createdAt, err := time.ParseInLocation("20060102-150405", match[1], time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupFile{
+			Name:      entry.Name(),
+			Path:      filepath.Join(dir, entry.Name()),
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// VerifyBackup はバックアップを読み取り専用で開き、PRAGMA integrity_check で検証します
+func VerifyBackup(path string) error {
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", path, err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed for %s: %s", path, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// PruneBackups は新しい順に keepDaily 日分の日次バックアップと keepWeekly 週分の週次バックアップを残し、
+// それ以外のバックアップを削除します。各日・各週で最も新しいものを残し、削除したファイルを返します。
+// 最新のバックアップは keepDaily と keepWeekly に関係なく残します。
+func PruneBackups(dir string, keepDaily, keepWeekly int) ([]string, error) {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := backupsToKeep(backups, keepDaily, keepWeekly)
+
+	var removed []string
+	for _, b := range backups {
+		if keep[b.Path] {
+			continue
+		}
+		if err := os.Remove(b.Path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, b.Path)
+	}
+	return removed, nil
+}
+
+// backupsToKeep は新しい順に並んだ backups のうち、残すもののパスを返します
+func backupsToKeep(backups []BackupFile, keepDaily, keepWeekly int) map[string]bool {
+	keep := make(map[string]bool)
+	if len(backups) > 0 {
+		keep[backups[0].Path] = true
+	}
+
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for _, b := range backups {
+		day := b.CreatedAt.Format("2006-01-02")
+		if !days[day] && len(days) < keepDaily {
+			days[day] = true
+			keep[b.Path] = true
+		}
+
+		year, week := b.CreatedAt.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%02d", year, week)
+		if !weeks[weekKey] && len(weeks) < keepWeekly {
+			weeks[weekKey] = true
+			keep[b.Path] = true
+		}
+	}
+	return keep
+}
+
+// restoreMarkerPath は次回起動時に復元するバックアップを記録するファイルです
+func restoreMarkerPath(dbPath string) string {
+	return dbPath + ".restore"
+}
+
+// ScheduleRestore はバックアップを検証し、次回起動時にデータベースと入れ替えるよう記録します。
+// 実行中のデータベースは置き換えず、ApplyPendingRestore が接続前に入れ替えます。
+func ScheduleRestore(dbPath, backupPath string) error {
+	abs, err := filepath.Abs(backupPath)
+	if err != nil {
+		return err
+	}
+	if err := VerifyBackup(abs); err != nil {
+		return err
+	}
+	return os.WriteFile(restoreMarkerPath(dbPath), []byte(abs+"\n"), 0600)
+}
+
+// PendingRestore は次回起動時に復元が予定されているバックアップを返します（ない場合は空）
+func PendingRestore(dbPath string) string {
+	data, err := os.ReadFile(restoreMarkerPath(dbPath))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// ApplyPendingRestore は予定された復元があれば、接続前にデータベースファイルを入れ替えます。
+// 現在のファイル（WALを含む）は <path>.pre-restore-YYYYMMDD-HHMMSS として残します。
+// 検証に失敗した場合は入れ替えず、記録を .restore.failed に変更してエラーを返します。
+func ApplyPendingRestore(dbPath string) (string, error) {
+	backupPath := PendingRestore(dbPath)
+	if backupPath == "" {
+		return "", nil
+	}
+	marker := restoreMarkerPath(dbPath)
+
+	if err := VerifyBackup(backupPath); err != nil {
+		os.Rename(marker, marker+".failed")
+		return backupPath, err
+	}
+
+	// 同じディレクトリにコピーしてから置き換え、途中で失敗しても元のファイルを壊さない
+	tmp := dbPath + ".restoring"
+	if err := copyFile(backupPath, tmp); err != nil {
+		os.Remove(tmp)
+		return backupPath, fmt.Errorf("failed to copy backup: %w", err)
+	}
+
+	aside := dbPath + ".pre-restore-" + time.Now().Format("20060102-150405")
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Rename(dbPath+suffix, aside+suffix); err != nil && !os.IsNotExist(err) {
+			os.Remove(tmp)
+			return backupPath, fmt.Errorf("failed to move current database aside: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmp, dbPath); err != nil {
+		// 元のファイルを戻す
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			os.Rename(aside+suffix, dbPath+suffix)
+		}
+		return backupPath, fmt.Errorf("failed to restore database: %w", err)
+	}
+	os.Remove(marker)
+	return backupPath, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
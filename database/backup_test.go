@@ -0,0 +1,87 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestBackupsToKeep(t *testing.T) {
+	at := func(s string) BackupFile {
+		createdAt, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return BackupFile{Path: s, CreatedAt: createdAt}
+	}
+	// 2025-06-02 は月曜日（ISO週の始まり）
+	backups := []BackupFile{
+		at("2025-06-10 12:00"),
+		at("2025-06-10 06:00"),
+		at("2025-06-09 06:00"),
+		at("2025-06-08 06:00"),
+		at("2025-06-02 06:00"),
+		at("2025-05-25 06:00"),
+		at("2025-05-18 06:00"),
+	}
+
+	tests := []struct {
+		name       string
+		keepDaily  int
+		keepWeekly int
+		want       []string
+	}{
+		{"newest per day", 3, 0, []string{"2025-06-08 06:00", "2025-06-09 06:00", "2025-06-10 12:00"}},
+		{"newest per week", 1, 3, []string{"2025-05-25 06:00", "2025-06-08 06:00", "2025-06-10 12:00"}},
+		{"weeks beyond days", 2, 4, []string{"2025-05-18 06:00", "2025-05-25 06:00", "2025-06-08 06:00", "2025-06-09 06:00", "2025-06-10 12:00"}},
+		{"nothing configured keeps newest", 0, 0, []string{"2025-06-10 12:00"}},
+		{"negative keeps newest", -1, -1, []string{"2025-06-10 12:00"}},
+		{"more than available", 30, 30, []string{
+			"2025-05-18 06:00", "2025-05-25 06:00", "2025-06-02 06:00", "2025-06-08 06:00", "2025-06-09 06:00", "2025-06-10 12:00",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep := backupsToKeep(backups, tt.keepDaily, tt.keepWeekly)
+			var got []string
+			for path := range keep {
+				got = append(got, path)
+			}
+			sort.Strings(got)
+			if len(got) != len(tt.want) {
+				t.Fatalf("kept %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("kept %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneBackupsKeepsNewest(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for _, path := range []string{DefaultBackupPath(dir, now), DefaultBackupPath(dir, now.Add(-48*time.Hour))} {
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := PruneBackups(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if len(removed) != 1 {
+		t.Errorf("removed %v, want only the older backup", removed)
+	}
+	if _, err := os.Stat(DefaultBackupPath(dir, now)); err != nil {
+		t.Errorf("newest backup was removed: %v", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "luna-*.db")); len(matches) != 1 {
+		t.Errorf("backups left = %v, want 1", matches)
+	}
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sharedSettingsTTL は複数のインスタンスで共有するデータベース（PostgreSQL）でのキャッシュの有効期間です。
+// 他のインスタンスが書き込んだ設定は、この時間が経つと反映されます。
+const sharedSettingsTTL = 30 * time.Second
+
+// CacheStats はギルド設定キャッシュの累計値です
+type CacheStats struct {
+	Hits    int64
+	Misses  int64
+	Entries int
+}
+
+// settingsCache は GuildSettings の読み込みキャッシュです。
+// 設定はこの Service を通してのみ書き込まれるため、書き込み時に該当ギルドを破棄します。
+// ttl が0でない場合は、他のプロセスによる書き込みに備えて一定時間で読み込み直します。
+type settingsCache struct {
+	mu      sync.RWMutex
+	entries map[string]cachedSettings
+	ttl     time.Duration
+	// generation は破棄のたびに増え、読み込み中に書き込まれた古い値を保存しないために使う
+	generation uint64
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type cachedSettings struct {
+	settings *GuildSettings
+	loadedAt time.Time
+}
+
+func newSettingsCache(ttl time.Duration) *settingsCache {
+	return &settingsCache{entries: make(map[string]cachedSettings), ttl: ttl}
+}
+
+// get はキャッシュされた設定のコピーと、キャッシュがない場合の世代を返します
+func (c *settingsCache) get(guildID string) (*GuildSettings, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if entry, ok := c.entries[guildID]; ok && (c.ttl == 0 || time.Since(entry.loadedAt) < c.ttl) {
+		c.hits.Add(1)
+		return copySettings(entry.settings), 0
+	}
+	c.misses.Add(1)
+	return nil, c.generation
+}
+
+// put は読み込み開始後に破棄がなかった場合のみ設定を保存します
+func (c *settingsCache) put(settings *GuildSettings, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+	c.entries[settings.GuildID] = cachedSettings{settings: copySettings(settings), loadedAt: time.Now()}
+}
+
+func (c *settingsCache) invalidate(guildID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, guildID)
+	c.generation++
+}
+
+func (c *settingsCache) stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: len(c.entries),
+	}
+}
+
+// copySettings は呼び出し元が変更してもキャッシュに影響しないようにコピーします
+func copySettings(settings *GuildSettings) *GuildSettings {
+	copied := *settings
+	if settings.BumpLastTime != nil {
+		t := *settings.BumpLastTime
+		copied.BumpLastTime = &t
+	}
+	return &copied
+}
+
+// prefixCache はギルドのプレフィックス（guilds.prefix）の読み込みキャッシュです。
+// プレフィックスコマンドの判定のためにメッセージごとに参照されるため、UpsertGuild とギルドの削除で破棄します。
+type prefixCache struct {
+	mu         sync.RWMutex
+	entries    map[string]cachedPrefix
+	ttl        time.Duration
+	generation uint64
+}
+
+type cachedPrefix struct {
+	prefix   string
+	loadedAt time.Time
+}
+
+func newPrefixCache(ttl time.Duration) *prefixCache {
+	return &prefixCache{entries: make(map[string]cachedPrefix), ttl: ttl}
+}
+
+// get はキャッシュされたプレフィックスと、キャッシュがない場合の世代を返します
+func (c *prefixCache) get(guildID string) (string, bool, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if entry, ok := c.entries[guildID]; ok && (c.ttl == 0 || time.Since(entry.loadedAt) < c.ttl) {
+		return entry.prefix, true, 0
+	}
+	return "", false, c.generation
+}
+
+// put は読み込み開始後に破棄がなかった場合のみプレフィックスを保存します
+func (c *prefixCache) put(guildID, prefix string, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+	c.entries[guildID] = cachedPrefix{prefix: prefix, loadedAt: time.Now()}
+}
+
+func (c *prefixCache) invalidate(guildID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, guildID)
+	c.generation++
+}
+
+// SettingsCacheStats はギルド設定キャッシュのヒット数・ミス数を返します
+func (s *Service) SettingsCacheStats() CacheStats {
+	return s.settings.stats()
+}
@@ -0,0 +1,33 @@
+package database
+
+import "testing"
+
+func TestGuildPrefixCache(t *testing.T) {
+	s := newTestService(t)
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	if prefix, err := s.GetGuildPrefix("1"); err != nil || prefix != "/" {
+		t.Fatalf("prefix of unknown guild = %q (%v), want /", prefix, err)
+	}
+	if err := s.UpsertGuild("1", "g", "!"); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if prefix, _ := s.GetGuildPrefix("1"); prefix != "!" {
+		t.Fatalf("prefix = %q, want !", prefix)
+	}
+
+	// 2回目以降はキャッシュから返す
+	mustExec(t, s, `UPDATE guilds SET prefix = '?' WHERE id = '1'`)
+	if prefix, _ := s.GetGuildPrefix("1"); prefix != "!" {
+		t.Errorf("prefix = %q, want the cached !", prefix)
+	}
+
+	if err := s.UpsertGuild("1", "g", "$"); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if prefix, _ := s.GetGuildPrefix("1"); prefix != "$" {
+		t.Errorf("prefix after update = %q, want $", prefix)
+	}
+}
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// copyTables はコピーするテーブルです（外部キーの参照先から順に並べる）
+var copyTables = []string{
+	"guilds",
+	"users",
+	"user_settings",
+	"guild_settings",
+	"guild_modules",
+	"command_usage",
+	"command_usage_daily",
+	"bracket_usage",
+	"config_audit",
+	"guild_removals",
+	"lockdown_schedules",
+	"tickets",
+	"ticket_messages",
+}
+
+// CopyResult はテーブル1つ分のコピー結果です
+type CopyResult struct {
+	Table    string
+	Rows     int  // コピーした行数（既にあった行を除く）
+	Orphaned int  // 参照先の行がないためコピーしなかった行数
+	Skipped  bool // コピー元またはコピー先にテーブルがない
+}
+
+// OpenSQLiteReadOnly は既存の SQLite データベースを読み取り専用で開きます（luna db copy のコピー元）
+func OpenSQLiteReadOnly(path string) (*DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return &DB{db: db, dialect: sqliteDialect{}}, nil
+}
+
+// CopyFrom は src の全データをこのデータベースにコピーします。
+// コピー先のテーブルは作成済み（マイグレーション済み）である必要があり、主キーが重複する行はコピーしません。
+// 列は名前で対応付け、コピー先にない列は無視します。
+// SQLite では外部キーが有効になっていない接続で書き込まれた行があり得るため、参照先のない行はコピーせずに数えます。
+func (s *Service) CopyFrom(src *DB) ([]CopyResult, error) {
+	var results []CopyResult
+	for _, table := range copyTables {
+		result, err := copyTable(src, s.db, table)
+		if err != nil {
+			return results, fmt.Errorf("failed to copy %s: %w", table, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func copyTable(src, dst *DB, table string) (CopyResult, error) {
+	result := CopyResult{Table: table}
+
+	for _, db := range []*DB{src, dst} {
+		var count int
+		if err := db.QueryRow(db.dialect.tableExistsQuery(), table).Scan(&count); err != nil {
+			return result, err
+		}
+		if count == 0 {
+			result.Skipped = true
+			return result, nil
+		}
+	}
+
+	srcColumns, err := columnTypes(src, table)
+	if err != nil {
+		return result, err
+	}
+	dstColumns, err := columnTypes(dst, table)
+	if err != nil {
+		return result, err
+	}
+
+	var columns []string
+	var types []string
+	for _, column := range dstColumns {
+		if _, ok := srcColumns[column.name]; ok {
+			columns = append(columns, column.name)
+			types = append(types, column.typ)
+		}
+	}
+	if len(columns) == 0 {
+		result.Skipped = true
+		return result, nil
+	}
+
+	rows, err := src.Query(fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), table))
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	tx, err := dst.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING",
+		table, strings.Join(columns, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return result, err
+		}
+		for i, value := range values {
+			values[i] = convertValue(value, types[i])
+		}
+		n, err := insertRow(tx, insert, values)
+		if err != nil {
+			if !dst.dialect.isForeignKeyViolation(err) {
+				return result, err
+			}
+			result.Orphaned++
+			continue
+		}
+		result.Rows += n
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	if query := dst.dialect.resetSequenceQuery(table); query != "" && hasColumn(columns, "id") {
+		if _, err := tx.Exec(query); err != nil {
+			return result, err
+		}
+	}
+
+	return result, tx.Commit()
+}
+
+// insertRow は1行を挿入します。
+// PostgreSQL ではエラーになった文があるとトランザクション全体が失敗するため、セーブポイントで1行分だけ取り消します。
+func insertRow(tx *Tx, insert string, values []interface{}) (int, error) {
+	if _, err := tx.Exec("SAVEPOINT copy_row"); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(insert, values...)
+	if err != nil {
+		if _, rollbackErr := tx.Exec("ROLLBACK TO SAVEPOINT copy_row"); rollbackErr != nil {
+			return 0, errors.Join(err, rollbackErr)
+		}
+		return 0, err
+	}
+	if _, err := tx.Exec("RELEASE SAVEPOINT copy_row"); err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, nil
+	}
+	return int(n), nil
+}
+
+type columnType struct {
+	name string
+	typ  string
+}
+
+// columnTypes はテーブルの列名と型名を返します
+func columnTypes(db *DB, table string) (map[string]columnType, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]columnType, len(types))
+	for _, t := range types {
+		columns[t.Name()] = columnType{name: t.Name(), typ: strings.ToUpper(t.DatabaseTypeName())}
+	}
+	return columns, nil
+}
+
+// convertValue は SQLite の値をコピー先の列の型に合わせます（SQLite の真偽値は整数で保存されている場合がある）
+func convertValue(value interface{}, typ string) interface{} {
+	switch typ {
+	case "BOOL", "BOOLEAN":
+		switch v := value.(type) {
+		case int64:
+			return v != 0
+		case string:
+			return v == "1" || strings.EqualFold(v, "true")
+		}
+	}
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value
+}
+
+func hasColumn(columns []string, name string) bool {
+	for _, column := range columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
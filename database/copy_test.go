@@ -0,0 +1,53 @@
+package database
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/Sumire-Labs/Luna/config"
+)
+
+func TestCopyFromSkipsOrphanedRows(t *testing.T) {
+	// コピー元は外部キーが有効でない接続で書き込まれ、参照先のない行を含む
+	src := newTestService(t)
+	if err := src.Migrate(); err != nil {
+		t.Fatalf("migrate source: %v", err)
+	}
+	mustExec(t, src,
+		`INSERT INTO guilds (id, name) VALUES ('1', 'g')`,
+		`INSERT INTO guild_settings (guild_id) VALUES ('1')`,
+		`INSERT INTO guild_settings (guild_id) VALUES ('404')`,
+	)
+
+	// コピー先は外部キーを有効にした接続を使い続ける
+	db, err := Connect(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "copy.db"), MaxConnections: 2})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	dst := NewService(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { dst.Close() })
+	if err := dst.Migrate(); err != nil {
+		t.Fatalf("migrate destination: %v", err)
+	}
+
+	results, err := dst.CopyFrom(src.db)
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+
+	byTable := make(map[string]CopyResult, len(results))
+	for _, r := range results {
+		byTable[r.Table] = r
+	}
+	if r := byTable["guilds"]; r.Rows != 1 || r.Orphaned != 0 {
+		t.Errorf("guilds = %+v, want 1 row", r)
+	}
+	if r := byTable["guild_settings"]; r.Rows != 1 || r.Orphaned != 1 {
+		t.Errorf("guild_settings = %+v, want 1 row and 1 orphan", r)
+	}
+	if n := count(t, dst, `SELECT COUNT(*) FROM guild_settings WHERE guild_id = '1'`); n != 1 {
+		t.Errorf("guild_settings for guild 1 = %d, want 1", n)
+	}
+}
@@ -6,11 +6,37 @@ import (
 	"os"
 	"path/filepath"
 
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "modernc.org/sqlite"
 	"github.com/Sumire-Labs/Luna/config"
 )
 
-func Connect(cfg config.DatabaseConfig) (*sql.DB, error) {
+// DB は *sql.DB に接続先のデータベースの種類を組み合わせたものです。
+// クエリは ? のプレースホルダーで書き、PostgreSQL では実行時に $1, $2, ... に変換します。
+type DB struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// Tx は DB のトランザクションです
+type Tx struct {
+	tx      *sql.Tx
+	dialect dialect
+}
+
+// execer は DB と Tx の共通部分です
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func Connect(cfg config.DatabaseConfig) (*DB, error) {
+	if cfg.Driver == DriverPostgres {
+		return connectPostgres(cfg)
+	}
+	return connectSQLite(cfg)
+}
+
+func connectSQLite(cfg config.DatabaseConfig) (*DB, error) {
 	dir := filepath.Dir(cfg.Path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
@@ -36,5 +62,72 @@ func Connect(cfg config.DatabaseConfig) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to set journal mode: %w", err)
 	}
 
-	return db, nil
-}
\ No newline at end of file
+	return &DB{db: db, dialect: sqliteDialect{}}, nil
+}
+
+// connectPostgres は PostgreSQL に接続します。複数のボットインスタンスで同じデータベースを共有できます。
+func connectPostgres(cfg config.DatabaseConfig) (*DB, error) {
+	db, err := sql.Open("pgx", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db.SetMaxOpenConns(cfg.MaxConnections)
+	db.SetMaxIdleConns(cfg.MaxConnections / 2)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+
+	return &DB{db: db, dialect: postgresDialect{}}, nil
+}
+
+// Driver は接続先のデータベースの種類（sqlite または postgres）を返します
+func (d *DB) Driver() string {
+	return d.dialect.driver()
+}
+
+func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.db.Exec(d.dialect.rebind(query), args...)
+}
+
+func (d *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.Query(d.dialect.rebind(query), args...)
+}
+
+func (d *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.db.QueryRow(d.dialect.rebind(query), args...)
+}
+
+func (d *DB) Begin() (*Tx, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, dialect: d.dialect}, nil
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.Exec(t.dialect.rebind(query), args...)
+}
+
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.Query(t.dialect.rebind(query), args...)
+}
+
+func (t *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRow(t.dialect.rebind(query), args...)
+}
+
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
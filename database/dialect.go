@@ -0,0 +1,113 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// 対応しているデータベース（DatabaseConfig.Driver）
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// dialect はデータベースの種類ごとのSQLの違いです。
+// クエリは SQLite の書き方（? のプレースホルダー）で書き、実行時に rebind で変換します。
+type dialect interface {
+	driver() string
+	// rebind はプレースホルダーをデータベースの書き方に変換します
+	rebind(query string) string
+	// tableExistsQuery はテーブル名を1つ受け取り、存在する場合に1を返すクエリです
+	tableExistsQuery() string
+	// columnExistsQuery はテーブル名と列名を受け取り、列が存在する場合に1を返すクエリです
+	columnExistsQuery() string
+	// bumpDueCondition は前回のBumpから2時間以上経ったギルドの条件です
+	bumpDueCondition() string
+	// noLimit は LIMIT に渡す「制限なし」の値です
+	noLimit() interface{}
+	// dayExpr は日時の列を日付（YYYY-MM-DD の文字列）に変換する式です
+	dayExpr(column string) string
+	// resetSequenceQuery は id を指定して行をコピーした後に、連番を最大値に合わせるクエリです（不要な場合は空）
+	resetSequenceQuery(table string) string
+	// isForeignKeyViolation はエラーが外部キー制約違反（参照先の行がない）かどうかを返します
+	isForeignKeyViolation(err error) bool
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) driver() string             { return DriverSQLite }
+func (sqliteDialect) rebind(query string) string { return query }
+func (sqliteDialect) tableExistsQuery() string {
+	return `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+}
+func (sqliteDialect) columnExistsQuery() string {
+	return `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+}
+func (sqliteDialect) bumpDueCondition() string {
+	return `datetime(bump_last_time, '+2 hours') <= datetime('now')`
+}
+func (sqliteDialect) noLimit() interface{} { return -1 }
+func (sqliteDialect) dayExpr(column string) string { return "date(" + column + ")" }
+
+// SQLite の AUTOINCREMENT は挿入された最大の id から続くため不要
+func (sqliteDialect) resetSequenceQuery(table string) string { return "" }
+
+func (sqliteDialect) isForeignKeyViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) driver() string { return DriverPostgres }
+
+// rebind は ? を $1, $2, ... に置き換えます（文字列リテラル内の ? はそのまま）
+func (postgresDialect) rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+
+	n := 0
+	inString := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+		case r == '?' && !inString:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+func (postgresDialect) tableExistsQuery() string {
+	return `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`
+}
+func (postgresDialect) columnExistsQuery() string {
+	return `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`
+}
+func (postgresDialect) bumpDueCondition() string {
+	return `bump_last_time + INTERVAL '2 hours' <= CURRENT_TIMESTAMP`
+}
+func (postgresDialect) noLimit() interface{} { return nil }
+func (postgresDialect) dayExpr(column string) string {
+	return "to_char(" + column + ", 'YYYY-MM-DD')"
+}
+
+// BIGSERIAL の連番は id を指定した挿入では進まないため、コピー後に最大値へ合わせる
+func (postgresDialect) resetSequenceQuery(table string) string {
+	return fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%s', 'id'), MAX(id)) FROM %s`, table, table)
+}
+
+// 23503 は foreign_key_violation
+func (postgresDialect) isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestPostgresRebind(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"no placeholders", `SELECT 1`, `SELECT 1`},
+		{"numbered in order", `UPDATE guilds SET name = ? WHERE id = ?`, `UPDATE guilds SET name = $1 WHERE id = $2`},
+		{"question mark in literal", `SELECT '?' FROM guilds WHERE id = ?`, `SELECT '?' FROM guilds WHERE id = $1`},
+		{"escaped quote in literal", `SELECT 'it''s ?' WHERE a = ? AND b = ?`, `SELECT 'it''s ?' WHERE a = $1 AND b = $2`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (postgresDialect{}).rebind(tt.query); got != tt.want {
+				t.Errorf("rebind(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgresForeignKeyViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"foreign key", &pgconn.PgError{Code: "23503"}, true},
+		{"wrapped", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23503"}), true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"other error", errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (postgresDialect{}).isForeignKeyViolation(tt.err); got != tt.want {
+				t.Errorf("isForeignKeyViolation = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// /lockdown の自動解除の種類
+const (
+	LockdownUnlock   = "unlock"
+	LockdownUnfreeze = "unfreeze"
+)
+
+// LockdownSchedule は /lockdown の自動解除の予定です。
+// 再起動をまたいでもロックや凍結が残り続けないように、解除する時刻と元に戻す内容を保存します。
+type LockdownSchedule struct {
+	ID          int64
+	GuildID     string
+	ChannelID   string   // 解除の結果を送信するチャンネル（コマンドを実行したチャンネル）
+	Action      string   // LockdownUnlock または LockdownUnfreeze
+	TargetIDs   []string // LockdownUnlock: ロックしたチャンネル
+	Permissions int64    // LockdownUnfreeze: 凍結前の @everyone の権限
+	UnlockAt    time.Time
+}
+
+// AddLockdownSchedule は自動解除の予定を保存し、schedule.ID を設定します
+func (s *Service) AddLockdownSchedule(schedule *LockdownSchedule) error {
+	return s.db.QueryRow(`
+		INSERT INTO lockdown_schedules (guild_id, channel_id, action, target_ids, permissions, unlock_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, schedule.GuildID, schedule.ChannelID, schedule.Action, strings.Join(schedule.TargetIDs, ","),
+		schedule.Permissions, schedule.UnlockAt.UTC()).Scan(&schedule.ID)
+}
+
+// GetLockdownSchedules はすべての自動解除の予定を解除時刻の順に返します
+func (s *Service) GetLockdownSchedules() ([]*LockdownSchedule, error) {
+	rows, err := s.db.Query(`
+		SELECT id, guild_id, channel_id, action, target_ids, permissions, unlock_at
+		FROM lockdown_schedules
+		ORDER BY unlock_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*LockdownSchedule
+	for rows.Next() {
+		schedule := &LockdownSchedule{}
+		var targets string
+		if err := rows.Scan(&schedule.ID, &schedule.GuildID, &schedule.ChannelID, &schedule.Action,
+			&targets, &schedule.Permissions, &schedule.UnlockAt); err != nil {
+			return nil, err
+		}
+		if targets != "" {
+			schedule.TargetIDs = strings.Split(targets, ",")
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+// TakeLockdownSchedule は予定を削除して返します。手動の解除で既に削除されている場合は nil を返します。
+func (s *Service) TakeLockdownSchedule(id int64) (*LockdownSchedule, error) {
+	schedules, err := s.GetLockdownSchedules()
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range schedules {
+		if schedule.ID != id {
+			continue
+		}
+		result, err := s.db.Exec(`DELETE FROM lockdown_schedules WHERE id = ?`, id)
+		if err != nil {
+			return nil, err
+		}
+		// 同時に手動で解除された場合は、どちらか一方だけが解除する
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return nil, err
+		}
+		return schedule, nil
+	}
+	return nil, nil
+}
+
+// RemoveLockdownTargets は手動でロックを解除したチャンネルを自動解除の予定から外します。
+// 対象のチャンネルがなくなった予定は削除します（後で再びロックしたチャンネルを古い予定が解除しないように）。
+func (s *Service) RemoveLockdownTargets(guildID string, channelIDs []string) error {
+	unlocked := make(map[string]bool, len(channelIDs))
+	for _, id := range channelIDs {
+		unlocked[id] = true
+	}
+
+	schedules, err := s.GetLockdownSchedules()
+	if err != nil {
+		return err
+	}
+	for _, schedule := range schedules {
+		if schedule.GuildID != guildID || schedule.Action != LockdownUnlock {
+			continue
+		}
+		var remaining []string
+		for _, id := range schedule.TargetIDs {
+			if !unlocked[id] {
+				remaining = append(remaining, id)
+			}
+		}
+		switch {
+		case len(remaining) == len(schedule.TargetIDs):
+			continue
+		case len(remaining) == 0:
+			_, err = s.db.Exec(`DELETE FROM lockdown_schedules WHERE id = ?`, schedule.ID)
+		default:
+			_, err = s.db.Exec(`UPDATE lockdown_schedules SET target_ids = ? WHERE id = ?`, strings.Join(remaining, ","), schedule.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CancelLockdownSchedules は手動で解除されたギルドの、同じ種類の自動解除の予定を削除します
+func (s *Service) CancelLockdownSchedules(guildID, action string) error {
+	_, err := s.db.Exec(`DELETE FROM lockdown_schedules WHERE guild_id = ? AND action = ?`, guildID, action)
+	return err
+}
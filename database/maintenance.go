@@ -0,0 +1,66 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Backup はデータベースの一貫したコピーを path に作成します。
+// VACUUM INTO はWAL中のデータも含めてコピーするため、ボットの実行中でも使用できます。
+// PostgreSQL では pg_dump を使用してください。
+func (s *Service) Backup(path string) error {
+	if s.db.Driver() != DriverSQLite {
+		return fmt.Errorf("backup is only supported for SQLite; use pg_dump for PostgreSQL")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file already exists: %s", path)
+	}
+
+	if _, err := s.db.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// DefaultBackupPath はバックアップのファイル名（luna-YYYYMMDD-HHMMSS.db）を dir 内に作成します
+func DefaultBackupPath(dir string, now time.Time) string {
+	return filepath.Join(dir, "luna-"+now.Format("20060102-150405")+".db")
+}
+
+// GuildExport はギルドに関するデータのエクスポートです
+type GuildExport struct {
+	GuildID    string          `json:"guild_id"`
+	Name       string          `json:"name"`
+	Prefix     string          `json:"prefix"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Settings   *GuildSettings  `json:"settings"`
+	Modules    map[string]bool `json:"modules"`
+	Brackets   []BracketStats  `json:"bracket_usage"`
+}
+
+// ExportGuild はギルドの設定・モジュール設定・集計データをまとめて返します
+func (s *Service) ExportGuild(guildID string) (*GuildExport, error) {
+	export := &GuildExport{GuildID: guildID, ExportedAt: time.Now()}
+
+	err := s.db.QueryRow(`SELECT name, prefix FROM guilds WHERE id = ?`, guildID).Scan(&export.Name, &export.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("guild %s not found: %w", guildID, err)
+	}
+
+	if export.Settings, err = s.GetGuildSettings(guildID); err != nil {
+		return nil, err
+	}
+	if export.Modules, err = s.GetGuildModules(guildID); err != nil {
+		return nil, err
+	}
+	if export.Brackets, err = s.GetBracketRanking(guildID, -1); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
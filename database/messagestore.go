@@ -0,0 +1,431 @@
+package database
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// messageStoreFlushInterval はメッセージをまとめて書き込む間隔です
+const messageStoreFlushInterval = 2 * time.Second
+
+// StoredMessage は編集・削除ログで元の内容を表示するために保存するメッセージです
+type StoredMessage struct {
+	ID          string
+	GuildID     string
+	ChannelID   string
+	AuthorID    string
+	AuthorName  string
+	Content     string
+	Attachments []string // 添付ファイル名
+	Embeds      int
+	CreatedAt   time.Time
+}
+
+// MessageArchive はログ機能が保存しているメッセージです。
+// /privacy とギルドのデータ削除では、データベースの行と一緒にエクスポート・削除します。
+type MessageArchive interface {
+	ExportAuthor(userID string) ([]*StoredMessage, error)
+	DeleteAuthor(userID string) (int64, error)
+	DeleteGuild(guildID string) (int64, error)
+}
+
+// SetMessageArchive はユーザー・ギルドのデータと一緒に扱うメッセージを登録します（ログ機能の初期化時に呼び出す）
+func (s *Service) SetMessageArchive(archive MessageArchive) {
+	s.messages = archive
+}
+
+// messagePayload は data 列に保存する内容です（暗号化する場合はこの JSON を暗号化する）
+type messagePayload struct {
+	AuthorName  string   `json:"author_name"`
+	Content     string   `json:"content"`
+	Attachments []string `json:"attachments,omitempty"`
+	Embeds      int      `json:"embeds,omitempty"`
+}
+
+// MessageStore はメッセージを本体とは別の SQLite ファイルに保存します。
+// メッセージの作成ごとに書き込まないよう、一定間隔でまとめて書き込みます。
+// 鍵を指定した場合、作成者名・内容・添付ファイル名は AES-256-GCM で暗号化して保存します
+// （削除やエクスポートに使うギルド・チャンネル・作成者のIDは暗号化しません）。
+type MessageStore struct {
+	db   *sql.DB
+	aead cipher.AEAD
+	log  *slog.Logger
+
+	mu      sync.Mutex
+	pending map[string]*StoredMessage
+	// flushMu は書き込みと削除を直列にします。書き込み中の行を削除すると、
+	// 書き込みの完了後（または失敗して戻した後）に削除した行が復活するためです。
+	flushMu sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// OpenMessageStore は path の SQLite ファイルを開きます（存在しない場合は作成）。key が nil の場合は暗号化しません。
+func OpenMessageStore(path string, key []byte, logger *slog.Logger) (*MessageStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create message store directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open message store: %w", err)
+	}
+	// 書き込みは1つの接続に揃える（SQLite は同時に1つしか書き込めない）
+	db.SetMaxOpenConns(1)
+
+	for _, query := range []string{
+		`PRAGMA journal_mode = WAL`,
+		`PRAGMA synchronous = NORMAL`,
+		`CREATE TABLE IF NOT EXISTS messages (
+			id TEXT PRIMARY KEY,
+			guild_id TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			author_id TEXT NOT NULL,
+			data BLOB NOT NULL,
+			created_at INTEGER NOT NULL
+		) WITHOUT ROWID`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_guild_created ON messages(guild_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_author ON messages(author_id)`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize message store: %w", err)
+		}
+	}
+
+	store := &MessageStore{
+		db:      db,
+		log:     logger,
+		pending: make(map[string]*StoredMessage),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if key != nil {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("invalid message store key: %w", err)
+		}
+		if store.aead, err = cipher.NewGCM(block); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// Start は定期的な書き込みを開始します
+func (m *MessageStore) Start() {
+	go m.run()
+}
+
+func (m *MessageStore) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(messageStoreFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-m.stop:
+			return
+		}
+		if err := m.Flush(); err != nil {
+			m.log.Error("Failed to flush message store", "error", err)
+		}
+	}
+}
+
+// Put はメッセージの保存を予約します（同じIDは上書き）
+func (m *MessageStore) Put(msg *StoredMessage) {
+	copied := *msg
+	m.mu.Lock()
+	m.pending[msg.ID] = &copied
+	m.mu.Unlock()
+}
+
+// Get は保存されているメッセージを返します（ない場合は nil）
+func (m *MessageStore) Get(id string) (*StoredMessage, error) {
+	m.mu.Lock()
+	if msg, ok := m.pending[id]; ok {
+		copied := *msg
+		m.mu.Unlock()
+		return &copied, nil
+	}
+	m.mu.Unlock()
+
+	msg := &StoredMessage{ID: id}
+	var data []byte
+	var createdAt int64
+	err := m.db.QueryRow(`SELECT guild_id, channel_id, author_id, data, created_at FROM messages WHERE id = ?`, id).
+		Scan(&msg.GuildID, &msg.ChannelID, &msg.AuthorID, &data, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := m.decode(msg, data); err != nil {
+		return nil, fmt.Errorf("failed to decode message %s: %w", id, err)
+	}
+	msg.CreatedAt = time.Unix(createdAt, 0)
+	return msg, nil
+}
+
+// Delete はメッセージを削除します
+func (m *MessageStore) Delete(id string) error {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+
+	m.mu.Lock()
+	delete(m.pending, id)
+	m.mu.Unlock()
+
+	_, err := m.db.Exec(`DELETE FROM messages WHERE id = ?`, id)
+	return err
+}
+
+// Flush は予約されたメッセージを1つのトランザクションで書き込みます。
+// 失敗した場合は次回の書き込みで再試行します。
+func (m *MessageStore) Flush() error {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+
+	m.mu.Lock()
+	pending := m.pending
+	if len(pending) == 0 {
+		m.mu.Unlock()
+		return nil
+	}
+	m.pending = make(map[string]*StoredMessage)
+	m.mu.Unlock()
+
+	if err := m.write(pending); err != nil {
+		m.requeue(pending)
+		return err
+	}
+	return nil
+}
+
+func (m *MessageStore) write(pending map[string]*StoredMessage) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO messages (id, guild_id, channel_id, author_id, data, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET data = excluded.data
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, msg := range pending {
+		data, err := m.encode(msg)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(msg.ID, msg.GuildID, msg.ChannelID, msg.AuthorID, data, msg.CreatedAt.Unix()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// requeue は書き込めなかったメッセージを戻します。その間に予約された値を優先します。
+func (m *MessageStore) requeue(pending map[string]*StoredMessage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, msg := range pending {
+		if _, ok := m.pending[id]; !ok {
+			m.pending[id] = msg
+		}
+	}
+}
+
+// Prune は保存期間を過ぎたメッセージを削除し、削除した件数を返します。
+// retentionDays はギルドごとの保存日数を返します。
+func (m *MessageStore) Prune(now time.Time, retentionDays func(guildID string) int) (int64, error) {
+	if err := m.Flush(); err != nil {
+		return 0, err
+	}
+
+	rows, err := m.db.Query(`SELECT DISTINCT guild_id FROM messages`)
+	if err != nil {
+		return 0, err
+	}
+	var guildIDs []string
+	for rows.Next() {
+		var guildID string
+		if err := rows.Scan(&guildID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		guildIDs = append(guildIDs, guildID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, guildID := range guildIDs {
+		cutoff := now.AddDate(0, 0, -retentionDays(guildID))
+		result, err := m.db.Exec(`DELETE FROM messages WHERE guild_id = ? AND created_at < ?`, guildID, cutoff.Unix())
+		if err != nil {
+			return total, err
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			total += n
+		}
+	}
+	return total, nil
+}
+
+// ExportAuthor はユーザーが作成したメッセージを返します（/privacy のエクスポート用）。
+// 鍵を変更する前に保存したものなど、復号できない行は内容を読めないため含めません（DeleteAuthor では削除されます）。
+func (m *MessageStore) ExportAuthor(userID string) ([]*StoredMessage, error) {
+	if err := m.Flush(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(`
+		SELECT id, guild_id, channel_id, author_id, data, created_at
+		FROM messages
+		WHERE author_id = ?
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*StoredMessage
+	skipped := 0
+	for rows.Next() {
+		msg := &StoredMessage{}
+		var data []byte
+		var createdAt int64
+		if err := rows.Scan(&msg.ID, &msg.GuildID, &msg.ChannelID, &msg.AuthorID, &data, &createdAt); err != nil {
+			return nil, err
+		}
+		if err := m.decode(msg, data); err != nil {
+			skipped++
+			continue
+		}
+		msg.CreatedAt = time.Unix(createdAt, 0)
+		messages = append(messages, msg)
+	}
+	if skipped > 0 {
+		m.log.Warn("Skipped undecodable messages in export", "author_id", userID, "skipped", skipped)
+	}
+	return messages, rows.Err()
+}
+
+// DeleteAuthor はユーザーが作成したメッセージを削除し、削除した件数を返します
+func (m *MessageStore) DeleteAuthor(userID string) (int64, error) {
+	return m.deleteWhere(func(msg *StoredMessage) bool { return msg.AuthorID == userID },
+		`DELETE FROM messages WHERE author_id = ?`, userID)
+}
+
+// DeleteGuild はギルドのメッセージを削除し、削除した件数を返します
+func (m *MessageStore) DeleteGuild(guildID string) (int64, error) {
+	return m.deleteWhere(func(msg *StoredMessage) bool { return msg.GuildID == guildID },
+		`DELETE FROM messages WHERE guild_id = ?`, guildID)
+}
+
+func (m *MessageStore) deleteWhere(match func(msg *StoredMessage) bool, query string, args ...interface{}) (int64, error) {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+
+	var removed int64
+	m.mu.Lock()
+	for id, msg := range m.pending {
+		if match(msg) {
+			delete(m.pending, id)
+			removed++
+		}
+	}
+	m.mu.Unlock()
+
+	result, err := m.db.Exec(query, args...)
+	if err != nil {
+		return removed, err
+	}
+	n, err := result.RowsAffected()
+	return removed + n, err
+}
+
+func (m *MessageStore) encode(msg *StoredMessage) ([]byte, error) {
+	data, err := json.Marshal(messagePayload{
+		AuthorName:  msg.AuthorName,
+		Content:     msg.Content,
+		Attachments: msg.Attachments,
+		Embeds:      msg.Embeds,
+	})
+	if err != nil || m.aead == nil {
+		return data, err
+	}
+
+	// nonce を先頭に付けて保存する。メッセージIDを追加データにし、行の入れ替えを検出する
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return m.aead.Seal(nonce, nonce, data, []byte(msg.ID)), nil
+}
+
+func (m *MessageStore) decode(msg *StoredMessage, data []byte) error {
+	if m.aead != nil {
+		size := m.aead.NonceSize()
+		if len(data) < size {
+			return fmt.Errorf("encrypted data too short")
+		}
+		plain, err := m.aead.Open(nil, data[:size], data[size:], []byte(msg.ID))
+		if err != nil {
+			return err
+		}
+		data = plain
+	}
+
+	var payload messagePayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	msg.AuthorName = payload.AuthorName
+	msg.Content = payload.Content
+	msg.Attachments = payload.Attachments
+	msg.Embeds = payload.Embeds
+	return nil
+}
+
+// Close は定期的な書き込みを止め、残りを書き込んでからファイルを閉じます
+func (m *MessageStore) Close(ctx context.Context) error {
+	close(m.stop)
+	select {
+	case <-m.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := m.Flush(); err != nil {
+		m.log.Error("Failed to flush message store", "error", err)
+	}
+	return m.db.Close()
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestMessageStore(t *testing.T, path string, key []byte) *MessageStore {
+	t.Helper()
+
+	store, err := OpenMessageStore(path, key, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("open message store: %v", err)
+	}
+	store.Start()
+	return store
+}
+
+func TestMessageStoreExportSkipsUndecodableRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.db")
+
+	store := openTestMessageStore(t, path, bytes.Repeat([]byte{1}, 32))
+	store.Put(&StoredMessage{ID: "1", GuildID: "1", ChannelID: "2", AuthorID: "3", Content: "old", CreatedAt: time.Now()})
+	if err := store.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// 鍵を変更すると以前の行は復号できない
+	store = openTestMessageStore(t, path, bytes.Repeat([]byte{2}, 32))
+	defer store.Close(context.Background())
+	store.Put(&StoredMessage{ID: "2", GuildID: "1", ChannelID: "2", AuthorID: "3", Content: "new", CreatedAt: time.Now()})
+
+	messages, err := store.ExportAuthor("3")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Content != "new" {
+		t.Fatalf("exported %d messages, want only the decodable one", len(messages))
+	}
+
+	n, err := store.DeleteAuthor("3")
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("deleted %d messages, want 2", n)
+	}
+}
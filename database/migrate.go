@@ -0,0 +1,279 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var createTablePattern = regexp.MustCompile(`(?i)^\s*CREATE TABLE IF NOT EXISTS (\w+)`)
+
+// Schema はモジュールのテーブル定義をデータベースの種類ごとに持ちます。
+// SQLite・Postgres には起動のたびに実行できる冪等な文（CREATE ... IF NOT EXISTS）だけを書き、
+// 既存のデータを変更する文は Upgrades に書いて一度だけ実行します。
+type Schema struct {
+	SQLite   []string
+	Postgres []string
+	// Columns は既存のテーブルに後から追加した列です（CREATE TABLE にも同じ定義を含める）
+	Columns []Column
+	// Upgrades は Version の順に一度だけ実行するマイグレーションです
+	Upgrades []Upgrade
+}
+
+// Column は ALTER TABLE ... ADD COLUMN で追加する列です
+type Column struct {
+	Table      string
+	Name       string
+	Definition string
+}
+
+// Upgrade はテーブルの作り直しなど、繰り返すとデータを失うマイグレーションです。
+// 実行したバージョンは schema_migrations に記録し、二度目以降は実行しません。
+type Upgrade struct {
+	Version     int
+	Description string
+	// Needed は古い構造が残っているかを返します。false の場合は文を実行せずに適用済みとして記録します。
+	Needed   func(s *Service) (bool, error)
+	SQLite   []string
+	Postgres []string
+}
+
+// SchemaStatus は luna migrate status で表示する未適用の内容です
+type SchemaStatus struct {
+	MissingTables   []string
+	MissingColumns  []string // table.column
+	PendingUpgrades []string // 未実行で、データを変更する Upgrade の説明
+}
+
+// UpToDate は適用する内容がないかを返します
+func (st *SchemaStatus) UpToDate() bool {
+	return len(st.MissingTables) == 0 && len(st.MissingColumns) == 0 && len(st.PendingUpgrades) == 0
+}
+
+// For は driver（DatabaseConfig.Driver）用のマイグレーションを返します
+func (s Schema) For(driver string) []string {
+	if driver == DriverPostgres {
+		return s.Postgres
+	}
+	return s.SQLite
+}
+
+// For は driver 用の文を返します（空の場合はそのデータベースでは不要）
+func (u Upgrade) For(driver string) []string {
+	if driver == DriverPostgres {
+		return u.Postgres
+	}
+	return u.SQLite
+}
+
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	module TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	description TEXT,
+	applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (module, version)
+)`
+
+// Migrate はコアのテーブルを作成します
+func (s *Service) Migrate() error {
+	if err := s.MigrateModule("core", CoreSchema); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	return nil
+}
+
+// MigrateModule は未実行の Upgrades を実行してから、テーブルと後から追加した列を作成します。
+// Upgrades を先に実行するのは、古い構造のテーブルに新しい列のインデックスを作成できないためです。
+func (s *Service) MigrateModule(name string, schema Schema) error {
+	if _, err := s.db.Exec(createSchemaMigrations); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied, err := s.appliedUpgrades(name)
+	if err != nil {
+		return err
+	}
+	for _, upgrade := range schema.Upgrades {
+		if applied[upgrade.Version] {
+			continue
+		}
+		if err := s.applyUpgrade(name, upgrade); err != nil {
+			return fmt.Errorf("upgrade %d of module %s failed: %w", upgrade.Version, name, err)
+		}
+	}
+
+	for _, migration := range schema.For(s.db.Driver()) {
+		if _, err := s.db.Exec(migration); err != nil {
+			return fmt.Errorf("migration for module %s failed: %w", name, err)
+		}
+	}
+
+	// CREATE TABLE IF NOT EXISTS は既存のテーブルを変更しないため、後から追加した列はここで追加する
+	for _, c := range schema.Columns {
+		exists, err := s.columnExists(c.Table, c.Name)
+		if err != nil {
+			return fmt.Errorf("failed to check column %s.%s: %w", c.Table, c.Name, err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := s.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, c.Table, c.Name, c.Definition)); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", c.Table, c.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// applyUpgrade は Upgrade を実行して記録します。記録を先に挿入するため、
+// 別のプロセスが同時に実行した場合は主キーの重複で失敗し、文は二重に実行されません。
+func (s *Service) applyUpgrade(module string, upgrade Upgrade) error {
+	statements := upgrade.For(s.db.Driver())
+	needed := false
+	if len(statements) > 0 && upgrade.Needed != nil {
+		var err error
+		if needed, err = upgrade.Needed(s); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (module, version, description) VALUES (?, ?, ?)`,
+		module, upgrade.Version, upgrade.Description); err != nil {
+		return err
+	}
+	if needed {
+		// テーブルを作り直す間は参照元の外部キーが一時的に切れるため、確認をコミット時まで遅らせる
+		if s.db.Driver() == DriverSQLite {
+			if _, err := tx.Exec(`PRAGMA defer_foreign_keys = ON`); err != nil {
+				return err
+			}
+		}
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+		s.log.Warn("Applied schema upgrade", "module", module, "version", upgrade.Version, "description", upgrade.Description)
+	}
+	return tx.Commit()
+}
+
+// appliedUpgrades は記録済みの Upgrade のバージョンを返します
+func (s *Service) appliedUpgrades(module string) (map[int]bool, error) {
+	rows, err := s.db.Query(`SELECT version FROM schema_migrations WHERE module = ?`, module)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// SchemaStatus はデータベースを変更せずに、MigrateModule で適用される内容を返します
+func (s *Service) SchemaStatus(name string, schema Schema) (*SchemaStatus, error) {
+	status := &SchemaStatus{}
+
+	for _, migration := range schema.For(s.db.Driver()) {
+		match := createTablePattern.FindStringSubmatch(migration)
+		if match == nil {
+			continue
+		}
+		exists, err := s.tableExists(match[1])
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			status.MissingTables = append(status.MissingTables, match[1])
+		}
+	}
+
+	for _, c := range schema.Columns {
+		exists, err := s.tableExists(c.Table)
+		if err != nil {
+			return nil, err
+		}
+		// テーブルごと作成される場合は列も含まれる
+		if !exists {
+			continue
+		}
+		if exists, err = s.columnExists(c.Table, c.Name); err != nil {
+			return nil, err
+		}
+		if !exists {
+			status.MissingColumns = append(status.MissingColumns, c.Table+"."+c.Name)
+		}
+	}
+
+	recorded, err := s.tableExists("schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int]bool{}
+	if recorded {
+		if applied, err = s.appliedUpgrades(name); err != nil {
+			return nil, err
+		}
+	}
+	for _, upgrade := range schema.Upgrades {
+		if applied[upgrade.Version] || len(upgrade.For(s.db.Driver())) == 0 || upgrade.Needed == nil {
+			continue
+		}
+		needed, err := upgrade.Needed(s)
+		if err != nil {
+			return nil, err
+		}
+		if needed {
+			status.PendingUpgrades = append(status.PendingUpgrades, fmt.Sprintf("%d: %s", upgrade.Version, upgrade.Description))
+		}
+	}
+
+	return status, nil
+}
+
+func (s *Service) columnExists(table, column string) (bool, error) {
+	var count int
+	if err := s.db.QueryRow(s.db.dialect.columnExistsQuery(), table, column).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// hasLegacyColumn は table が存在し、column の有無が古い構造と一致するかを返します
+// （present が true なら column がある場合、false ならない場合が古い構造）
+func (s *Service) hasLegacyColumn(table, column string, present bool) (bool, error) {
+	exists, err := s.tableExists(table)
+	if err != nil || !exists {
+		return false, err
+	}
+	found, err := s.columnExists(table, column)
+	if err != nil {
+		return false, err
+	}
+	return found == present, nil
+}
+
+// missingPrimaryKey は SQLite のテーブルが存在し、主キーを持たないかを返します
+func (s *Service) missingPrimaryKey(table string) (bool, error) {
+	exists, err := s.tableExists(table)
+	if err != nil || !exists {
+		return false, err
+	}
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE pk > 0`, table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/Sumire-Labs/Luna/config"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+
+	db, err := Connect(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "luna.db"), MaxConnections: 1})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	s := NewService(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func mustExec(t *testing.T, s *Service, queries ...string) {
+	t.Helper()
+	for _, query := range queries {
+		if _, err := s.db.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+}
+
+func count(t *testing.T, s *Service, query string) int {
+	t.Helper()
+	var n int
+	if err := s.db.QueryRow(query).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func TestMigrateKeepsDataOnRestart(t *testing.T) {
+	s := newTestService(t)
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := s.MigrateModule("tickets", TicketSchema); err != nil {
+		t.Fatalf("migrate tickets: %v", err)
+	}
+
+	mustExec(t, s,
+		`INSERT INTO guilds (id, name) VALUES ('1', 'g')`,
+		`INSERT INTO users (id, username) VALUES ('2', 'u')`,
+		`INSERT INTO bracket_usage (guild_id, user_id, total_pairs) VALUES ('1', '2', 5)`,
+		`INSERT INTO tickets (guild_id, channel_id, creator_id, title) VALUES ('1', '3', '2', 't')`,
+	)
+
+	// 2回目以降の起動でテーブルが作り直されないこと
+	for i := 0; i < 2; i++ {
+		if err := s.Migrate(); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		if err := s.MigrateModule("tickets", TicketSchema); err != nil {
+			t.Fatalf("migrate tickets: %v", err)
+		}
+	}
+
+	if n := count(t, s, `SELECT COUNT(*) FROM bracket_usage`); n != 1 {
+		t.Errorf("bracket_usage rows = %d, want 1", n)
+	}
+	if n := count(t, s, `SELECT COUNT(*) FROM tickets`); n != 1 {
+		t.Errorf("tickets rows = %d, want 1", n)
+	}
+	if n := count(t, s, `SELECT COUNT(*) FROM schema_migrations`); n != 2 {
+		t.Errorf("schema_migrations rows = %d, want 2", n)
+	}
+}
+
+func TestMigrateUpgradesLegacyTables(t *testing.T) {
+	s := newTestService(t)
+
+	// 以前の構造: 集計列のない bracket_usage と、priority 列が残り主キーのない tickets
+	mustExec(t, s,
+		`CREATE TABLE guilds (id TEXT PRIMARY KEY, name TEXT NOT NULL, prefix TEXT DEFAULT '/', language TEXT DEFAULT 'en',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP, updated_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE TABLE guild_settings (guild_id TEXT PRIMARY KEY)`,
+		`CREATE TABLE bracket_usage (guild_id TEXT, user_id TEXT, count INTEGER)`,
+		`INSERT INTO bracket_usage VALUES ('1', '2', 3)`,
+		`CREATE TABLE tickets (id INTEGER, guild_id TEXT, channel_id TEXT, creator_id TEXT, assigned_id TEXT,
+			category TEXT, title TEXT, description TEXT, status TEXT, priority TEXT,
+			created_at DATETIME, closed_at DATETIME, updated_at DATETIME)`,
+		`INSERT INTO tickets (id, guild_id, channel_id, creator_id, title, status, priority) VALUES (7, '1', '3', '2', 't', 'open', 'high')`,
+		`CREATE TABLE ticket_messages (id INTEGER PRIMARY KEY AUTOINCREMENT, ticket_id INTEGER NOT NULL, user_id TEXT NOT NULL,
+			message_id TEXT NOT NULL, FOREIGN KEY (ticket_id) REFERENCES tickets(id))`,
+	)
+
+	status, err := s.SchemaStatus("core", CoreSchema)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(status.MissingColumns) != 1 || status.MissingColumns[0] != "guild_settings.log_message_retention_days" {
+		t.Errorf("missing columns = %v", status.MissingColumns)
+	}
+	if len(status.PendingUpgrades) != 1 {
+		t.Errorf("pending upgrades = %v, want the bracket_usage rebuild", status.PendingUpgrades)
+	}
+
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := s.MigrateModule("tickets", TicketSchema); err != nil {
+		t.Fatalf("migrate tickets: %v", err)
+	}
+
+	if ok, _ := s.columnExists("bracket_usage", "total_pairs"); !ok {
+		t.Error("bracket_usage was not recreated")
+	}
+	if ok, _ := s.columnExists("tickets", "priority"); ok {
+		t.Error("tickets.priority was not removed")
+	}
+	if missing, _ := s.missingPrimaryKey("tickets"); missing {
+		t.Error("tickets has no primary key")
+	}
+	if n := count(t, s, `SELECT COUNT(*) FROM tickets WHERE id = 7 AND title = 't'`); n != 1 {
+		t.Error("ticket rows were not kept")
+	}
+	mustExec(t, s, `INSERT INTO ticket_messages (ticket_id, user_id, message_id) VALUES (7, '2', '4')`)
+
+	status, err = s.SchemaStatus("core", CoreSchema)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !status.UpToDate() {
+		t.Errorf("status after migrate = %+v, want up to date", status)
+	}
+}
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeletedUserID は削除されたユーザーの行を引き継ぐ匿名ユーザーです。
+// チケットや集計など、ギルド側に残す必要のあるデータはこのユーザーに付け替えます。
+const DeletedUserID = "0"
+
+// UserExport はユーザーIDに結びついたデータのエクスポートです（テーブル名 → 行）
+type UserExport struct {
+	UserID     string                              `json:"user_id"`
+	ExportedAt time.Time                           `json:"exported_at"`
+	Tables     map[string][]map[string]interface{} `json:"tables"`
+}
+
+// userDataQueries はユーザーのデータを含むテーブルと、その行を取得するクエリです。
+// ? にはすべてユーザーIDを渡します。チケットのテーブルはモジュールが無効な場合は存在しません。
+var userDataQueries = []struct {
+	table string
+	query string
+}{
+	{"users", `SELECT * FROM users WHERE id = ?`},
+	{"user_settings", `SELECT * FROM user_settings WHERE user_id = ?`},
+	{"command_usage", `SELECT * FROM command_usage WHERE user_id = ? ORDER BY id`},
+	{"bracket_usage", `SELECT * FROM bracket_usage WHERE user_id = ? ORDER BY guild_id`},
+	{"config_audit", `SELECT * FROM config_audit WHERE actor_id = ? ORDER BY id`},
+	{"tickets", `SELECT * FROM tickets WHERE creator_id = ? OR assigned_id = ? ORDER BY id`},
+	{"ticket_messages", `SELECT * FROM ticket_messages WHERE user_id = ? ORDER BY id`},
+}
+
+// ExportUser はユーザーIDに結びついたすべてのテーブルの行を返します
+func (s *Service) ExportUser(userID string) (*UserExport, error) {
+	export := &UserExport{
+		UserID:     userID,
+		ExportedAt: time.Now(),
+		Tables:     make(map[string][]map[string]interface{}),
+	}
+
+	for _, q := range userDataQueries {
+		exists, err := s.tableExists(q.table)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+
+		args := make([]interface{}, countPlaceholders(q.query))
+		for i := range args {
+			args[i] = userID
+		}
+		rows, err := s.queryMaps(q.query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", q.table, err)
+		}
+		export.Tables[q.table] = rows
+	}
+
+	if s.messages != nil {
+		messages, err := s.messages.ExportAuthor(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export cached messages: %w", err)
+		}
+		rows := []map[string]interface{}{}
+		for _, msg := range messages {
+			rows = append(rows, map[string]interface{}{
+				"id":          msg.ID,
+				"guild_id":    msg.GuildID,
+				"channel_id":  msg.ChannelID,
+				"author_name": msg.AuthorName,
+				"content":     msg.Content,
+				"attachments": msg.Attachments,
+				"embeds":      msg.Embeds,
+				"created_at":  msg.CreatedAt,
+			})
+		}
+		export.Tables[cachedMessagesTable] = rows
+	}
+	return export, nil
+}
+
+// cachedMessagesTable はログ機能が保存しているメッセージのエクスポート・削除結果での名前です
+const cachedMessagesTable = "cached_messages"
+
+// DeleteUser はユーザーのデータを1つのトランザクションで削除・匿名化し、テーブルごとの件数を返します。
+//   - users, user_settings: 削除
+//   - command_usage: 日別の集計に加えてから削除（/activity の集計は変わらない）
+//   - bracket_usage: 匿名ユーザーの行に合算してから削除（ランキングからは外れる）
+//   - tickets, ticket_messages: 匿名ユーザーに付け替え、本人が書いた内容を消去
+//   - config_audit: 変更者を匿名ユーザーに付け替え
+//   - ログ機能が保存しているメッセージ: 削除（別のファイルのため、トランザクションの後に削除）
+//
+// 書き込みバッファに残っている分は、呼び出し前に Flush しておく必要があります。
+func (s *Service) DeleteUser(userID string) (map[string]int64, error) {
+	if userID == DeletedUserID {
+		return nil, fmt.Errorf("cannot delete the anonymous user")
+	}
+
+	hasTickets, err := s.tableExists("tickets")
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO users (id, username, bot) VALUES (?, 'Deleted User', ?)
+		ON CONFLICT(id) DO NOTHING
+	`, DeletedUserID, false); err != nil {
+		return nil, fmt.Errorf("failed to create anonymous user: %w", err)
+	}
+
+	anon := DeletedUserID
+	steps := []erasureStep{
+		{table: "command_usage", args: []interface{}{userID}, query: s.rollupCommandUsageQuery("user_id = ?")},
+		{table: "command_usage", count: true, args: []interface{}{userID}, query: `DELETE FROM command_usage WHERE user_id = ?`},
+		{table: "bracket_usage", args: []interface{}{anon, userID}, query: `
+			INSERT INTO bracket_usage (guild_id, user_id, half_width_pairs, full_width_pairs, total_pairs)
+			SELECT guild_id, CAST(? AS TEXT), half_width_pairs, full_width_pairs, total_pairs
+			FROM bracket_usage
+			WHERE user_id = ?
+			ON CONFLICT(guild_id, user_id) DO UPDATE SET
+				half_width_pairs = bracket_usage.half_width_pairs + excluded.half_width_pairs,
+				full_width_pairs = bracket_usage.full_width_pairs + excluded.full_width_pairs,
+				total_pairs = bracket_usage.total_pairs + excluded.total_pairs
+		`},
+		{table: "bracket_usage", count: true, args: []interface{}{userID}, query: `DELETE FROM bracket_usage WHERE user_id = ?`},
+		{table: "config_audit", count: true, args: []interface{}{anon, userID}, query: `UPDATE config_audit SET actor_id = ? WHERE actor_id = ?`},
+	}
+	if hasTickets {
+		steps = append(steps,
+			erasureStep{table: "tickets", count: true, args: []interface{}{anon, userID}, query: `UPDATE tickets SET title = '[deleted]', description = NULL, creator_id = ? WHERE creator_id = ?`},
+			erasureStep{table: "tickets", count: true, args: []interface{}{userID}, query: `UPDATE tickets SET assigned_id = NULL WHERE assigned_id = ?`},
+			erasureStep{table: "ticket_messages", count: true, args: []interface{}{anon, userID}, query: `UPDATE ticket_messages SET content = NULL, attachments_json = NULL, user_id = ? WHERE user_id = ?`},
+		)
+	}
+	steps = append(steps,
+		erasureStep{table: "user_settings", count: true, args: []interface{}{userID}, query: `DELETE FROM user_settings WHERE user_id = ?`},
+		erasureStep{table: "users", count: true, args: []interface{}{userID}, query: `DELETE FROM users WHERE id = ?`},
+	)
+
+	counts := make(map[string]int64)
+	for _, step := range steps {
+		result, err := tx.Exec(step.query, step.args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to erase %s: %w", step.table, err)
+		}
+		if !step.count {
+			continue
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			counts[step.table] += n
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if s.messages != nil {
+		n, err := s.messages.DeleteAuthor(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to erase cached messages: %w", err)
+		}
+		counts[cachedMessagesTable] = n
+	}
+	return counts, nil
+}
+
+// erasureStep は DeleteUser の1つの処理です（count は結果の件数に含めるかどうか）
+type erasureStep struct {
+	table string
+	query string
+	args  []interface{}
+	count bool
+}
+
+func (s *Service) tableExists(table string) (bool, error) {
+	var count int
+	if err := s.db.QueryRow(s.db.dialect.tableExistsQuery(), table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// queryMaps は結果の各行を列名 → 値のマップで返します
+func (s *Service) queryMaps(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[column] = values[i]
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func countPlaceholders(query string) int {
+	count := 0
+	for _, r := range query {
+		if r == '?' {
+			count++
+		}
+	}
+	return count
+}
+
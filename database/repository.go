@@ -0,0 +1,52 @@
+package database
+
+import "time"
+
+// 保存先ごとのリポジトリです。Service が SQLite と PostgreSQL（DatabaseConfig.Driver）の両方で実装します。
+// 機能側は必要なリポジトリだけを受け取るようにしてください。
+// チケット機能は現在テーブル定義（TicketSchema）のみを持ち、クエリはありません。
+
+// GuildSettingsRepository はギルド・ギルド設定・モジュール設定・設定変更履歴の保存先です
+type GuildSettingsRepository interface {
+	UpsertGuild(id, name, prefix string) error
+	GetGuildPrefix(guildID string) (string, error)
+
+	GetGuildSettings(guildID string) (*GuildSettings, error)
+	SaveGuildSettings(settings *GuildSettings, actorID, feature string) error
+	UpsertGuildSettings(settings *GuildSettings) error
+	ResetGuildSettings(guildID, feature, actorID string) error
+
+	GetGuildModules(guildID string) (map[string]bool, error)
+	SetGuildModule(guildID, module string, enabled bool) error
+
+	GetConfigHistory(guildID string, limit, offset int) ([]ConfigChange, error)
+	CountConfigHistory(guildID string) (int, error)
+	RevertConfigChange(guildID string, changeID int64, actorID string) (*ConfigChange, error)
+
+	UpdateBumpTime(guildID string) error
+	MarkBumpReminderSent(guildID string) error
+	GetBumpableGuilds() ([]*GuildSettings, error)
+
+	MarkGuildRemoved(guildID string) error
+	CancelGuildRemoval(guildID string) error
+}
+
+// UsageRepository はユーザー情報とコマンドの利用履歴の保存先です
+type UsageRepository interface {
+	UpsertUser(id, username, discriminator, avatar string, isBot bool) error
+	LogCommand(guildID, userID, command, args string, success bool, errorMsg string) error
+	GetCommandStats(guildID string, since time.Time) (map[string]int, error)
+}
+
+// CounterRepository はかっこの使用数などの集計の保存先です
+type CounterRepository interface {
+	UpdateBracketUsage(guildID, userID string, halfWidthPairs, fullWidthPairs int) error
+	GetBracketRanking(guildID string, limit int) ([]BracketStats, error)
+	GetUserBracketStats(guildID, userID string) (*BracketStats, error)
+}
+
+var (
+	_ GuildSettingsRepository = (*Service)(nil)
+	_ UsageRepository         = (*Service)(nil)
+	_ CounterRepository       = (*Service)(nil)
+)
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// dayFormat は command_usage_daily の day 列の形式です（UTC の日付）
+const dayFormat = "2006-01-02"
+
+// RetentionCutoff は days 日より前のデータを削除する境界を返します。
+// 日別の集計と重ならないよう、UTC の日付の境目に揃えます。
+func RetentionCutoff(now time.Time, days int) time.Time {
+	today := now.UTC().Truncate(24 * time.Hour)
+	return today.AddDate(0, 0, -days)
+}
+
+// RollupCommandUsage は before より前のコマンド履歴をギルド・コマンド・日ごとの件数に集計し、元の行を削除します。
+// 集計はユーザーを含まないため、/activity の集計は保持期間を過ぎても変わりません。削除した行数を返します。
+func (s *Service) RollupCommandUsage(before time.Time) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(s.rollupCommandUsageQuery("executed_at < ?"), before.UTC()); err != nil {
+		return 0, fmt.Errorf("failed to roll up command usage: %w", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM command_usage WHERE executed_at < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete command usage: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// rollupCommandUsageQuery は condition に一致するコマンド履歴を command_usage_daily に加算するクエリです
+func (s *Service) rollupCommandUsageQuery(condition string) string {
+	return fmt.Sprintf(`
+		INSERT INTO command_usage_daily (guild_id, command, day, uses, failures)
+		SELECT COALESCE(guild_id, ''), command, %s, COUNT(*), SUM(CASE WHEN success THEN 0 ELSE 1 END)
+		FROM command_usage
+		WHERE %s
+		GROUP BY 1, 2, 3
+		ON CONFLICT(guild_id, command, day) DO UPDATE SET
+			uses = command_usage_daily.uses + excluded.uses,
+			failures = command_usage_daily.failures + excluded.failures
+	`, s.db.dialect.dayExpr("executed_at"), condition)
+}
+
+// PruneConfigAudit は before より前の設定変更履歴を削除し、削除した行数を返します
+func (s *Service) PruneConfigAudit(before time.Time) (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM config_audit WHERE changed_at < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune config audit: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// MarkGuildRemoved はボットがギルドから削除された日時を記録します（既に記録されている場合はそのまま）。
+// 保持期間を過ぎると PurgeRemovedGuilds がギルドのデータを削除します。
+func (s *Service) MarkGuildRemoved(guildID string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO guild_removals (guild_id) VALUES (?)
+		ON CONFLICT(guild_id) DO NOTHING
+	`, guildID)
+	return err
+}
+
+// CancelGuildRemoval はギルドに再び追加された場合に、予定されていた削除を取り消します
+func (s *Service) CancelGuildRemoval(guildID string) error {
+	_, err := s.db.Exec(`DELETE FROM guild_removals WHERE guild_id = ?`, guildID)
+	return err
+}
+
+// guildDataTables はギルドのデータを削除する順（外部キーの参照元から）です。
+// チケットのテーブルはモジュールが無効な場合は存在しないため、存在する場合のみ削除します。
+var guildDataTables = []struct {
+	table string
+	query string
+}{
+	{"ticket_messages", `DELETE FROM ticket_messages WHERE ticket_id IN (SELECT id FROM tickets WHERE guild_id = ?)`},
+	{"tickets", `DELETE FROM tickets WHERE guild_id = ?`},
+	{"command_usage", `DELETE FROM command_usage WHERE guild_id = ?`},
+	{"command_usage_daily", `DELETE FROM command_usage_daily WHERE guild_id = ?`},
+	{"bracket_usage", `DELETE FROM bracket_usage WHERE guild_id = ?`},
+	{"config_audit", `DELETE FROM config_audit WHERE guild_id = ?`},
+	{"lockdown_schedules", `DELETE FROM lockdown_schedules WHERE guild_id = ?`},
+	{"guild_modules", `DELETE FROM guild_modules WHERE guild_id = ?`},
+	{"guild_settings", `DELETE FROM guild_settings WHERE guild_id = ?`},
+	{"guilds", `DELETE FROM guilds WHERE id = ?`},
+	{"guild_removals", `DELETE FROM guild_removals WHERE guild_id = ?`},
+}
+
+// PurgeRemovedGuilds は before より前にボットが削除されたギルドのデータ（ログ機能が保存しているメッセージを含む）をすべて削除し、
+// 削除した行数を返します。ユーザー情報はギルドに属さないため残します。
+func (s *Service) PurgeRemovedGuilds(before time.Time) (int64, error) {
+	rows, err := s.db.Query(`SELECT guild_id FROM guild_removals WHERE removed_at < ?`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	var guildIDs []string
+	for rows.Next() {
+		var guildID string
+		if err := rows.Scan(&guildID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		guildIDs = append(guildIDs, guildID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, guildID := range guildIDs {
+		removed, err := s.purgeGuild(guildID)
+		if err != nil {
+			return total, fmt.Errorf("failed to purge guild %s: %w", guildID, err)
+		}
+		total += removed
+	}
+	return total, nil
+}
+
+func (s *Service) purgeGuild(guildID string) (int64, error) {
+	// テーブルの確認はトランザクションの外で行う（接続数が1の場合に待ち続けないように）
+	existing := make(map[string]bool)
+	for _, t := range guildDataTables {
+		exists, err := s.tableExists(t.table)
+		if err != nil {
+			return 0, err
+		}
+		existing[t.table] = exists
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	defer s.settings.invalidate(guildID)
+	defer s.prefixes.invalidate(guildID)
+
+	var total int64
+	for _, t := range guildDataTables {
+		if !existing[t.table] {
+			continue
+		}
+		result, err := tx.Exec(t.query, guildID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete %s: %w", t.table, err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			total += n
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if s.messages != nil {
+		n, err := s.messages.DeleteGuild(guildID)
+		if err != nil {
+			return total, fmt.Errorf("failed to delete cached messages: %w", err)
+		}
+		total += n
+	}
+	return total, nil
+}
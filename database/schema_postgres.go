@@ -0,0 +1,182 @@
+package database
+
+// PostgreSQL 用のテーブル定義です。SQLite 版の過去の作り直し（bracket_usage や tickets の再作成）は含まず、
+// 最終的な構造だけを作成します。列の名前と意味は SQLite 版と同じにしてください（luna db copy が列名で対応付けます）。
+
+var corePostgresMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS guilds (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		prefix TEXT DEFAULT '/',
+		language TEXT DEFAULT 'en',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL,
+		discriminator TEXT,
+		avatar TEXT,
+		bot BOOLEAN DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS command_usage (
+		id BIGSERIAL PRIMARY KEY,
+		guild_id TEXT REFERENCES guilds(id),
+		user_id TEXT NOT NULL REFERENCES users(id),
+		command TEXT NOT NULL,
+		args TEXT,
+		success BOOLEAN DEFAULT TRUE,
+		error_message TEXT,
+		executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS user_settings (
+		user_id TEXT PRIMARY KEY REFERENCES users(id),
+		theme TEXT DEFAULT 'material3',
+		color_scheme TEXT DEFAULT 'dynamic',
+		language TEXT DEFAULT 'en',
+		notifications BOOLEAN DEFAULT TRUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS guild_settings (
+		guild_id TEXT PRIMARY KEY REFERENCES guilds(id),
+
+		-- Ticket System Settings
+		ticket_enabled BOOLEAN DEFAULT FALSE,
+		ticket_category_id TEXT,
+		ticket_support_role_id TEXT,
+		ticket_admin_role_id TEXT,
+		ticket_log_channel_id TEXT,
+		ticket_transcript_channel_id TEXT,
+		ticket_auto_close_hours INTEGER DEFAULT 24,
+		ticket_max_per_user INTEGER DEFAULT 3,
+
+		-- Moderation Settings
+		moderation_enabled BOOLEAN DEFAULT FALSE,
+		moderation_log_channel_id TEXT,
+		automod_enabled BOOLEAN DEFAULT FALSE,
+
+		-- Welcome System Settings
+		welcome_enabled BOOLEAN DEFAULT FALSE,
+		welcome_channel_id TEXT,
+		welcome_message TEXT,
+		welcome_role_id TEXT,
+
+		-- Logging Settings
+		logging_enabled BOOLEAN DEFAULT FALSE,
+		log_channel_id TEXT,
+		log_message_edits BOOLEAN DEFAULT TRUE,
+		log_message_deletes BOOLEAN DEFAULT TRUE,
+		log_member_joins BOOLEAN DEFAULT TRUE,
+		log_member_leaves BOOLEAN DEFAULT TRUE,
+		log_channel_events BOOLEAN DEFAULT FALSE,
+		log_role_events BOOLEAN DEFAULT FALSE,
+		log_voice_events BOOLEAN DEFAULT FALSE,
+		log_moderation_events BOOLEAN DEFAULT FALSE,
+		log_server_events BOOLEAN DEFAULT FALSE,
+		log_nickname_changes BOOLEAN DEFAULT FALSE,
+		log_message_retention_days INTEGER DEFAULT 0,
+
+		-- Bump Settings
+		bump_enabled BOOLEAN DEFAULT FALSE,
+		bump_channel_id TEXT,
+		bump_role_id TEXT,
+		bump_last_time TIMESTAMP,
+		bump_reminder_sent BOOLEAN DEFAULT FALSE,
+
+		-- General Settings
+		settings_json TEXT,
+
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_command_usage_guild ON command_usage(guild_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_command_usage_user ON command_usage(user_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_command_usage_command ON command_usage(command)`,
+	`CREATE INDEX IF NOT EXISTS idx_command_usage_executed ON command_usage(executed_at)`,
+	`CREATE TABLE IF NOT EXISTS bracket_usage (
+		id BIGSERIAL PRIMARY KEY,
+		guild_id TEXT NOT NULL REFERENCES guilds(id),
+		user_id TEXT NOT NULL REFERENCES users(id),
+		half_width_pairs INTEGER DEFAULT 0,
+		full_width_pairs INTEGER DEFAULT 0,
+		total_pairs INTEGER DEFAULT 0,
+		last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(guild_id, user_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_bracket_usage_guild_new ON bracket_usage(guild_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_bracket_usage_total_new ON bracket_usage(total_pairs DESC)`,
+	`CREATE TABLE IF NOT EXISTS config_audit (
+		id BIGSERIAL PRIMARY KEY,
+		guild_id TEXT NOT NULL,
+		actor_id TEXT NOT NULL,
+		feature TEXT NOT NULL,
+		field TEXT NOT NULL,
+		old_value TEXT,
+		new_value TEXT,
+		changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_config_audit_guild ON config_audit(guild_id, id DESC)`,
+	`CREATE TABLE IF NOT EXISTS guild_modules (
+		guild_id TEXT NOT NULL,
+		module TEXT NOT NULL,
+		enabled BOOLEAN NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (guild_id, module)
+	)`,
+	`CREATE TABLE IF NOT EXISTS command_usage_daily (
+		guild_id TEXT NOT NULL,
+		command TEXT NOT NULL,
+		day TEXT NOT NULL,
+		uses INTEGER NOT NULL DEFAULT 0,
+		failures INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (guild_id, command, day)
+	)`,
+	`CREATE TABLE IF NOT EXISTS guild_removals (
+		guild_id TEXT PRIMARY KEY,
+		removed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS lockdown_schedules (
+		id BIGSERIAL PRIMARY KEY,
+		guild_id TEXT NOT NULL,
+		channel_id TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target_ids TEXT NOT NULL DEFAULT '',
+		permissions BIGINT NOT NULL DEFAULT 0,
+		unlock_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+}
+
+var ticketPostgresMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS tickets (
+		id BIGSERIAL PRIMARY KEY,
+		guild_id TEXT NOT NULL REFERENCES guilds(id),
+		channel_id TEXT NOT NULL UNIQUE,
+		creator_id TEXT NOT NULL REFERENCES users(id),
+		assigned_id TEXT REFERENCES users(id),
+		category TEXT DEFAULT 'general',
+		title TEXT NOT NULL,
+		description TEXT,
+		status TEXT DEFAULT 'open',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		closed_at TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS ticket_messages (
+		id BIGSERIAL PRIMARY KEY,
+		ticket_id BIGINT NOT NULL REFERENCES tickets(id),
+		user_id TEXT NOT NULL REFERENCES users(id),
+		message_id TEXT NOT NULL,
+		content TEXT,
+		attachments_json TEXT,
+		message_type TEXT DEFAULT 'user',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_tickets_guild ON tickets(guild_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_tickets_creator ON tickets(creator_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_tickets_status ON tickets(status)`,
+	`CREATE INDEX IF NOT EXISTS idx_ticket_messages_ticket ON ticket_messages(ticket_id)`,
+}
@@ -3,204 +3,328 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 )
 
 type Service struct {
-	db *sql.DB
+	db       *DB
+	settings *settingsCache
+	prefixes *prefixCache
+	// messages はログ機能が保存しているメッセージ（ログ機能が無効な場合は nil）
+	messages MessageArchive
+	log      *slog.Logger
 }
 
-func NewService(db *sql.DB) *Service {
-	return &Service{db: db}
+func NewService(db *DB, logger *slog.Logger) *Service {
+	var ttl time.Duration
+	if db.Driver() == DriverPostgres {
+		ttl = sharedSettingsTTL
+	}
+	return &Service{db: db, settings: newSettingsCache(ttl), prefixes: newPrefixCache(ttl), log: logger}
 }
 
-func (s *Service) Migrate() error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS guilds (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			prefix TEXT DEFAULT '/',
-			language TEXT DEFAULT 'en',
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS users (
-			id TEXT PRIMARY KEY,
-			username TEXT NOT NULL,
-			discriminator TEXT,
-			avatar TEXT,
-			bot BOOLEAN DEFAULT FALSE,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS command_usage (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			guild_id TEXT,
-			user_id TEXT NOT NULL,
-			command TEXT NOT NULL,
-			args TEXT,
-			success BOOLEAN DEFAULT TRUE,
-			error_message TEXT,
-			executed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (guild_id) REFERENCES guilds(id),
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS user_settings (
-			user_id TEXT PRIMARY KEY,
-			theme TEXT DEFAULT 'material3',
-			color_scheme TEXT DEFAULT 'dynamic',
-			language TEXT DEFAULT 'en',
-			notifications BOOLEAN DEFAULT TRUE,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS guild_settings (
-			guild_id TEXT PRIMARY KEY,
-			
-			-- Ticket System Settings
-			ticket_enabled BOOLEAN DEFAULT FALSE,
-			ticket_category_id TEXT,
-			ticket_support_role_id TEXT,
-			ticket_admin_role_id TEXT,
-			ticket_log_channel_id TEXT,
-			ticket_transcript_channel_id TEXT,
-			ticket_auto_close_hours INTEGER DEFAULT 24,
-			ticket_max_per_user INTEGER DEFAULT 3,
-			
-			-- Moderation Settings
-			moderation_enabled BOOLEAN DEFAULT FALSE,
-			moderation_log_channel_id TEXT,
-			automod_enabled BOOLEAN DEFAULT FALSE,
-			
-			-- Welcome System Settings
-			welcome_enabled BOOLEAN DEFAULT FALSE,
-			welcome_channel_id TEXT,
-			welcome_message TEXT,
-			welcome_role_id TEXT,
-			
-			-- Logging Settings
-			logging_enabled BOOLEAN DEFAULT FALSE,
-			log_channel_id TEXT,
-			log_message_edits BOOLEAN DEFAULT TRUE,
-			log_message_deletes BOOLEAN DEFAULT TRUE,
-			log_member_joins BOOLEAN DEFAULT TRUE,
-			log_member_leaves BOOLEAN DEFAULT TRUE,
-			log_channel_events BOOLEAN DEFAULT FALSE,
-			log_role_events BOOLEAN DEFAULT FALSE,
-			log_voice_events BOOLEAN DEFAULT FALSE,
-			log_moderation_events BOOLEAN DEFAULT FALSE,
-			log_server_events BOOLEAN DEFAULT FALSE,
-			log_nickname_changes BOOLEAN DEFAULT FALSE,
-			
-			-- Bump Settings
-			bump_enabled BOOLEAN DEFAULT FALSE,
-			bump_channel_id TEXT,
-			bump_role_id TEXT,
-			bump_last_time DATETIME,
-			bump_reminder_sent BOOLEAN DEFAULT FALSE,
-			
-			-- General Settings
-			settings_json TEXT,
-			
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (guild_id) REFERENCES guilds(id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS tickets (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			guild_id TEXT NOT NULL,
-			channel_id TEXT NOT NULL UNIQUE,
-			creator_id TEXT NOT NULL,
-			assigned_id TEXT,
-			category TEXT DEFAULT 'general',
-			title TEXT NOT NULL,
-			description TEXT,
-			status TEXT DEFAULT 'open',
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			closed_at DATETIME,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (guild_id) REFERENCES guilds(id),
-			FOREIGN KEY (creator_id) REFERENCES users(id),
-			FOREIGN KEY (assigned_id) REFERENCES users(id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS ticket_messages (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			ticket_id INTEGER NOT NULL,
-			user_id TEXT NOT NULL,
-			message_id TEXT NOT NULL,
-			content TEXT,
-			attachments_json TEXT,
-			message_type TEXT DEFAULT 'user',
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (ticket_id) REFERENCES tickets(id),
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_command_usage_guild ON command_usage(guild_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_command_usage_user ON command_usage(user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_command_usage_command ON command_usage(command)`,
-		`CREATE INDEX IF NOT EXISTS idx_command_usage_executed ON command_usage(executed_at)`,
-		`CREATE INDEX IF NOT EXISTS idx_tickets_guild ON tickets(guild_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_tickets_creator ON tickets(creator_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_tickets_status ON tickets(status)`,
-		`CREATE INDEX IF NOT EXISTS idx_ticket_messages_ticket ON ticket_messages(ticket_id)`,
-		// Migration: Drop and recreate bracket_usage table with new structure
-		`DROP TABLE IF EXISTS bracket_usage`,
-		`CREATE TABLE IF NOT EXISTS bracket_usage (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			guild_id TEXT NOT NULL,
-			user_id TEXT NOT NULL,
-			half_width_pairs INTEGER DEFAULT 0,
-			full_width_pairs INTEGER DEFAULT 0,
-			total_pairs INTEGER DEFAULT 0,
-			last_updated DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(guild_id, user_id),
-			FOREIGN KEY (guild_id) REFERENCES guilds(id),
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_bracket_usage_guild_new ON bracket_usage(guild_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_bracket_usage_total_new ON bracket_usage(total_pairs DESC)`,
-		// Migration: Remove priority column from tickets table
-		`CREATE TABLE IF NOT EXISTS tickets_new AS SELECT 
-			id, guild_id, channel_id, creator_id, assigned_id, category, 
-			title, description, status, created_at, closed_at, updated_at 
-			FROM tickets`,
-		`DROP TABLE IF EXISTS tickets`,
-		`ALTER TABLE tickets_new RENAME TO tickets`,
-		`CREATE INDEX IF NOT EXISTS idx_tickets_guild_new ON tickets(guild_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_tickets_creator_new ON tickets(creator_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_tickets_status_new ON tickets(status)`,
-	}
+// Close は接続を閉じます。SQLite ではWALの内容をメインのDBファイルに書き戻してから閉じます。
+func (s *Service) Close() error {
+	stats := s.settings.stats()
+	s.log.Info("Guild settings cache", "hits", stats.Hits, "misses", stats.Misses)
 
-	for _, migration := range migrations {
-		if _, err := s.db.Exec(migration); err != nil {
-			return fmt.Errorf("migration failed: %w", err)
+	if s.db.Driver() == DriverSQLite {
+		if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+			s.log.Warn("Failed to checkpoint WAL", "error", err)
 		}
 	}
+	return s.db.Close()
+}
+
+// Driver は接続先のデータベースの種類（sqlite または postgres）を返します
+func (s *Service) Driver() string {
+	return s.db.Driver()
+}
+
+// CoreSchema はモジュールに関係なく常に作成されるテーブルです
+var CoreSchema = Schema{
+	SQLite:   coreSQLiteMigrations,
+	Postgres: corePostgresMigrations,
+	Columns: []Column{
+		{Table: "guild_settings", Name: "log_message_retention_days", Definition: "INTEGER DEFAULT 0"},
+	},
+	Upgrades: []Upgrade{
+		{
+			Version:     1,
+			Description: "recreate bracket_usage with half/full width counts",
+			Needed: func(s *Service) (bool, error) {
+				return s.hasLegacyColumn("bracket_usage", "total_pairs", false)
+			},
+			SQLite: []string{`DROP TABLE bracket_usage`},
+		},
+	},
+}
+
+// TicketSchema はチケット機能のテーブルです（features.enable_tickets が有効な場合のみ作成）
+var TicketSchema = Schema{
+	SQLite:   ticketSQLiteMigrations,
+	Postgres: ticketPostgresMigrations,
+	Upgrades: []Upgrade{
+		{
+			// 以前は起動のたびに CREATE TABLE AS で作り直していたため、主キーと制約が失われている場合も対象にする
+			Version:     1,
+			Description: "rebuild tickets without the priority column",
+			Needed: func(s *Service) (bool, error) {
+				if legacy, err := s.hasLegacyColumn("tickets", "priority", true); err != nil || legacy {
+					return legacy, err
+				}
+				return s.missingPrimaryKey("tickets")
+			},
+			SQLite: []string{
+				`CREATE TABLE tickets_rebuild (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					guild_id TEXT NOT NULL,
+					channel_id TEXT NOT NULL UNIQUE,
+					creator_id TEXT NOT NULL,
+					assigned_id TEXT,
+					category TEXT DEFAULT 'general',
+					title TEXT NOT NULL,
+					description TEXT,
+					status TEXT DEFAULT 'open',
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					closed_at DATETIME,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (guild_id) REFERENCES guilds(id),
+					FOREIGN KEY (creator_id) REFERENCES users(id),
+					FOREIGN KEY (assigned_id) REFERENCES users(id)
+				)`,
+				`INSERT INTO tickets_rebuild (id, guild_id, channel_id, creator_id, assigned_id, category,
+					title, description, status, created_at, closed_at, updated_at)
+				SELECT id, guild_id, channel_id, creator_id, assigned_id, category,
+					title, description, status, created_at, closed_at, updated_at
+				FROM tickets`,
+				`DROP TABLE tickets`,
+				`ALTER TABLE tickets_rebuild RENAME TO tickets`,
+			},
+		},
+	},
+}
+
+var coreSQLiteMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS guilds (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		prefix TEXT DEFAULT '/',
+		language TEXT DEFAULT 'en',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL,
+		discriminator TEXT,
+		avatar TEXT,
+		bot BOOLEAN DEFAULT FALSE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS command_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		guild_id TEXT,
+		user_id TEXT NOT NULL,
+		command TEXT NOT NULL,
+		args TEXT,
+		success BOOLEAN DEFAULT TRUE,
+		error_message TEXT,
+		executed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (guild_id) REFERENCES guilds(id),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS user_settings (
+		user_id TEXT PRIMARY KEY,
+		theme TEXT DEFAULT 'material3',
+		color_scheme TEXT DEFAULT 'dynamic',
+		language TEXT DEFAULT 'en',
+		notifications BOOLEAN DEFAULT TRUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS guild_settings (
+		guild_id TEXT PRIMARY KEY,
+		
+		-- Ticket System Settings
+		ticket_enabled BOOLEAN DEFAULT FALSE,
+		ticket_category_id TEXT,
+		ticket_support_role_id TEXT,
+		ticket_admin_role_id TEXT,
+		ticket_log_channel_id TEXT,
+		ticket_transcript_channel_id TEXT,
+		ticket_auto_close_hours INTEGER DEFAULT 24,
+		ticket_max_per_user INTEGER DEFAULT 3,
+		
+		-- Moderation Settings
+		moderation_enabled BOOLEAN DEFAULT FALSE,
+		moderation_log_channel_id TEXT,
+		automod_enabled BOOLEAN DEFAULT FALSE,
+		
+		-- Welcome System Settings
+		welcome_enabled BOOLEAN DEFAULT FALSE,
+		welcome_channel_id TEXT,
+		welcome_message TEXT,
+		welcome_role_id TEXT,
+		
+		-- Logging Settings
+		logging_enabled BOOLEAN DEFAULT FALSE,
+		log_channel_id TEXT,
+		log_message_edits BOOLEAN DEFAULT TRUE,
+		log_message_deletes BOOLEAN DEFAULT TRUE,
+		log_member_joins BOOLEAN DEFAULT TRUE,
+		log_member_leaves BOOLEAN DEFAULT TRUE,
+		log_channel_events BOOLEAN DEFAULT FALSE,
+		log_role_events BOOLEAN DEFAULT FALSE,
+		log_voice_events BOOLEAN DEFAULT FALSE,
+		log_moderation_events BOOLEAN DEFAULT FALSE,
+		log_server_events BOOLEAN DEFAULT FALSE,
+		log_nickname_changes BOOLEAN DEFAULT FALSE,
+		log_message_retention_days INTEGER DEFAULT 0,
+		
+		-- Bump Settings
+		bump_enabled BOOLEAN DEFAULT FALSE,
+		bump_channel_id TEXT,
+		bump_role_id TEXT,
+		bump_last_time DATETIME,
+		bump_reminder_sent BOOLEAN DEFAULT FALSE,
+		
+		-- General Settings
+		settings_json TEXT,
+		
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (guild_id) REFERENCES guilds(id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_command_usage_guild ON command_usage(guild_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_command_usage_user ON command_usage(user_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_command_usage_command ON command_usage(command)`,
+	`CREATE INDEX IF NOT EXISTS idx_command_usage_executed ON command_usage(executed_at)`,
+	`CREATE TABLE IF NOT EXISTS bracket_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		guild_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		half_width_pairs INTEGER DEFAULT 0,
+		full_width_pairs INTEGER DEFAULT 0,
+		total_pairs INTEGER DEFAULT 0,
+		last_updated DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(guild_id, user_id),
+		FOREIGN KEY (guild_id) REFERENCES guilds(id),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_bracket_usage_guild_new ON bracket_usage(guild_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_bracket_usage_total_new ON bracket_usage(total_pairs DESC)`,
+	`CREATE TABLE IF NOT EXISTS config_audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		guild_id TEXT NOT NULL,
+		actor_id TEXT NOT NULL,
+		feature TEXT NOT NULL,
+		field TEXT NOT NULL,
+		old_value TEXT,
+		new_value TEXT,
+		changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_config_audit_guild ON config_audit(guild_id, id DESC)`,
+	`CREATE TABLE IF NOT EXISTS guild_modules (
+		guild_id TEXT NOT NULL,
+		module TEXT NOT NULL,
+		enabled BOOLEAN NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (guild_id, module)
+	)`,
+	`CREATE TABLE IF NOT EXISTS command_usage_daily (
+		guild_id TEXT NOT NULL,
+		command TEXT NOT NULL,
+		day TEXT NOT NULL,
+		uses INTEGER NOT NULL DEFAULT 0,
+		failures INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (guild_id, command, day)
+	)`,
+	`CREATE TABLE IF NOT EXISTS guild_removals (
+		guild_id TEXT PRIMARY KEY,
+		removed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS lockdown_schedules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		guild_id TEXT NOT NULL,
+		channel_id TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target_ids TEXT NOT NULL DEFAULT '',
+		permissions INTEGER NOT NULL DEFAULT 0,
+		unlock_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+}
 
-	return nil
+var ticketSQLiteMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS tickets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		guild_id TEXT NOT NULL,
+		channel_id TEXT NOT NULL UNIQUE,
+		creator_id TEXT NOT NULL,
+		assigned_id TEXT,
+		category TEXT DEFAULT 'general',
+		title TEXT NOT NULL,
+		description TEXT,
+		status TEXT DEFAULT 'open',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		closed_at DATETIME,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (guild_id) REFERENCES guilds(id),
+		FOREIGN KEY (creator_id) REFERENCES users(id),
+		FOREIGN KEY (assigned_id) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS ticket_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ticket_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		message_id TEXT NOT NULL,
+		content TEXT,
+		attachments_json TEXT,
+		message_type TEXT DEFAULT 'user',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (ticket_id) REFERENCES tickets(id),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_tickets_guild ON tickets(guild_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_tickets_creator ON tickets(creator_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_tickets_status ON tickets(status)`,
+	`CREATE INDEX IF NOT EXISTS idx_ticket_messages_ticket ON ticket_messages(ticket_id)`,
 }
 
 func (s *Service) LogCommand(guildID, userID, command, args string, success bool, errorMsg string) error {
 	query := `
 		INSERT INTO command_usage (guild_id, user_id, command, args, success, error_message)
-		VALUES (?, ?, ?, ?, ?, ?)
+		VALUES (NULLIF(?, ''), ?, ?, ?, ?, ?)
 	`
 	_, err := s.db.Exec(query, guildID, userID, command, args, success, errorMsg)
 	return err
 }
 
+// GetCommandStats はコマンドごとの使用回数を返します。
+// 保持期間を過ぎて日別に集計された分は、since の日を含む日単位で合算します。
 func (s *Service) GetCommandStats(guildID string, since time.Time) (map[string]int, error) {
 	query := `
-		SELECT command, COUNT(*) as count
-		FROM command_usage
-		WHERE guild_id = ? AND executed_at >= ?
+		SELECT command, CAST(SUM(uses) AS BIGINT) AS count
+		FROM (
+			SELECT command, COUNT(*) AS uses
+			FROM command_usage
+			WHERE guild_id = ? AND executed_at >= ?
+			GROUP BY command
+			UNION ALL
+			SELECT command, SUM(uses) AS uses
+			FROM command_usage_daily
+			WHERE guild_id = ? AND day >= ?
+			GROUP BY command
+		) AS usage
 		GROUP BY command
 		ORDER BY count DESC
 	`
 	
-	rows, err := s.db.Query(query, guildID, since)
+	rows, err := s.db.Query(query, guildID, since, guildID, since.UTC().Format(dayFormat))
 	if err != nil {
 		return nil, err
 	}
@@ -220,6 +344,10 @@ func (s *Service) GetCommandStats(guildID string, since time.Time) (map[string]i
 }
 
 func (s *Service) UpsertUser(id, username, discriminator, avatar string, isBot bool) error {
+	return upsertUser(s.db, id, username, discriminator, avatar, isBot)
+}
+
+func upsertUser(db execer, id, username, discriminator, avatar string, isBot bool) error {
 	query := `
 		INSERT INTO users (id, username, discriminator, avatar, bot)
 		VALUES (?, ?, ?, ?, ?)
@@ -230,11 +358,13 @@ func (s *Service) UpsertUser(id, username, discriminator, avatar string, isBot b
 			bot = excluded.bot,
 			updated_at = CURRENT_TIMESTAMP
 	`
-	_, err := s.db.Exec(query, id, username, discriminator, avatar, isBot)
+	_, err := db.Exec(query, id, username, discriminator, avatar, isBot)
 	return err
 }
 
 func (s *Service) UpsertGuild(id, name, prefix string) error {
+	defer s.prefixes.invalidate(id)
+
 	query := `
 		INSERT INTO guilds (id, name, prefix)
 		VALUES (?, ?, ?)
@@ -247,14 +377,23 @@ func (s *Service) UpsertGuild(id, name, prefix string) error {
 	return err
 }
 
+// GetGuildPrefix はギルドのプレフィックスを返します（キャッシュから返す場合があります）
 func (s *Service) GetGuildPrefix(guildID string) (string, error) {
-	var prefix string
+	prefix, ok, generation := s.prefixes.get(guildID)
+	if ok {
+		return prefix, nil
+	}
+
 	query := `SELECT prefix FROM guilds WHERE id = ?`
 	err := s.db.QueryRow(query, guildID).Scan(&prefix)
 	if err == sql.ErrNoRows {
-		return "/", nil
+		prefix, err = "/", nil
 	}
-	return prefix, err
+	if err != nil {
+		return "", err
+	}
+	s.prefixes.put(guildID, prefix, generation)
+	return prefix, nil
 }
 
 // Guild Settings Management
@@ -296,6 +435,7 @@ type GuildSettings struct {
 	LogModerationEvents bool `json:"log_moderation_events"`
 	LogServerEvents     bool `json:"log_server_events"`
 	LogNicknameChanges  bool `json:"log_nickname_changes"`
+	LogMessageRetentionDays int `json:"log_message_retention_days"` // 編集・削除ログ用にメッセージを保存する日数（0 の場合は既定値）
 	
 	// Bump Settings
 	BumpEnabled        bool      `json:"bump_enabled"`
@@ -310,7 +450,22 @@ type GuildSettings struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// GetGuildSettings はギルドの設定を返します。キャッシュがあればデータベースを参照しません。
 func (s *Service) GetGuildSettings(guildID string) (*GuildSettings, error) {
+	cached, generation := s.settings.get(guildID)
+	if cached != nil {
+		return cached, nil
+	}
+
+	settings, err := s.loadGuildSettings(guildID)
+	if err != nil {
+		return settings, err
+	}
+	s.settings.put(settings, generation)
+	return settings, nil
+}
+
+func (s *Service) loadGuildSettings(guildID string) (*GuildSettings, error) {
 	settings := &GuildSettings{GuildID: guildID}
 	
 	query := `
@@ -323,6 +478,7 @@ func (s *Service) GetGuildSettings(guildID string) (*GuildSettings, error) {
 			logging_enabled, log_channel_id, log_message_edits, log_message_deletes,
 			log_member_joins, log_member_leaves, log_channel_events, log_role_events,
 			log_voice_events, log_moderation_events, log_server_events, log_nickname_changes,
+			log_message_retention_days,
 			bump_enabled, bump_channel_id, bump_role_id, bump_last_time, bump_reminder_sent,
 			settings_json, created_at, updated_at
 		FROM guild_settings 
@@ -338,25 +494,24 @@ func (s *Service) GetGuildSettings(guildID string) (*GuildSettings, error) {
 		&settings.LoggingEnabled, &settings.LogChannelID, &settings.LogMessageEdits, &settings.LogMessageDeletes,
 		&settings.LogMemberJoins, &settings.LogMemberLeaves, &settings.LogChannelEvents, &settings.LogRoleEvents,
 		&settings.LogVoiceEvents, &settings.LogModerationEvents, &settings.LogServerEvents, &settings.LogNicknameChanges,
+		&settings.LogMessageRetentionDays,
 		&settings.BumpEnabled, &settings.BumpChannelID, &settings.BumpRoleID, &settings.BumpLastTime, &settings.BumpReminderSent,
 		&settings.SettingsJSON, &settings.CreatedAt, &settings.UpdatedAt,
 	)
 	
 	if err == sql.ErrNoRows {
-		// Return default settings
-		settings.TicketAutoCloseHours = 24
-		settings.TicketMaxPerUser = 3
-		settings.LogMessageEdits = true
-		settings.LogMessageDeletes = true
-		settings.LogMemberJoins = true
-		settings.LogMemberLeaves = true
-		return settings, nil
+		return defaultGuildSettings(guildID), nil
 	}
 	
 	return settings, err
 }
 
 func (s *Service) UpsertGuildSettings(settings *GuildSettings) error {
+	defer s.settings.invalidate(settings.GuildID)
+	return s.upsertGuildSettings(s.db, settings)
+}
+
+func (s *Service) upsertGuildSettings(db execer, settings *GuildSettings) error {
 	query := `
 		INSERT INTO guild_settings (
 			guild_id, ticket_enabled, ticket_category_id, ticket_support_role_id,
@@ -367,9 +522,10 @@ func (s *Service) UpsertGuildSettings(settings *GuildSettings) error {
 			logging_enabled, log_channel_id, log_message_edits, log_message_deletes,
 			log_member_joins, log_member_leaves, log_channel_events, log_role_events,
 			log_voice_events, log_moderation_events, log_server_events, log_nickname_changes,
+			log_message_retention_days,
 			bump_enabled, bump_channel_id, bump_role_id, bump_last_time, bump_reminder_sent,
 			settings_json
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(guild_id) DO UPDATE SET
 			ticket_enabled = excluded.ticket_enabled,
 			ticket_category_id = excluded.ticket_category_id,
@@ -398,6 +554,7 @@ func (s *Service) UpsertGuildSettings(settings *GuildSettings) error {
 			log_moderation_events = excluded.log_moderation_events,
 			log_server_events = excluded.log_server_events,
 			log_nickname_changes = excluded.log_nickname_changes,
+			log_message_retention_days = excluded.log_message_retention_days,
 			bump_enabled = excluded.bump_enabled,
 			bump_channel_id = excluded.bump_channel_id,
 			bump_role_id = excluded.bump_role_id,
@@ -413,7 +570,7 @@ func (s *Service) UpsertGuildSettings(settings *GuildSettings) error {
 		settingsJSON = "{}"
 	}
 
-	_, err := s.db.Exec(query,
+	_, err := db.Exec(query,
 		settings.GuildID, settings.TicketEnabled, settings.TicketCategoryID, settings.TicketSupportRoleID,
 		settings.TicketAdminRoleID, settings.TicketLogChannelID, settings.TicketTranscriptChannelID,
 		settings.TicketAutoCloseHours, settings.TicketMaxPerUser,
@@ -422,75 +579,75 @@ func (s *Service) UpsertGuildSettings(settings *GuildSettings) error {
 		settings.LoggingEnabled, settings.LogChannelID, settings.LogMessageEdits, settings.LogMessageDeletes,
 		settings.LogMemberJoins, settings.LogMemberLeaves, settings.LogChannelEvents, settings.LogRoleEvents,
 		settings.LogVoiceEvents, settings.LogModerationEvents, settings.LogServerEvents, settings.LogNicknameChanges,
+		settings.LogMessageRetentionDays,
 		settings.BumpEnabled, settings.BumpChannelID, settings.BumpRoleID, settings.BumpLastTime, settings.BumpReminderSent,
 		settingsJSON,
 	)
 	
 	if err != nil {
-		log.Printf("Database error in UpsertGuildSettings: %v", err)
-		log.Printf("Guild ID: %s", settings.GuildID)
+		s.log.Error("Failed to upsert guild settings", "guild_id", settings.GuildID, "error", err)
 	}
 	
 	return err
 }
 
-func (s *Service) ResetGuildSettings(guildID, feature string) error {
-	var query string
-	
+// ResetGuildSettings は機能の設定を既定値に戻します（"all" の場合はすべての設定）。
+// 変更は SaveGuildSettings で実行者とともに監査ログに記録します。
+func (s *Service) ResetGuildSettings(guildID, feature, actorID string) error {
+	settings, err := s.GetGuildSettings(guildID)
+	if err != nil {
+		return err
+	}
+	reset := *settings
+	defaults := defaultGuildSettings(guildID)
+
 	switch feature {
 	case "tickets":
-		query = `
-			UPDATE guild_settings SET
-				ticket_enabled = FALSE,
-				ticket_category_id = NULL,
-				ticket_support_role_id = NULL,
-				ticket_admin_role_id = NULL,
-				ticket_log_channel_id = NULL,
-				ticket_transcript_channel_id = NULL,
-				ticket_auto_close_hours = 24,
-				ticket_max_per_user = 3,
-				updated_at = CURRENT_TIMESTAMP
-			WHERE guild_id = ?
-		`
+		reset.TicketEnabled = defaults.TicketEnabled
+		reset.TicketCategoryID = defaults.TicketCategoryID
+		reset.TicketSupportRoleID = defaults.TicketSupportRoleID
+		reset.TicketAdminRoleID = defaults.TicketAdminRoleID
+		reset.TicketLogChannelID = defaults.TicketLogChannelID
+		reset.TicketTranscriptChannelID = defaults.TicketTranscriptChannelID
+		reset.TicketAutoCloseHours = defaults.TicketAutoCloseHours
+		reset.TicketMaxPerUser = defaults.TicketMaxPerUser
 	case "moderation":
-		query = `
-			UPDATE guild_settings SET
-				moderation_enabled = FALSE,
-				moderation_log_channel_id = NULL,
-				automod_enabled = FALSE,
-				updated_at = CURRENT_TIMESTAMP
-			WHERE guild_id = ?
-		`
+		reset.ModerationEnabled = defaults.ModerationEnabled
+		reset.ModerationLogChannelID = defaults.ModerationLogChannelID
+		reset.AutomodEnabled = defaults.AutomodEnabled
 	case "welcome":
-		query = `
-			UPDATE guild_settings SET
-				welcome_enabled = FALSE,
-				welcome_channel_id = NULL,
-				welcome_message = NULL,
-				welcome_role_id = NULL,
-				updated_at = CURRENT_TIMESTAMP
-			WHERE guild_id = ?
-		`
+		reset.WelcomeEnabled = defaults.WelcomeEnabled
+		reset.WelcomeChannelID = defaults.WelcomeChannelID
+		reset.WelcomeMessage = defaults.WelcomeMessage
+		reset.WelcomeRoleID = defaults.WelcomeRoleID
 	case "logging":
-		query = `
-			UPDATE guild_settings SET
-				logging_enabled = FALSE,
-				log_channel_id = NULL,
-				log_message_edits = TRUE,
-				log_message_deletes = TRUE,
-				log_member_joins = TRUE,
-				log_member_leaves = TRUE,
-				updated_at = CURRENT_TIMESTAMP
-			WHERE guild_id = ?
-		`
+		reset.LoggingEnabled = defaults.LoggingEnabled
+		reset.LogChannelID = defaults.LogChannelID
+		reset.LogMessageEdits = defaults.LogMessageEdits
+		reset.LogMessageDeletes = defaults.LogMessageDeletes
+		reset.LogMemberJoins = defaults.LogMemberJoins
+		reset.LogMemberLeaves = defaults.LogMemberLeaves
+		reset.LogMessageRetentionDays = defaults.LogMessageRetentionDays
 	case "all":
-		query = `DELETE FROM guild_settings WHERE guild_id = ?`
+		reset = *defaults
 	default:
 		return fmt.Errorf("unknown feature: %s", feature)
 	}
-	
-	_, err := s.db.Exec(query, guildID)
-	return err
+
+	return s.SaveGuildSettings(&reset, actorID, "reset")
+}
+
+// defaultGuildSettings は設定がまだ保存されていないギルドの設定です
+func defaultGuildSettings(guildID string) *GuildSettings {
+	return &GuildSettings{
+		GuildID:              guildID,
+		TicketAutoCloseHours: 24,
+		TicketMaxPerUser:     3,
+		LogMessageEdits:      true,
+		LogMessageDeletes:    true,
+		LogMemberJoins:       true,
+		LogMemberLeaves:      true,
+	}
 }
 
 // Bump関連のメソッド
@@ -502,6 +659,7 @@ func (s *Service) UpdateBumpTime(guildID string) error {
 			updated_at = CURRENT_TIMESTAMP
 		WHERE guild_id = ?
 	`
+	defer s.settings.invalidate(guildID)
 	_, err := s.db.Exec(query, guildID)
 	return err
 }
@@ -513,6 +671,7 @@ func (s *Service) MarkBumpReminderSent(guildID string) error {
 			updated_at = CURRENT_TIMESTAMP
 		WHERE guild_id = ?
 	`
+	defer s.settings.invalidate(guildID)
 	_, err := s.db.Exec(query, guildID)
 	return err
 }
@@ -525,8 +684,7 @@ func (s *Service) GetBumpableGuilds() ([]*GuildSettings, error) {
 		WHERE bump_enabled = TRUE 
 		AND bump_last_time IS NOT NULL 
 		AND bump_reminder_sent = FALSE
-		AND datetime(bump_last_time, '+2 hours') <= datetime('now')
-	`
+		AND ` + s.db.dialect.bumpDueCondition()
 	
 	rows, err := s.db.Query(query)
 	if err != nil {
@@ -556,31 +714,40 @@ func (s *Service) GetBumpableGuilds() ([]*GuildSettings, error) {
 
 // Bracket usage methods
 func (s *Service) UpdateBracketUsage(guildID, userID string, halfWidthPairs, fullWidthPairs int) error {
+	return updateBracketUsage(s.db, guildID, userID, halfWidthPairs, fullWidthPairs)
+}
+
+func updateBracketUsage(db execer, guildID, userID string, halfWidthPairs, fullWidthPairs int) error {
 	totalPairs := halfWidthPairs + fullWidthPairs
 	query := `
 		INSERT INTO bracket_usage (guild_id, user_id, half_width_pairs, full_width_pairs, total_pairs, last_updated)
 		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 		ON CONFLICT(guild_id, user_id) DO UPDATE SET
-			half_width_pairs = half_width_pairs + ?,
-			full_width_pairs = full_width_pairs + ?,
-			total_pairs = total_pairs + ?,
+			half_width_pairs = bracket_usage.half_width_pairs + excluded.half_width_pairs,
+			full_width_pairs = bracket_usage.full_width_pairs + excluded.full_width_pairs,
+			total_pairs = bracket_usage.total_pairs + excluded.total_pairs,
 			last_updated = CURRENT_TIMESTAMP
 	`
-	_, err := s.db.Exec(query, guildID, userID, halfWidthPairs, fullWidthPairs, totalPairs, 
-		halfWidthPairs, fullWidthPairs, totalPairs)
+	_, err := db.Exec(query, guildID, userID, halfWidthPairs, fullWidthPairs, totalPairs)
 	return err
 }
 
+// GetBracketRanking はかっこの使用数の多い順に limit 件返します（負の場合は全件）
 func (s *Service) GetBracketRanking(guildID string, limit int) ([]BracketStats, error) {
 	query := `
 		SELECT user_id, half_width_pairs, full_width_pairs, total_pairs
 		FROM bracket_usage
-		WHERE guild_id = ?
+		WHERE guild_id = ? AND user_id <> ?
 		ORDER BY total_pairs DESC
 		LIMIT ?
 	`
 	
-	rows, err := s.db.Query(query, guildID, limit)
+	var limitArg interface{} = limit
+	if limit < 0 {
+		limitArg = s.db.dialect.noLimit()
+	}
+	// 削除されたユーザーから引き継いだ分（DeletedUserID）はランキングに含めない
+	rows, err := s.db.Query(query, guildID, DeletedUserID, limitArg)
 	if err != nil {
 		return nil, err
 	}
@@ -625,4 +792,37 @@ type BracketStats struct {
 	HalfWidthPairs int
 	FullWidthPairs int
 	TotalPairs     int
-}
\ No newline at end of file
+}
+// GetGuildModules はギルドで個別に有効・無効が設定されたモジュールを返します
+func (s *Service) GetGuildModules(guildID string) (map[string]bool, error) {
+	rows, err := s.db.Query(`SELECT module, enabled FROM guild_modules WHERE guild_id = ?`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	modules := make(map[string]bool)
+	for rows.Next() {
+		var module string
+		var enabled bool
+		if err := rows.Scan(&module, &enabled); err != nil {
+			return nil, err
+		}
+		modules[module] = enabled
+	}
+
+	return modules, rows.Err()
+}
+
+// SetGuildModule はギルドでのモジュールの有効・無効を保存します
+func (s *Service) SetGuildModule(guildID, module string, enabled bool) error {
+	query := `
+		INSERT INTO guild_modules (guild_id, module, enabled)
+		VALUES (?, ?, ?)
+		ON CONFLICT(guild_id, module) DO UPDATE SET
+			enabled = excluded.enabled,
+			updated_at = CURRENT_TIMESTAMP
+	`
+	_, err := s.db.Exec(query, guildID, module, enabled)
+	return err
+}
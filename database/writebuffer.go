@@ -0,0 +1,243 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Sumire-Labs/Luna/config"
+)
+
+// userRow はまとめて書き込むユーザー情報です（同じユーザーは最新の値で上書き）
+type userRow struct {
+	username, discriminator, avatar string
+	bot                             bool
+}
+
+// bracketKey と bracketCount はまとめて加算するかっこの集計です
+type bracketKey struct {
+	guildID, userID string
+}
+
+type bracketCount struct {
+	halfWidth, fullWidth int
+}
+
+// maxFlushAttempts はまとめた書き込みを再試行する回数です。
+// これを超えて失敗し続けた場合は1行ずつ書き込み、書き込めない行を捨てます。
+const maxFlushAttempts = 5
+
+// WriteBuffer はメッセージごとに発生するユーザー更新とかっこ集計をメモリに溜め、
+// 一定間隔または一定件数ごとに1つのトランザクションで書き込みます。
+// 停止時（Close）に残りを書き込むため、ワーカープールの停止後・データベースの停止前に閉じる必要があります。
+type WriteBuffer struct {
+	service   *Service
+	batchSize int
+	interval  time.Duration
+	log       *slog.Logger
+
+	mu       sync.Mutex
+	users    map[string]userRow
+	brackets map[bracketKey]bracketCount
+	failures int // 連続して失敗した書き込みの回数
+
+	full chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewWriteBuffer(service *Service, cfg config.DatabaseConfig, logger *slog.Logger) *WriteBuffer {
+	batchSize := cfg.WriteBatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	interval := time.Duration(cfg.WriteFlushInterval) * time.Second
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	return &WriteBuffer{
+		service:   service,
+		batchSize: batchSize,
+		interval:  interval,
+		log:       logger,
+		users:     make(map[string]userRow),
+		brackets:  make(map[bracketKey]bracketCount),
+		full:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start は定期的な書き込みを開始します
+func (w *WriteBuffer) Start() {
+	go w.run()
+}
+
+// UpsertUser はユーザー情報の更新を予約します
+func (w *WriteBuffer) UpsertUser(id, username, discriminator, avatar string, isBot bool) {
+	w.mu.Lock()
+	w.users[id] = userRow{username: username, discriminator: discriminator, avatar: avatar, bot: isBot}
+	w.mu.Unlock()
+	w.notifyIfFull()
+}
+
+// UpdateBracketUsage はかっこの使用数の加算を予約します
+func (w *WriteBuffer) UpdateBracketUsage(guildID, userID string, halfWidthPairs, fullWidthPairs int) {
+	w.mu.Lock()
+	key := bracketKey{guildID: guildID, userID: userID}
+	count := w.brackets[key]
+	count.halfWidth += halfWidthPairs
+	count.fullWidth += fullWidthPairs
+	w.brackets[key] = count
+	w.mu.Unlock()
+	w.notifyIfFull()
+}
+
+// Pending は書き込み待ちの件数を返します
+func (w *WriteBuffer) Pending() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.users) + len(w.brackets)
+}
+
+func (w *WriteBuffer) notifyIfFull() {
+	if w.Pending() < w.batchSize {
+		return
+	}
+	select {
+	case w.full <- struct{}{}:
+	default:
+	}
+}
+
+func (w *WriteBuffer) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.full:
+		case <-w.stop:
+			return
+		}
+		if err := w.Flush(); err != nil {
+			w.log.Error("Failed to flush buffered writes", "pending", w.Pending(), "error", err)
+		}
+	}
+}
+
+// Flush は溜まっている書き込みを1つのトランザクションで実行します。
+// 失敗した場合は次回の書き込みで再試行し、maxFlushAttempts 回続けて失敗した場合は
+// 1行ずつ書き込んで、外部キー違反などで書き込めない行だけを捨てます。
+func (w *WriteBuffer) Flush() error {
+	w.mu.Lock()
+	users, brackets := w.users, w.brackets
+	if len(users) == 0 && len(brackets) == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	w.users = make(map[string]userRow)
+	w.brackets = make(map[bracketKey]bracketCount)
+	w.mu.Unlock()
+
+	err := w.write(users, brackets)
+
+	w.mu.Lock()
+	if err == nil {
+		w.failures = 0
+		w.mu.Unlock()
+		return nil
+	}
+	w.failures++
+	failures := w.failures
+	w.mu.Unlock()
+
+	if failures < maxFlushAttempts {
+		w.requeue(users, brackets)
+		return err
+	}
+
+	dropped := w.writeEach(users, brackets)
+	w.mu.Lock()
+	w.failures = 0
+	w.mu.Unlock()
+	if dropped > 0 {
+		return fmt.Errorf("dropped %d buffered writes after %d failed attempts: %w", dropped, failures, err)
+	}
+	return nil
+}
+
+// writeEach は1行ずつ書き込み、書き込めなかった行の数を返します
+func (w *WriteBuffer) writeEach(users map[string]userRow, brackets map[bracketKey]bracketCount) int {
+	dropped := 0
+	for id, u := range users {
+		if err := upsertUser(w.service.db, id, u.username, u.discriminator, u.avatar, u.bot); err != nil {
+			w.log.Warn("Dropped buffered user write", "user_id", id, "error", err)
+			dropped++
+		}
+	}
+	for key, count := range brackets {
+		if err := updateBracketUsage(w.service.db, key.guildID, key.userID, count.halfWidth, count.fullWidth); err != nil {
+			w.log.Warn("Dropped buffered bracket usage", "guild_id", key.guildID, "user_id", key.userID, "error", err)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+func (w *WriteBuffer) write(users map[string]userRow, brackets map[bracketKey]bracketCount) error {
+	tx, err := w.service.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// bracket_usage は users を参照するため、ユーザーを先に書き込む
+	for id, u := range users {
+		if err := upsertUser(tx, id, u.username, u.discriminator, u.avatar, u.bot); err != nil {
+			return err
+		}
+	}
+	for key, count := range brackets {
+		if err := updateBracketUsage(tx, key.guildID, key.userID, count.halfWidth, count.fullWidth); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// requeue は書き込めなかった内容を戻します。その間に予約された値を優先します。
+func (w *WriteBuffer) requeue(users map[string]userRow, brackets map[bracketKey]bracketCount) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for id, u := range users {
+		if _, ok := w.users[id]; !ok {
+			w.users[id] = u
+		}
+	}
+	for key, count := range brackets {
+		current := w.brackets[key]
+		current.halfWidth += count.halfWidth
+		current.fullWidth += count.fullWidth
+		w.brackets[key] = current
+	}
+}
+
+// Close は定期的な書き込みを止め、残りをすべて書き込みます
+func (w *WriteBuffer) Close(ctx context.Context) error {
+	close(w.stop)
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return w.Flush()
+}
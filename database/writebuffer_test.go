@@ -0,0 +1,50 @@
+package database
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/Sumire-Labs/Luna/config"
+)
+
+func TestWriteBufferDropsRowsThatKeepFailing(t *testing.T) {
+	s := newTestService(t)
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	mustExec(t, s,
+		`INSERT INTO guilds (id, name) VALUES ('1', 'g')`,
+		`INSERT INTO guilds (id, name) VALUES ('9', 'broken')`,
+		// 特定の行だけ書き込みに失敗させる
+		`CREATE TRIGGER reject_bracket_usage BEFORE INSERT ON bracket_usage WHEN NEW.guild_id = '9'
+			BEGIN SELECT RAISE(ABORT, 'rejected'); END`,
+	)
+
+	w := NewWriteBuffer(s, config.DatabaseConfig{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	w.UpsertUser("2", "u", "0", "", false)
+	w.UpdateBracketUsage("1", "2", 1, 0)
+	w.UpdateBracketUsage("9", "2", 1, 0)
+
+	for i := 1; i < maxFlushAttempts; i++ {
+		if err := w.Flush(); err == nil {
+			t.Fatalf("flush %d succeeded, want the batch to fail", i)
+		}
+		if n := w.Pending(); n != 3 {
+			t.Fatalf("pending after flush %d = %d, want 3", i, n)
+		}
+	}
+
+	if err := w.Flush(); err == nil {
+		t.Error("flush that drops rows should report them")
+	}
+	if n := w.Pending(); n != 0 {
+		t.Errorf("pending = %d, want 0", n)
+	}
+	if n := count(t, s, `SELECT COUNT(*) FROM users WHERE id = '2'`); n != 1 {
+		t.Error("valid user was not written")
+	}
+	if n := count(t, s, `SELECT COUNT(*) FROM bracket_usage`); n != 1 {
+		t.Errorf("bracket_usage rows = %d, want only the accepted guild", n)
+	}
+}
@@ -2,35 +2,65 @@ package di
 
 import (
 	"context"
-	"database/sql"
+	"log/slog"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/Sumire-Labs/Luna/ai"
+	"github.com/Sumire-Labs/Luna/backup"
 	"github.com/Sumire-Labs/Luna/bot"
 	"github.com/Sumire-Labs/Luna/bump"
+	"github.com/Sumire-Labs/Luna/cleanup"
 	"github.com/Sumire-Labs/Luna/commands"
 	"github.com/Sumire-Labs/Luna/config"
 	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/features"
+	"github.com/Sumire-Labs/Luna/interactions"
+	"github.com/Sumire-Labs/Luna/lifecycle"
 	"github.com/Sumire-Labs/Luna/logging"
+	"github.com/Sumire-Labs/Luna/replay"
+	"github.com/Sumire-Labs/Luna/retention"
+	"github.com/Sumire-Labs/Luna/shard"
+	"github.com/Sumire-Labs/Luna/worker"
 )
 
 type Container struct {
 	Config           *config.Config
-	DB               *sql.DB
+	Log              *slog.Logger
+	DB               *database.DB
 	Session          *discordgo.Session
+	Shards           *shard.Manager
 	Bot              *bot.Bot
 	CommandRegistry  *commands.Registry
 	DatabaseService  *database.Service
+	WriteBuffer      *database.WriteBuffer
+	Backup           *backup.Scheduler
+	Retention        *retention.Janitor
 	Logger           *logging.Logger
+	MessageStore     *database.MessageStore
 	AIService        *ai.Service
 	GeminiStudio     *ai.GeminiStudioService
 	VertexGemini     *ai.VertexGeminiService
 	BumpHandler      *bump.Handler
+	CleanupHandler   *cleanup.Handler
+	Pool             *worker.Pool
+	Lifecycle        *lifecycle.Manager
+	Interactions     *interactions.Server
+	Recorder         *replay.Recorder
+	ConfigWatcher    *config.Watcher
+	Features         *features.Gate
+
+	// 起動時に組み込まれたモジュール
+	modules []*module
+	// maintenance はゲートウェイに接続しない保守用コンテナ（luna のサブコマンド）かどうか
+	maintenance bool
 }
 
-func NewContainer(ctx context.Context, cfg *config.Config) (*Container, error) {
+func NewContainer(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*Container, error) {
 	container := &Container{
-		Config: cfg,
+		Config:    cfg,
+		Log:       logger,
+		Lifecycle: lifecycle.NewManager(time.Duration(cfg.Bot.ShutdownTimeout)*time.Second, logger),
 	}
 
 	if err := container.initDatabase(); err != nil {
@@ -41,169 +71,168 @@ func NewContainer(ctx context.Context, cfg *config.Config) (*Container, error) {
 		return nil, err
 	}
 
-	container.initServices()
-	
-	// AI Service の初期化（オプション）
-	if cfg.GoogleCloud.UseStudioAPI && cfg.GoogleCloud.StudioAPIKey != "" {
-		// Google AI Studio API を優先
-		if err := container.initGeminiStudio(); err != nil {
-			println("Warning: Gemini Studio service initialization failed:", err.Error())
-		}
-	} else if cfg.GoogleCloud.ProjectID != "" {
-		// 新しいVertex AI Gemini APIを使用
-		if err := container.initVertexGemini(); err != nil {
-			println("Warning: Vertex AI Gemini service initialization failed:", err.Error())
-		}
-		// Imagen用に旧APIも初期化
-		if err := container.initAIService(); err != nil {
-			println("Warning: Vertex AI service initialization failed:", err.Error())
-		}
+	if err := container.initServices(); err != nil {
+		return nil, err
 	}
-	
+
 	container.initCommands()
+	if err := container.initModules(); err != nil {
+		return nil, err
+	}
+	container.Retention.Start()
+	if err := container.initInteractions(); err != nil {
+		return nil, err
+	}
+	container.initIntents()
+	container.initConfigReload()
+	container.registerShutdownHooks()
 
 	return container, nil
 }
 
 func (c *Container) initDatabase() error {
+	// /backup restore で予約された復元は接続前に反映する（保守用コマンドは実行中のボットと並行するため対象外）
+	if c.Config.Database.Driver != database.DriverPostgres && !c.maintenance {
+		restored, err := database.ApplyPendingRestore(c.Config.Database.Path)
+		if err != nil {
+			c.Log.Error("Failed to restore database backup", "backup", restored, "error", err)
+		} else if restored != "" {
+			c.Log.Warn("Database restored from backup", "backup", restored)
+		}
+	}
+
 	db, err := database.Connect(c.Config.Database)
 	if err != nil {
 		return err
 	}
 
 	c.DB = db
-	c.DatabaseService = database.NewService(db)
+	c.DatabaseService = database.NewService(db, c.Log)
 
-	if err := c.DatabaseService.Migrate(); err != nil {
-		return err
+	// 保守用コンテナは実行中のボットと並行するため、マイグレーションは luna migrate up でのみ行う
+	if c.maintenance {
+		return nil
 	}
-
-	return nil
+	return c.DatabaseService.Migrate()
 }
 
 func (c *Container) initDiscordSession() error {
-	session, err := discordgo.New("Bot " + c.Config.Discord.Token)
+	shards, err := shard.NewManager(c.Config.Discord, c.Log)
 	if err != nil {
 		return err
 	}
 
-	session.Identify.Intents = discordgo.IntentsAll
+	// インテントは各モジュールの初期化後に initIntents で設定
+	shards.SetIntents(discordgo.IntentsNone)
 
-	c.Session = session
+	c.Shards = shards
+	// REST呼び出しには代表シャードのセッションを使用
+	c.Session = shards.Primary()
 	return nil
 }
 
-func (c *Container) initServices() {
-	c.Bot = bot.New(c.Session, c.Config, c.DatabaseService)
-	c.Logger = logging.NewLogger(c.Session, c.Config, c.DatabaseService)
-	c.BumpHandler = bump.NewHandler(c.Session, c.DatabaseService)
-	
-	// ハンドラーを登録
-	c.Logger.RegisterHandlers()
-	c.BumpHandler.RegisterHandlers()
-	
-	// 起動時に保留中のBumpリマインダーをチェック
-	go c.BumpHandler.CheckPendingReminders()
-}
+func (c *Container) initServices() error {
+	// ギルドで無効にされたモジュールのイベントはワーカーで破棄する
+	c.Features = features.NewGate(c.DatabaseService, c.Log)
+	c.Pool = worker.NewPool(c.Config.Worker, c.Log)
+	c.Pool.SetFilter(c.Features.Allow)
+	c.Pool.Start()
+
+	// デバッグ用のイベント記録（他のハンドラーより先に登録）
+	if c.Config.Recorder.Enabled && !c.maintenance {
+		recorder, err := replay.NewRecorder(c.Config.Recorder, c.Log)
+		if err != nil {
+			return err
+		}
+		recorder.Attach(c.Shards)
+		c.Recorder = recorder
+	}
 
-func (c *Container) initAIService() error {
-	aiService, err := ai.NewService(&c.Config.GoogleCloud)
-	if err != nil {
-		return err
+	// メッセージごとのユーザー更新・かっこ集計はまとめて書き込む
+	c.WriteBuffer = database.NewWriteBuffer(c.DatabaseService, c.Config.Database, c.Log)
+	c.WriteBuffer.Start()
+
+	// SQLite の定期バックアップ（PostgreSQL では pg_dump を使用する）。
+	// 保守用コンテナでも /backup コマンドを登録するために作成するが、定期実行は開始しない。
+	if c.Config.Backup.Enabled && c.Config.Database.Driver != database.DriverPostgres {
+		c.Backup = backup.NewScheduler(c.Config.Backup, c.DatabaseService, c.Lifecycle, c.Log)
+		if !c.maintenance {
+			c.Backup.Start()
+		}
 	}
-	c.AIService = aiService
+
+	// 保持期間を過ぎたコマンド履歴などの削除（モジュールが削除処理を追加するため、開始は initModules の後）
+	c.Retention = retention.NewJanitor(c.Config.Retention, c.DatabaseService, c.Lifecycle, c.Log)
+
+	c.Bot = bot.New(c.Shards, c.Config, c.DatabaseService, c.WriteBuffer, c.Pool, c.Log)
+
 	return nil
 }
 
-func (c *Container) initGeminiStudio() error {
-	geminiStudio := ai.NewGeminiStudioService(
-		c.Config.GoogleCloud.StudioAPIKey,
-		c.Config.GoogleCloud.GeminiModel,
-	)
-	c.GeminiStudio = geminiStudio
-	return nil
+func (c *Container) initCommands() {
+	c.CommandRegistry = commands.NewRegistry(c.Shards, c.Config, c.DatabaseService, c.WriteBuffer, c.Pool, c.Features, c.Log)
 }
 
-func (c *Container) initVertexGemini() error {
-	vertexGemini, err := ai.NewVertexGeminiService(&c.Config.GoogleCloud)
+// initInteractions はHTTPインタラクションエンドポイントを作成します（有効な場合のみ）
+func (c *Container) initInteractions() error {
+	if !c.Config.Interactions.Enabled {
+		return nil
+	}
+
+	server, err := interactions.NewServer(c.Config.Interactions, c.Session, c.CommandRegistry, c.Log)
 	if err != nil {
 		return err
 	}
-	c.VertexGemini = vertexGemini
+	c.Interactions = server
 	return nil
 }
 
-func (c *Container) initCommands() {
-	c.CommandRegistry = commands.NewRegistry(c.Session, c.Config, c.DatabaseService)
-	
-	c.CommandRegistry.Register(commands.NewPingCommand())
-	c.CommandRegistry.Register(commands.NewAvatarCommand())
-	c.CommandRegistry.Register(commands.NewConfigCommand())
-	c.CommandRegistry.Register(commands.NewEmbedBuilderCommand())
-	c.CommandRegistry.Register(commands.NewActivityCommand(c.DatabaseService))
-	c.CommandRegistry.Register(commands.NewLockdownCommand())
-	c.CommandRegistry.Register(commands.NewPurgeCommand())
-	
-	// AI コマンドの登録
-	if c.VertexGemini != nil {
-		// 新しいVertex AI Gemini API使用時
-		c.CommandRegistry.Register(commands.NewAICommandWithVertex(c.VertexGemini))
-		// Imagenコマンドは旧APIが必要、GeminiStudioが使える場合は日本語翻訳対応
+// registerShutdownHooks は停止処理を登録します。フックは逆順に実行されるため、
+// ゲートウェイ切断 → HTTPエンドポイント停止 → 実行中タスクの完了待ち → AIクライアント → 書き込みバッファ → データベースの順に停止します。
+func (c *Container) registerShutdownHooks() {
+	c.Lifecycle.OnStop("database", func(ctx context.Context) error {
+		return c.DatabaseService.Close()
+	})
+
+	// 実行中タスクが予約した書き込みを、データベースを閉じる前に反映する
+	c.Lifecycle.OnStop("write buffer", c.WriteBuffer.Close)
+	if c.MessageStore != nil {
+		c.Lifecycle.OnStop("message store", c.MessageStore.Close)
+	}
+
+	if c.Backup != nil {
+		c.Lifecycle.OnStop("backup scheduler", c.Backup.Stop)
+	}
+	c.Lifecycle.OnStop("retention", c.Retention.Stop)
+
+	if c.Recorder != nil {
+		c.Lifecycle.OnStop("event recorder", func(ctx context.Context) error {
+			return c.Recorder.Close()
+		})
+	}
+
+	c.Lifecycle.OnStop("ai clients", func(ctx context.Context) error {
 		if c.AIService != nil {
-			if c.GeminiStudio != nil {
-				c.CommandRegistry.Register(commands.NewImageCommandWithGemini(c.AIService, c.GeminiStudio))
-			} else {
-				c.CommandRegistry.Register(commands.NewImageCommand(c.AIService))
-			}
+			c.AIService.Close()
 		}
-	} else if c.AIService != nil {
-		// 旧Vertex AI使用時
-		c.CommandRegistry.Register(commands.NewAICommand(c.AIService))
-		// GeminiStudioが使える場合は日本語翻訳対応のImageCommand
-		if c.GeminiStudio != nil {
-			c.CommandRegistry.Register(commands.NewImageCommandWithGemini(c.AIService, c.GeminiStudio))
-		} else {
-			c.CommandRegistry.Register(commands.NewImageCommand(c.AIService))
+		if c.VertexGemini != nil {
+			c.VertexGemini.Close()
 		}
-	} else if c.GeminiStudio != nil {
-		// Google AI Studio使用時（askコマンドのみ、imageは非対応）
-		c.CommandRegistry.Register(commands.NewAICommandWithStudio(c.GeminiStudio))
-	}
-	
-	// OCR・翻訳コマンドの登録
-	if c.VertexGemini != nil && c.GeminiStudio != nil {
-		c.CommandRegistry.Register(commands.NewOCRCommandWithBoth(c.GeminiStudio, c.VertexGemini))
-		c.CommandRegistry.Register(commands.NewTranslateCommand(c.GeminiStudio))
-	} else if c.VertexGemini != nil {
-		c.CommandRegistry.Register(commands.NewOCRCommandWithVertex(c.VertexGemini))
-	} else if c.GeminiStudio != nil {
-		c.CommandRegistry.Register(commands.NewOCRCommand(c.GeminiStudio))
-		c.CommandRegistry.Register(commands.NewTranslateCommand(c.GeminiStudio))
-	}
-	
-	// War Thunder コマンドの登録
-	c.CommandRegistry.Register(commands.NewWTCommand())
-	
-	// Brackets コマンドの登録
-	c.CommandRegistry.Register(commands.NewBracketsCommand(c.DatabaseService))
-}
+		return nil
+	})
 
-func (c *Container) Cleanup() error {
-	if c.Session != nil {
-		c.Session.Close()
-	}
+	c.Lifecycle.OnStop("worker pool", c.Pool.Shutdown)
 
-	if c.DB != nil {
-		c.DB.Close()
-	}
-	
-	if c.AIService != nil {
-		c.AIService.Close()
-	}
-	
-	if c.VertexGemini != nil {
-		c.VertexGemini.Close()
+	if c.Interactions != nil {
+		c.Lifecycle.OnStop("interactions endpoint", c.Interactions.Shutdown)
 	}
 
-	return nil
-}
\ No newline at end of file
+	c.Lifecycle.OnStop("gateway", func(ctx context.Context) error {
+		return c.Bot.Stop()
+	})
+}
+
+// Cleanup は新しいイベントの受付を止め、実行中の処理を待ってから全サービスを停止します
+func (c *Container) Cleanup() error {
+	return c.Lifecycle.Shutdown()
+}
@@ -0,0 +1,55 @@
+package di
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Developer Portal で有効化が必要な特権インテントと、それを示すアプリケーションフラグ
+var privilegedIntents = []struct {
+	intent discordgo.Intent
+	name   string
+	flags  int // GATEWAY_* と GATEWAY_*_LIMITED
+}{
+	{discordgo.IntentGuildPresences, "PRESENCE", 1<<12 | 1<<13},
+	{discordgo.IntentGuildMembers, "SERVER MEMBERS", 1<<14 | 1<<15},
+	{discordgo.IntentMessageContent, "MESSAGE CONTENT", 1<<18 | 1<<19},
+}
+
+// initIntents は組み込まれたモジュールが必要とするインテントだけをセッションに設定します。
+// アプリケーションで許可されていない特権インテントは、必要とするモジュールを警告したうえで除外します
+// （そのまま接続すると 4014 Disallowed intents で切断されるため）。
+func (c *Container) initIntents() {
+	var intents discordgo.Intent
+	for _, m := range c.modules {
+		intents |= m.intents
+	}
+
+	app, err := c.Session.Application("@me")
+	if err != nil {
+		c.Log.Warn("Could not verify privileged intents", "error", err)
+		c.Shards.SetIntents(intents)
+		return
+	}
+
+	for _, p := range privilegedIntents {
+		if intents&p.intent == 0 || app.Flags&p.flags != 0 {
+			continue
+		}
+
+		var needed []string
+		for _, m := range c.modules {
+			if m.intents&p.intent != 0 {
+				needed = append(needed, m.name)
+			}
+		}
+		c.Log.Warn("Privileged intent is not enabled in the Developer Portal; these modules will not receive the related events",
+			"intent", p.name, "modules", strings.Join(needed, ", "))
+		intents &^= p.intent
+	}
+
+	c.Log.Info("Gateway intents configured", "intents", int(intents))
+	c.Shards.SetIntents(intents)
+}
+
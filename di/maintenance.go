@@ -0,0 +1,46 @@
+package di
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/lifecycle"
+	"github.com/Sumire-Labs/Luna/shard"
+)
+
+// NewMaintenanceContainer はゲートウェイに接続せずに保守作業を行うためのコンテナを作成します。
+// データベース・REST用セッション・コマンドは通常どおり用意しますが、
+// マイグレーション、Bumpリマインダーの送信、HTTPインタラクション、イベント記録、設定の監視は行いません。
+func NewMaintenanceContainer(cfg *config.Config, logger *slog.Logger) (*Container, error) {
+	container := &Container{
+		Config:      cfg,
+		Log:         logger,
+		Lifecycle:   lifecycle.NewManager(time.Duration(cfg.Bot.ShutdownTimeout)*time.Second, logger),
+		maintenance: true,
+	}
+
+	if err := container.initDatabase(); err != nil {
+		return nil, err
+	}
+
+	// シャード数の取得は不要なため、REST用のセッションを1つだけ作成する
+	session, err := discordgo.New("Bot " + cfg.Discord.Token)
+	if err != nil {
+		return nil, err
+	}
+	container.Session = session
+	container.Shards = shard.NewManagerFromSessions(session)
+
+	if err := container.initServices(); err != nil {
+		return nil, err
+	}
+	container.initCommands()
+	if err := container.initModules(); err != nil {
+		return nil, err
+	}
+	container.registerShutdownHooks()
+
+	return container, nil
+}
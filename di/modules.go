@@ -0,0 +1,241 @@
+package di
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/ai"
+	"github.com/Sumire-Labs/Luna/bump"
+	"github.com/Sumire-Labs/Luna/cleanup"
+	"github.com/Sumire-Labs/Luna/commands"
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/logging"
+	"github.com/Sumire-Labs/Luna/retention"
+)
+
+// module は機能単位でハンドラー・コマンド・インテント・マイグレーションをまとめたものです。
+// config.toml で有効な場合のみ setup が呼ばれ、description があるモジュールはギルドごとに無効にできます。
+type module struct {
+	name        string
+	description string
+	enabled     bool
+	schema      database.Schema
+	// setup はハンドラーとコマンドを登録し、必要なゲートウェイインテントを返します
+	setup func() discordgo.Intent
+
+	intents discordgo.Intent
+}
+
+func (c *Container) moduleTable(features config.FeaturesConfig) []*module {
+	return []*module{
+		{name: "core", enabled: true, setup: c.setupCore},
+		{name: "bump", description: "Bumpリマインダー", enabled: true, setup: c.setupBump},
+		{name: "logging", description: "サーバーログ", enabled: features.EnableLogging, setup: c.setupLogging},
+		{name: "tickets", description: "チケットシステム", enabled: features.EnableTickets, schema: database.TicketSchema, setup: c.setupTickets},
+		{name: "moderation", description: "ロックダウン・メッセージ一括削除", enabled: features.EnableModeration, setup: c.setupModeration},
+		{name: "ai", description: "AIアシスタント・画像生成・OCR・翻訳", enabled: features.EnableAI, setup: c.setupAI},
+	}
+}
+
+// initModules は有効なモジュールのマイグレーションを実行し、ハンドラーとコマンドを登録します
+func (c *Container) initModules() error {
+	for _, m := range c.moduleTable(c.Config.Features) {
+		if m.description != "" {
+			c.Features.Define(m.name, m.description, m.enabled)
+		}
+		if !m.enabled {
+			c.Log.Info("Module disabled", "module", m.name)
+			continue
+		}
+
+		// 保守用コンテナは実行中のボットと並行するため、マイグレーションは luna migrate up でのみ行う
+		if !c.maintenance {
+			if err := c.DatabaseService.MigrateModule(m.name, m.schema); err != nil {
+				return err
+			}
+		}
+		m.intents = m.setup()
+		c.modules = append(c.modules, m)
+	}
+	return nil
+}
+
+// EnabledModules は config.toml で有効なモジュール名を返します
+func EnabledModules(cfg *config.Config) []string {
+	var names []string
+	for _, m := range (&Container{}).moduleTable(cfg.Features) {
+		if m.enabled {
+			names = append(names, m.name)
+		}
+	}
+	return names
+}
+
+// ModuleMigrations は config.toml で有効なモジュールのスキーマを返します（core を含む）
+func ModuleMigrations(cfg *config.Config) map[string]database.Schema {
+	migrations := map[string]database.Schema{"core": database.CoreSchema}
+	for _, m := range (&Container{}).moduleTable(cfg.Features) {
+		if m.enabled && len(m.schema.For(cfg.Database.Driver)) > 0 {
+			migrations[m.name] = m.schema
+		}
+	}
+	return migrations
+}
+
+// wired はモジュールが起動時に組み込まれたかを返します
+func (c *Container) wired(name string) bool {
+	for _, m := range c.modules {
+		if m.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Container) setupCore() discordgo.Intent {
+	c.CommandRegistry.Register(commands.NewPingCommand())
+	c.CommandRegistry.Register(commands.NewAvatarCommand())
+	c.CommandRegistry.Register(commands.NewConfigCommand(c.DatabaseService, c.Features))
+	c.CommandRegistry.Register(commands.NewEmbedBuilderCommand())
+	c.CommandRegistry.Register(commands.NewActivityCommand(c.DatabaseService))
+	c.CommandRegistry.Register(commands.NewModuleCommand(c.Features))
+	c.CommandRegistry.Register(commands.NewPrivacyCommand(c.DatabaseService, c.WriteBuffer))
+	if c.Backup != nil {
+		c.CommandRegistry.Register(commands.NewBackupCommand(c.Backup, c.Config.Database.Path, c.Config.Bot.Owners))
+	}
+
+	// War Thunder コマンドの登録
+	c.CommandRegistry.Register(commands.NewWTCommand())
+
+	// Brackets コマンドの登録
+	c.CommandRegistry.Register(commands.NewBracketsCommand(c.DatabaseService))
+
+	// 削除されたギルド・チャンネル・ロールの後始末
+	c.CleanupHandler = cleanup.NewHandler(c.Shards, c.DatabaseService, c.Pool, c.Log)
+	c.CleanupHandler.RegisterHandlers()
+
+	return c.Bot.Intents() | c.CommandRegistry.Intents() | c.CleanupHandler.Intents()
+}
+
+func (c *Container) setupBump() discordgo.Intent {
+	c.BumpHandler = bump.NewHandler(c.Shards, c.DatabaseService, c.Pool, c.Lifecycle)
+	c.BumpHandler.RegisterHandlers()
+
+	// 起動時に保留中のBumpリマインダーをチェック（保守用コンテナではリマインダーを送信しない）
+	if !c.maintenance {
+		go c.BumpHandler.CheckPendingReminders()
+	}
+
+	return c.BumpHandler.Intents()
+}
+
+func (c *Container) setupLogging() discordgo.Intent {
+	// 編集・削除ログ用のメッセージの保存（保守用コンテナではメッセージを受信しないため開かない）
+	if c.Config.MessageCache.Persist && !c.maintenance {
+		key, _ := c.Config.MessageCache.Key() // 鍵の形式は設定の読み込み時に検証済み
+		store, err := database.OpenMessageStore(c.Config.MessageCache.Path, key, c.Log)
+		if err != nil {
+			c.Log.Error("Failed to open message store, keeping messages in memory only", "path", c.Config.MessageCache.Path, "error", err)
+		} else {
+			store.Start()
+			c.MessageStore = store
+		}
+	}
+
+	c.Logger = logging.NewLogger(c.Shards, c.Config, c.DatabaseService, c.MessageStore, c.Pool, c.Log)
+	c.Logger.RegisterHandlers()
+
+	// /privacy とギルドのデータ削除で、保存しているメッセージも扱う
+	c.DatabaseService.SetMessageArchive(c.Logger.MessageCache())
+	c.Retention.Add(retention.Task{Name: "message_cache", Run: c.Logger.PruneMessages})
+
+	return c.Logger.Intents()
+}
+
+// setupTickets はチケット用のテーブルを用意します（チケットの設定画面は /config に含まれる）
+func (c *Container) setupTickets() discordgo.Intent {
+	return commands.TicketIntents
+}
+
+func (c *Container) setupModeration() discordgo.Intent {
+	lockdown := commands.NewLockdownCommand(c.Shards, c.DatabaseService, c.Lifecycle, c.Log)
+	c.CommandRegistry.RegisterModule("moderation", lockdown)
+	c.CommandRegistry.RegisterModule("moderation", commands.NewPurgeCommand())
+
+	// 再起動前に予約された自動解除を再開（保守用コンテナでは解除しない）
+	if !c.maintenance {
+		go lockdown.CheckPendingLockdowns()
+	}
+	return discordgo.IntentGuilds
+}
+
+func (c *Container) setupAI() discordgo.Intent {
+	cfg := c.Config.GoogleCloud
+
+	// AI Service の初期化（オプション）
+	if cfg.UseStudioAPI && cfg.StudioAPIKey != "" {
+		// Google AI Studio API を優先
+		c.GeminiStudio = ai.NewGeminiStudioService(cfg.StudioAPIKey, cfg.GeminiModel)
+	} else if cfg.ProjectID != "" {
+		// 新しいVertex AI Gemini APIを使用
+		vertexGemini, err := ai.NewVertexGeminiService(&c.Config.GoogleCloud)
+		if err != nil {
+			c.Log.Warn("Vertex AI Gemini service initialization failed", "error", err)
+		} else {
+			c.VertexGemini = vertexGemini
+		}
+		// Imagen用に旧APIも初期化
+		aiService, err := ai.NewService(&c.Config.GoogleCloud)
+		if err != nil {
+			c.Log.Warn("Vertex AI service initialization failed", "error", err)
+		} else {
+			c.AIService = aiService
+		}
+	}
+
+	register := func(cmd commands.Command) {
+		c.CommandRegistry.RegisterModule("ai", cmd)
+	}
+
+	// AI コマンドの登録
+	if c.VertexGemini != nil {
+		// 新しいVertex AI Gemini API使用時
+		register(commands.NewAICommandWithVertex(c.VertexGemini))
+		// Imagenコマンドは旧APIが必要、GeminiStudioが使える場合は日本語翻訳対応
+		if c.AIService != nil {
+			if c.GeminiStudio != nil {
+				register(commands.NewImageCommandWithGemini(c.AIService, c.GeminiStudio))
+			} else {
+				register(commands.NewImageCommand(c.AIService))
+			}
+		}
+	} else if c.AIService != nil {
+		// 旧Vertex AI使用時
+		register(commands.NewAICommand(c.AIService))
+		// GeminiStudioが使える場合は日本語翻訳対応のImageCommand
+		if c.GeminiStudio != nil {
+			register(commands.NewImageCommandWithGemini(c.AIService, c.GeminiStudio))
+		} else {
+			register(commands.NewImageCommand(c.AIService))
+		}
+	} else if c.GeminiStudio != nil {
+		// Google AI Studio使用時（askコマンドのみ、imageは非対応）
+		register(commands.NewAICommandWithStudio(c.GeminiStudio))
+	}
+
+	// OCR・翻訳コマンドの登録
+	if c.VertexGemini != nil && c.GeminiStudio != nil {
+		register(commands.NewOCRCommandWithBoth(c.GeminiStudio, c.VertexGemini))
+		register(commands.NewTranslateCommand(c.GeminiStudio))
+	} else if c.VertexGemini != nil {
+		register(commands.NewOCRCommandWithVertex(c.VertexGemini))
+	} else if c.GeminiStudio != nil {
+		register(commands.NewOCRCommand(c.GeminiStudio))
+		register(commands.NewTranslateCommand(c.GeminiStudio))
+	}
+
+	if c.GeminiStudio == nil && c.VertexGemini == nil && c.AIService == nil {
+		c.Log.Warn("AI module is enabled but no AI backend is configured in [google_cloud]")
+		return 0
+	}
+	return commands.AIIntents
+}
@@ -0,0 +1,47 @@
+package di
+
+import (
+	"github.com/Sumire-Labs/Luna/config"
+)
+
+// initConfigReload は設定ファイルの監視を開始し、再起動なしで反映できる変更を各モジュールに適用します
+func (c *Container) initConfigReload() {
+	c.ConfigWatcher = config.Watch(c.Config, c.Log)
+	c.ConfigWatcher.Subscribe(func(old, new *config.Config) {
+		c.Bot.ApplyConfig(new)
+		c.applyAIModels(old.GoogleCloud, new.GoogleCloud)
+		c.applyFeatures(new.Features)
+	})
+}
+
+func (c *Container) applyAIModels(old, new config.GoogleCloudConfig) {
+	if old.GeminiModel == new.GeminiModel && old.ImagenModel == new.ImagenModel {
+		return
+	}
+
+	if c.GeminiStudio != nil {
+		c.GeminiStudio.SetModel(new.GeminiModel)
+	}
+	if c.VertexGemini != nil {
+		c.VertexGemini.SetModel(new.GeminiModel)
+	}
+	if c.AIService != nil {
+		c.AIService.SetModels(new.GeminiModel, new.ImagenModel)
+	}
+	c.Log.Info("AI models updated", "gemini_model", new.GeminiModel, "imagen_model", new.ImagenModel)
+}
+
+// applyFeatures は機能フラグの変更を反映します。無効化はすぐに反映されますが、
+// 起動時に組み込まれていないモジュールの有効化にはハンドラーとインテントの登録が必要なため再起動が必要です。
+func (c *Container) applyFeatures(features config.FeaturesConfig) {
+	for _, m := range c.moduleTable(features) {
+		if m.description == "" {
+			continue
+		}
+		if m.enabled && !c.wired(m.name) {
+			c.Log.Warn("Module was enabled in config; restart the bot to load it", "module", m.name)
+			continue
+		}
+		c.Features.SetGlobal(m.name, m.enabled)
+	}
+}
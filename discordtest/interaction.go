@@ -0,0 +1,169 @@
+package discordtest
+
+import (
+	"encoding/json"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// InteractionRecord はインタラクションに対するボットの応答の記録です
+type InteractionRecord struct {
+	Interaction *discordgo.Interaction
+	// ResponseType は最初の応答の種類です（未応答の場合は 0）
+	ResponseType discordgo.InteractionResponseType
+	// Response は最初の応答の data をメッセージとして読み込んだものです
+	Response *discordgo.Message
+	// Data は最初の応答の data です（モーダルの custom_id などの確認に使用）
+	Data json.RawMessage
+	// Original は @original メッセージの現在の内容です
+	Original *discordgo.Message
+	// Followups はフォローアップメッセージです
+	Followups []*discordgo.Message
+}
+
+// Ephemeral は最初の応答がエフェメラルかを返します
+func (r *InteractionRecord) Ephemeral() bool {
+	return r.Response != nil && r.Response.Flags&discordgo.MessageFlagsEphemeral != 0
+}
+
+// Interaction はインタラクションへの応答記録を返します
+func (s *Server) Interaction(interactionID string) *InteractionRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range s.interactions {
+		if record.Interaction.ID == interactionID {
+			return record
+		}
+	}
+	return nil
+}
+
+// CommandInteraction はスラッシュコマンドのインタラクションを作成します
+func (s *Server) CommandInteraction(guildID, channelID string, user *discordgo.User, name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	data := discordgo.ApplicationCommandInteractionData{
+		Name:     name,
+		Options:  options,
+		Resolved: &discordgo.ApplicationCommandInteractionDataResolved{},
+	}
+
+	// ユーザーオプションは Discord と同様に resolved にも含める
+	for _, opt := range options {
+		if opt.Type == discordgo.ApplicationCommandOptionUser {
+			s.resolveUser(data.Resolved, opt.Value.(string))
+		}
+	}
+
+	return s.newInteraction(discordgo.InteractionApplicationCommand, guildID, channelID, user, data, nil)
+}
+
+// ComponentInteraction はボタンやセレクトメニューのインタラクションを作成します
+func (s *Server) ComponentInteraction(guildID, channelID string, user *discordgo.User, message *discordgo.Message, customID string, values ...string) *discordgo.InteractionCreate {
+	componentType := discordgo.ButtonComponent
+	if len(values) > 0 {
+		componentType = discordgo.SelectMenuComponent
+	}
+
+	data := discordgo.MessageComponentInteractionData{
+		CustomID:      customID,
+		ComponentType: componentType,
+		Values:        values,
+	}
+	return s.newInteraction(discordgo.InteractionMessageComponent, guildID, channelID, user, data, message)
+}
+
+// ModalSubmitInteraction はモーダル送信のインタラクションを作成します。fields はテキスト入力の custom_id と値です。
+func (s *Server) ModalSubmitInteraction(guildID, channelID string, user *discordgo.User, customID string, fields map[string]string) *discordgo.InteractionCreate {
+	data := discordgo.ModalSubmitInteractionData{CustomID: customID}
+	for id, value := range fields {
+		data.Components = append(data.Components, &discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				&discordgo.TextInput{CustomID: id, Value: value},
+			},
+		})
+	}
+	return s.newInteraction(discordgo.InteractionModalSubmit, guildID, channelID, user, data, nil)
+}
+
+// resolveUser はユーザーIDに対応するユーザーを resolved に追加します
+func (s *Server) resolveUser(resolved *discordgo.ApplicationCommandInteractionDataResolved, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return
+	}
+	if resolved.Users == nil {
+		resolved.Users = make(map[string]*discordgo.User)
+	}
+	resolved.Users[userID] = user
+}
+
+func (s *Server) newInteraction(interactionType discordgo.InteractionType, guildID, channelID string, user *discordgo.User, data discordgo.InteractionData, message *discordgo.Message) *discordgo.InteractionCreate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := &discordgo.Interaction{
+		ID:        s.nextID(),
+		AppID:     s.ApplicationID,
+		Type:      interactionType,
+		Data:      data,
+		GuildID:   guildID,
+		ChannelID: channelID,
+		Message:   message,
+		Token:     "token-" + s.nextID(),
+		Version:   1,
+	}
+
+	if guildID != "" {
+		member := s.members[guildID][user.ID]
+		if member == nil {
+			member = &discordgo.Member{GuildID: guildID, User: user}
+		}
+		i.Member = member
+	} else {
+		i.User = user
+	}
+
+	s.interactions[i.Token] = &InteractionRecord{Interaction: i}
+	return &discordgo.InteractionCreate{Interaction: i}
+}
+
+// StringOption などはコマンドオプションを作成します
+func StringOption(name, value string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionString, Value: value}
+}
+
+func IntOption(name string, value int64) *discordgo.ApplicationCommandInteractionDataOption {
+	// JSON で受信した場合と同じく数値は float64 で保持される
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionInteger, Value: float64(value)}
+}
+
+func BoolOption(name string, value bool) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionBoolean, Value: value}
+}
+
+func UserOption(name, userID string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionUser, Value: userID}
+}
+
+func ChannelOption(name, channelID string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionChannel, Value: channelID}
+}
+
+func RoleOption(name, roleID string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionRole, Value: roleID}
+}
+
+// decodeMessage は送信内容のJSONをメッセージとして読み込みます（components の復元は discordgo に任せる）
+func decodeMessage(data []byte) (*discordgo.Message, error) {
+	var message discordgo.Message
+	if len(data) == 0 {
+		return &message, nil
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
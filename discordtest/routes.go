@@ -0,0 +1,636 @@
+package discordtest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord の JSON エラーコード
+const (
+	codeUnknownChannel     = 10003
+	codeUnknownGuild       = 10004
+	codeUnknownMember      = 10007
+	codeUnknownMessage     = 10008
+	codeUnknownRole        = 10011
+	codeUnknownUser        = 10013
+	codeUnknownWebhook     = 10015
+	codeUnknownInteraction = 10062
+	codeAlreadyResponded   = 40060
+	codeInvalidFormBody    = 50035
+)
+
+var apiPrefix = regexp.MustCompile(`^/api/v\d+`)
+
+type route struct {
+	method  string
+	pattern *regexp.Regexp
+	handle  func(s *Server, w http.ResponseWriter, r *http.Request, params []string)
+}
+
+func newRoute(method, pattern string, handle func(s *Server, w http.ResponseWriter, r *http.Request, params []string)) route {
+	return route{method: method, pattern: regexp.MustCompile("^" + pattern + "$"), handle: handle}
+}
+
+var routes = []route{
+	newRoute("GET", `/users/@me`, (*Server).getCurrentUser),
+	newRoute("GET", `/users/(\d+)`, (*Server).getUser),
+	newRoute("GET", `/guilds/(\d+)`, (*Server).getGuild),
+	newRoute("GET", `/guilds/(\d+)/audit-logs`, (*Server).getAuditLog),
+	newRoute("GET", `/guilds/(\d+)/channels`, (*Server).getGuildChannels),
+	newRoute("POST", `/guilds/(\d+)/channels`, (*Server).createGuildChannel),
+	newRoute("GET", `/guilds/(\d+)/members/(\d+)`, (*Server).getGuildMember),
+	newRoute("GET", `/guilds/(\d+)/roles`, (*Server).getGuildRoles),
+	newRoute("PATCH", `/guilds/(\d+)/roles/(\d+)`, (*Server).editGuildRole),
+	newRoute("GET", `/channels/(\d+)`, (*Server).getChannel),
+	newRoute("DELETE", `/channels/(\d+)`, (*Server).deleteChannel),
+	newRoute("GET", `/channels/(\d+)/messages`, (*Server).getMessages),
+	newRoute("POST", `/channels/(\d+)/messages`, (*Server).createMessage),
+	newRoute("POST", `/channels/(\d+)/messages/bulk-delete`, (*Server).bulkDeleteMessages),
+	newRoute("GET", `/channels/(\d+)/messages/(\d+)`, (*Server).getMessage),
+	newRoute("PATCH", `/channels/(\d+)/messages/(\d+)`, (*Server).editMessage),
+	newRoute("DELETE", `/channels/(\d+)/messages/(\d+)`, (*Server).deleteMessage),
+	newRoute("PUT", `/channels/(\d+)/permissions/(\d+)`, (*Server).setPermission),
+	newRoute("DELETE", `/channels/(\d+)/permissions/(\d+)`, (*Server).deletePermission),
+	newRoute("POST", `/channels/(\d+)/typing`, (*Server).noContent),
+	newRoute("POST", `/interactions/(\d+)/([^/]+)/callback`, (*Server).interactionCallback),
+	newRoute("GET", `/webhooks/(\d+)/([^/]+)/messages/@original`, (*Server).getOriginal),
+	newRoute("PATCH", `/webhooks/(\d+)/([^/]+)/messages/@original`, (*Server).editOriginal),
+	newRoute("DELETE", `/webhooks/(\d+)/([^/]+)/messages/@original`, (*Server).deleteOriginal),
+	newRoute("POST", `/webhooks/(\d+)/([^/]+)`, (*Server).createFollowup),
+	newRoute("PATCH", `/webhooks/(\d+)/([^/]+)/messages/(\d+)`, (*Server).editFollowup),
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := apiPrefix.ReplaceAllString(r.URL.Path, "")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{Method: r.Method, Path: path})
+
+	for _, rt := range routes {
+		if rt.method != r.Method {
+			continue
+		}
+		if match := rt.pattern.FindStringSubmatch(path); match != nil {
+			rt.handle(s, w, r, match[1:])
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, 0, "404: Not Found")
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "message": message})
+}
+
+// readPayload はリクエストボディを返します。添付ファイル付きの場合は payload_json を取り出します。
+func readPayload(r *http.Request) ([]byte, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+		return []byte(r.FormValue("payload_json")), nil
+	}
+	return io.ReadAll(r.Body)
+}
+
+func (s *Server) noContent(w http.ResponseWriter, r *http.Request, params []string) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getCurrentUser(w http.ResponseWriter, r *http.Request, params []string) {
+	writeJSON(w, s.BotUser)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request, params []string) {
+	user, ok := s.users[params[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeUnknownUser, "Unknown User")
+		return
+	}
+	writeJSON(w, user)
+}
+
+func (s *Server) getGuild(w http.ResponseWriter, r *http.Request, params []string) {
+	guild, ok := s.guilds[params[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeUnknownGuild, "Unknown Guild")
+		return
+	}
+	writeJSON(w, guild)
+}
+
+// getAuditLog はエントリを新しい順に返します（action_type と limit に対応）
+func (s *Server) getAuditLog(w http.ResponseWriter, r *http.Request, params []string) {
+	if _, ok := s.guilds[params[0]]; !ok {
+		writeError(w, http.StatusNotFound, codeUnknownGuild, "Unknown Guild")
+		return
+	}
+
+	actionType, _ := strconv.Atoi(r.URL.Query().Get("action_type"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	entries := s.auditLogs[params[0]]
+	result := []*discordgo.AuditLogEntry{}
+	for i := len(entries) - 1; i >= 0 && len(result) < limit; i-- {
+		if actionType != 0 && (entries[i].ActionType == nil || int(*entries[i].ActionType) != actionType) {
+			continue
+		}
+		result = append(result, entries[i])
+	}
+	writeJSON(w, &discordgo.GuildAuditLog{AuditLogEntries: result})
+}
+
+func (s *Server) getGuildChannels(w http.ResponseWriter, r *http.Request, params []string) {
+	if _, ok := s.guilds[params[0]]; !ok {
+		writeError(w, http.StatusNotFound, codeUnknownGuild, "Unknown Guild")
+		return
+	}
+	channels := s.guildChannelsLocked(params[0])
+	if channels == nil {
+		channels = []*discordgo.Channel{}
+	}
+	writeJSON(w, channels)
+}
+
+func (s *Server) createGuildChannel(w http.ResponseWriter, r *http.Request, params []string) {
+	if _, ok := s.guilds[params[0]]; !ok {
+		writeError(w, http.StatusNotFound, codeUnknownGuild, "Unknown Guild")
+		return
+	}
+
+	var data discordgo.GuildChannelCreateData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil || data.Name == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidFormBody, "Invalid Form Body")
+		return
+	}
+
+	channel := s.addChannelLocked(&discordgo.Channel{
+		GuildID:              params[0],
+		Name:                 data.Name,
+		Type:                 data.Type,
+		Topic:                data.Topic,
+		ParentID:             data.ParentID,
+		Position:             data.Position,
+		PermissionOverwrites: data.PermissionOverwrites,
+		NSFW:                 data.NSFW,
+	})
+	writeJSON(w, channel)
+}
+
+func (s *Server) getGuildMember(w http.ResponseWriter, r *http.Request, params []string) {
+	member, ok := s.members[params[0]][params[1]]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeUnknownMember, "Unknown Member")
+		return
+	}
+	writeJSON(w, member)
+}
+
+func (s *Server) getGuildRoles(w http.ResponseWriter, r *http.Request, params []string) {
+	guild, ok := s.guilds[params[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeUnknownGuild, "Unknown Guild")
+		return
+	}
+	writeJSON(w, guild.Roles)
+}
+
+func (s *Server) editGuildRole(w http.ResponseWriter, r *http.Request, params []string) {
+	guild, ok := s.guilds[params[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeUnknownGuild, "Unknown Guild")
+		return
+	}
+
+	var role *discordgo.Role
+	for _, rl := range guild.Roles {
+		if rl.ID == params[1] {
+			role = rl
+		}
+	}
+	if role == nil {
+		writeError(w, http.StatusNotFound, codeUnknownRole, "Unknown Role")
+		return
+	}
+
+	var data discordgo.RoleParams
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidFormBody, "Invalid Form Body")
+		return
+	}
+
+	if data.Name != "" {
+		role.Name = data.Name
+	}
+	if data.Color != nil {
+		role.Color = *data.Color
+	}
+	if data.Hoist != nil {
+		role.Hoist = *data.Hoist
+	}
+	if data.Permissions != nil {
+		role.Permissions = *data.Permissions
+	}
+	if data.Mentionable != nil {
+		role.Mentionable = *data.Mentionable
+	}
+
+	s.syncRole(guild.ID, role)
+	writeJSON(w, role)
+}
+
+func (s *Server) getChannel(w http.ResponseWriter, r *http.Request, params []string) {
+	channel, ok := s.channels[params[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeUnknownChannel, "Unknown Channel")
+		return
+	}
+	writeJSON(w, channel)
+}
+
+func (s *Server) deleteChannel(w http.ResponseWriter, r *http.Request, params []string) {
+	channel, ok := s.channels[params[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeUnknownChannel, "Unknown Channel")
+		return
+	}
+
+	delete(s.channels, channel.ID)
+	delete(s.messages, channel.ID)
+	for _, session := range s.sessions {
+		session.State.ChannelRemove(channel)
+	}
+	writeJSON(w, channel)
+}
+
+// getMessages は Discord と同様に新しい順でメッセージを返します
+func (s *Server) getMessages(w http.ResponseWriter, r *http.Request, params []string) {
+	if _, ok := s.channels[params[0]]; !ok {
+		writeError(w, http.StatusNotFound, codeUnknownChannel, "Unknown Channel")
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	before, _ := strconv.ParseUint(query.Get("before"), 10, 64)
+	after, _ := strconv.ParseUint(query.Get("after"), 10, 64)
+
+	messages := s.messages[params[0]]
+	result := []*discordgo.Message{}
+	for i := len(messages) - 1; i >= 0 && len(result) < limit; i-- {
+		id, _ := strconv.ParseUint(messages[i].ID, 10, 64)
+		if (before != 0 && id >= before) || (after != 0 && id <= after) {
+			continue
+		}
+		result = append(result, messages[i])
+	}
+	writeJSON(w, result)
+}
+
+func (s *Server) createMessage(w http.ResponseWriter, r *http.Request, params []string) {
+	if _, ok := s.channels[params[0]]; !ok {
+		writeError(w, http.StatusNotFound, codeUnknownChannel, "Unknown Channel")
+		return
+	}
+
+	payload, err := readPayload(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidFormBody, "Invalid Form Body")
+		return
+	}
+	message, err := decodeMessage(payload)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidFormBody, "Invalid Form Body")
+		return
+	}
+
+	message.ChannelID = params[0]
+	message.Author = s.BotUser
+	writeJSON(w, s.addMessageLocked(message))
+}
+
+func (s *Server) findMessage(channelID, messageID string) (*discordgo.Message, int) {
+	for i, message := range s.messages[channelID] {
+		if message.ID == messageID {
+			return message, i
+		}
+	}
+	return nil, -1
+}
+
+func (s *Server) removeMessageLocked(channelID, messageID string) bool {
+	_, index := s.findMessage(channelID, messageID)
+	if index < 0 {
+		return false
+	}
+	messages := s.messages[channelID]
+	s.messages[channelID] = append(messages[:index:index], messages[index+1:]...)
+	return true
+}
+
+func (s *Server) getMessage(w http.ResponseWriter, r *http.Request, params []string) {
+	message, _ := s.findMessage(params[0], params[1])
+	if message == nil {
+		writeError(w, http.StatusNotFound, codeUnknownMessage, "Unknown Message")
+		return
+	}
+	writeJSON(w, message)
+}
+
+func (s *Server) editMessage(w http.ResponseWriter, r *http.Request, params []string) {
+	message, _ := s.findMessage(params[0], params[1])
+	if message == nil {
+		writeError(w, http.StatusNotFound, codeUnknownMessage, "Unknown Message")
+		return
+	}
+	s.respondEdit(w, r, message)
+}
+
+func (s *Server) deleteMessage(w http.ResponseWriter, r *http.Request, params []string) {
+	if !s.removeMessageLocked(params[0], params[1]) {
+		writeError(w, http.StatusNotFound, codeUnknownMessage, "Unknown Message")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) bulkDeleteMessages(w http.ResponseWriter, r *http.Request, params []string) {
+	var data struct {
+		Messages []string `json:"messages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil || len(data.Messages) < 2 || len(data.Messages) > 100 {
+		writeError(w, http.StatusBadRequest, codeInvalidFormBody, "Invalid Form Body")
+		return
+	}
+
+	for _, id := range data.Messages {
+		s.removeMessageLocked(params[0], id)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setPermission(w http.ResponseWriter, r *http.Request, params []string) {
+	channel, ok := s.channels[params[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeUnknownChannel, "Unknown Channel")
+		return
+	}
+
+	var overwrite discordgo.PermissionOverwrite
+	if err := json.NewDecoder(r.Body).Decode(&overwrite); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidFormBody, "Invalid Form Body")
+		return
+	}
+	overwrite.ID = params[1]
+
+	replaced := false
+	for i, existing := range channel.PermissionOverwrites {
+		if existing.ID == overwrite.ID {
+			channel.PermissionOverwrites[i] = &overwrite
+			replaced = true
+		}
+	}
+	if !replaced {
+		channel.PermissionOverwrites = append(channel.PermissionOverwrites, &overwrite)
+	}
+
+	s.syncChannel(channel)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deletePermission(w http.ResponseWriter, r *http.Request, params []string) {
+	channel, ok := s.channels[params[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeUnknownChannel, "Unknown Channel")
+		return
+	}
+
+	var kept []*discordgo.PermissionOverwrite
+	for _, existing := range channel.PermissionOverwrites {
+		if existing.ID != params[1] {
+			kept = append(kept, existing)
+		}
+	}
+	channel.PermissionOverwrites = kept
+
+	s.syncChannel(channel)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) interactionCallback(w http.ResponseWriter, r *http.Request, params []string) {
+	record, ok := s.interactions[params[1]]
+	if !ok || record.Interaction.ID != params[0] {
+		writeError(w, http.StatusNotFound, codeUnknownInteraction, "Unknown interaction")
+		return
+	}
+	if record.ResponseType != 0 {
+		writeError(w, http.StatusBadRequest, codeAlreadyResponded, "Interaction has already been acknowledged.")
+		return
+	}
+
+	payload, err := readPayload(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidFormBody, "Invalid Form Body")
+		return
+	}
+
+	var resp struct {
+		Type discordgo.InteractionResponseType `json:"type"`
+		Data json.RawMessage                   `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidFormBody, "Invalid Form Body")
+		return
+	}
+	message, err := decodeMessage(resp.Data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidFormBody, "Invalid Form Body")
+		return
+	}
+
+	record.ResponseType = resp.Type
+	record.Response = message
+	record.Data = resp.Data
+
+	i := record.Interaction
+	switch resp.Type {
+	case discordgo.InteractionResponseChannelMessageWithSource,
+		discordgo.InteractionResponseDeferredChannelMessageWithSource:
+		original := *message
+		original.ChannelID = i.ChannelID
+		original.Author = s.BotUser
+		record.Original = s.postLocked(&original)
+	case discordgo.InteractionResponseUpdateMessage, discordgo.InteractionResponseDeferredMessageUpdate:
+		if i.Message != nil {
+			target, _ := s.findMessage(i.Message.ChannelID, i.Message.ID)
+			if target == nil {
+				target = i.Message
+			}
+			if resp.Type == discordgo.InteractionResponseUpdateMessage {
+				applyEdit(target, resp.Data)
+			}
+			record.Original = target
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postLocked はインタラクションの応答メッセージを作成します。エフェメラルな応答はチャンネルには残りません。
+func (s *Server) postLocked(message *discordgo.Message) *discordgo.Message {
+	if message.Flags&discordgo.MessageFlagsEphemeral != 0 {
+		message.ID = s.nextID()
+		return message
+	}
+	return s.addMessageLocked(message)
+}
+
+func (s *Server) webhookRecord(w http.ResponseWriter, params []string) *InteractionRecord {
+	record, ok := s.interactions[params[1]]
+	if !ok || record.Interaction.AppID != params[0] {
+		writeError(w, http.StatusNotFound, codeUnknownWebhook, "Unknown Webhook")
+		return nil
+	}
+	return record
+}
+
+func (s *Server) getOriginal(w http.ResponseWriter, r *http.Request, params []string) {
+	record := s.webhookRecord(w, params)
+	if record == nil {
+		return
+	}
+	if record.Original == nil {
+		writeError(w, http.StatusNotFound, codeUnknownMessage, "Unknown Message")
+		return
+	}
+	writeJSON(w, record.Original)
+}
+
+func (s *Server) editOriginal(w http.ResponseWriter, r *http.Request, params []string) {
+	record := s.webhookRecord(w, params)
+	if record == nil {
+		return
+	}
+	if record.Original == nil {
+		writeError(w, http.StatusNotFound, codeUnknownMessage, "Unknown Message")
+		return
+	}
+	s.respondEdit(w, r, record.Original)
+}
+
+func (s *Server) deleteOriginal(w http.ResponseWriter, r *http.Request, params []string) {
+	record := s.webhookRecord(w, params)
+	if record == nil {
+		return
+	}
+	if record.Original == nil {
+		writeError(w, http.StatusNotFound, codeUnknownMessage, "Unknown Message")
+		return
+	}
+	s.removeMessageLocked(record.Original.ChannelID, record.Original.ID)
+	record.Original = nil
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) createFollowup(w http.ResponseWriter, r *http.Request, params []string) {
+	record := s.webhookRecord(w, params)
+	if record == nil {
+		return
+	}
+
+	payload, err := readPayload(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidFormBody, "Invalid Form Body")
+		return
+	}
+	message, err := decodeMessage(payload)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidFormBody, "Invalid Form Body")
+		return
+	}
+
+	message.ChannelID = record.Interaction.ChannelID
+	message.Author = s.BotUser
+	message = s.postLocked(message)
+	record.Followups = append(record.Followups, message)
+	writeJSON(w, message)
+}
+
+func (s *Server) editFollowup(w http.ResponseWriter, r *http.Request, params []string) {
+	record := s.webhookRecord(w, params)
+	if record == nil {
+		return
+	}
+
+	for _, message := range record.Followups {
+		if message.ID == params[2] {
+			s.respondEdit(w, r, message)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, codeUnknownMessage, "Unknown Message")
+}
+
+func (s *Server) respondEdit(w http.ResponseWriter, r *http.Request, message *discordgo.Message) {
+	payload, err := readPayload(r)
+	if err != nil || applyEdit(message, payload) != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidFormBody, "Invalid Form Body")
+		return
+	}
+	writeJSON(w, message)
+}
+
+// applyEdit はリクエストに含まれるフィールドだけをメッセージに反映します
+func applyEdit(message *discordgo.Message, payload []byte) error {
+	if len(payload) == 0 {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return err
+	}
+	edit, err := decodeMessage(payload)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := fields["content"]; ok {
+		message.Content = edit.Content
+	}
+	if _, ok := fields["embeds"]; ok {
+		message.Embeds = edit.Embeds
+	}
+	if _, ok := fields["components"]; ok {
+		message.Components = edit.Components
+	}
+	if _, ok := fields["flags"]; ok {
+		message.Flags = edit.Flags
+	}
+	return nil
+}
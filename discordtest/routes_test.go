@@ -0,0 +1,99 @@
+package discordtest
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestAuditLogFiltersByActionType(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	owner := srv.AddUser("owner", false)
+	guild := srv.AddGuild("Test", owner)
+	kick := discordgo.AuditLogActionMemberKick
+	ban := discordgo.AuditLogActionMemberBanAdd
+
+	first := srv.AddAuditLogEntry(guild.ID, &discordgo.AuditLogEntry{UserID: owner.ID, TargetID: "1", ActionType: &kick})
+	srv.AddAuditLogEntry(guild.ID, &discordgo.AuditLogEntry{UserID: owner.ID, TargetID: "2", ActionType: &ban})
+	last := srv.AddAuditLogEntry(guild.ID, &discordgo.AuditLogEntry{UserID: owner.ID, TargetID: "3", ActionType: &kick})
+
+	session := srv.Session()
+
+	log, err := session.GuildAuditLog(guild.ID, "", "", int(kick), 10)
+	if err != nil {
+		t.Fatalf("audit log: %v", err)
+	}
+	if len(log.AuditLogEntries) != 2 {
+		t.Fatalf("entries = %d, want 2", len(log.AuditLogEntries))
+	}
+	// Discord と同様に新しい順で返す
+	if log.AuditLogEntries[0].ID != last.ID || log.AuditLogEntries[1].ID != first.ID {
+		t.Errorf("entries are not ordered newest first")
+	}
+
+	log, err = session.GuildAuditLog(guild.ID, "", "", 0, 1)
+	if err != nil {
+		t.Fatalf("audit log: %v", err)
+	}
+	if len(log.AuditLogEntries) != 1 || log.AuditLogEntries[0].ID != last.ID {
+		t.Errorf("limit 1 returned %d entries, want the newest", len(log.AuditLogEntries))
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 2 || requests[0].Method != "GET" || requests[0].Path != "/guilds/"+guild.ID+"/audit-logs" {
+		t.Errorf("requests = %+v, want two audit log requests", requests)
+	}
+
+	if _, err := session.GuildAuditLog("1", "", "", 0, 10); err == nil {
+		t.Error("unknown guild should fail")
+	}
+}
+
+func TestChannelPermissionsAndMessages(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	owner := srv.AddUser("owner", false)
+	guild := srv.AddGuild("Test", owner)
+	channel := srv.AddChannel(guild.ID, "general", discordgo.ChannelTypeGuildText, "")
+	for _, content := range []string{"a", "b", "c"} {
+		srv.AddMessage(channel.ID, owner, content)
+	}
+
+	session := srv.Session()
+
+	messages, err := session.ChannelMessages(channel.ID, 2, "", "", "")
+	if err != nil {
+		t.Fatalf("messages: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Content != "c" || messages[1].Content != "b" {
+		t.Fatalf("messages are not the newest two in order")
+	}
+
+	if err := session.ChannelMessagesBulkDelete(channel.ID, []string{messages[0].ID, messages[1].ID}); err != nil {
+		t.Fatalf("bulk delete: %v", err)
+	}
+	if remaining := srv.Messages(channel.ID); len(remaining) != 1 || remaining[0].Content != "a" {
+		t.Errorf("remaining messages = %d, want only the oldest", len(remaining))
+	}
+
+	if err := session.ChannelPermissionSet(channel.ID, guild.ID, discordgo.PermissionOverwriteTypeRole, 0, discordgo.PermissionSendMessages); err != nil {
+		t.Fatalf("permission set: %v", err)
+	}
+	if overwrites := srv.Channel(channel.ID).PermissionOverwrites; len(overwrites) != 1 || overwrites[0].Deny != discordgo.PermissionSendMessages {
+		t.Fatalf("overwrites = %+v, want @everyone deny", overwrites)
+	}
+	// セッションのステートにも反映される
+	if state, err := session.State.Channel(channel.ID); err != nil || len(state.PermissionOverwrites) != 1 {
+		t.Error("state was not updated")
+	}
+
+	if err := session.ChannelPermissionDelete(channel.ID, guild.ID); err != nil {
+		t.Fatalf("permission delete: %v", err)
+	}
+	if overwrites := srv.Channel(channel.ID).PermissionOverwrites; len(overwrites) != 0 {
+		t.Errorf("overwrites = %d after delete, want 0", len(overwrites))
+	}
+}
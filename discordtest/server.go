@@ -0,0 +1,383 @@
+// Package discordtest はネットワークに接続せずにコマンドを実行するための、
+// Discord REST API の簡易的な代替サーバーを提供します。
+//
+// ギルド・チャンネル・メンバー・ロール・メッセージをメモリ上に保持し、
+// Session() で得たセッションの REST 呼び出しはすべてこのサーバーに送られます。
+//
+//	srv := discordtest.NewServer()
+//	defer srv.Close()
+//	guild := srv.AddGuild("Test", owner)
+//	channel := srv.AddChannel(guild.ID, "general", discordgo.ChannelTypeGuildText, "")
+//	i := srv.CommandInteraction(guild.ID, channel.ID, owner, "purge", discordtest.IntOption("amount", 10))
+//	cmd.Execute(commands.NewContext(srv.Session(), i))
+package discordtest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discordのスノーフレークの基準時刻 (2015-01-01)
+const discordEpoch = 1420070400000
+
+// Server はメモリ上にデータを持つ Discord REST API の代替サーバーです
+type Server struct {
+	mu sync.Mutex
+
+	httpServer *httptest.Server
+	sessions   []*discordgo.Session
+	sequence   int64
+
+	// BotUser はセッションのボットユーザーです
+	BotUser *discordgo.User
+	// ApplicationID はインタラクションに設定されるアプリケーションIDです
+	ApplicationID string
+
+	guilds       map[string]*discordgo.Guild
+	channels     map[string]*discordgo.Channel
+	members      map[string]map[string]*discordgo.Member
+	users        map[string]*discordgo.User
+	messages     map[string][]*discordgo.Message
+	auditLogs    map[string][]*discordgo.AuditLogEntry
+	interactions map[string]*InteractionRecord
+	requests     []Request
+}
+
+// Request は受信したリクエストの記録です
+type Request struct {
+	Method string
+	Path   string
+}
+
+// NewServer はローカルにHTTPサーバーを起動します。使用後は Close を呼んでください。
+func NewServer() *Server {
+	s := &Server{
+		guilds:       make(map[string]*discordgo.Guild),
+		channels:     make(map[string]*discordgo.Channel),
+		members:      make(map[string]map[string]*discordgo.Member),
+		users:        make(map[string]*discordgo.User),
+		messages:     make(map[string][]*discordgo.Message),
+		auditLogs:    make(map[string][]*discordgo.AuditLogEntry),
+		interactions: make(map[string]*InteractionRecord),
+	}
+
+	s.BotUser = &discordgo.User{ID: s.nextID(), Username: "Luna", Bot: true}
+	s.ApplicationID = s.BotUser.ID
+	s.users[s.BotUser.ID] = s.BotUser
+
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close はHTTPサーバーを停止します
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// URL はサーバーのベースURLを返します
+func (s *Server) URL() string {
+	return s.httpServer.URL
+}
+
+// Session はこのサーバーに接続されたセッションを返します。
+// ステートには現在のギルド・チャンネル・ロール・メンバーが読み込まれ、以降の変更も反映されます。
+func (s *Server) Session() *discordgo.Session {
+	session, err := discordgo.New("Bot discordtest")
+	if err != nil {
+		panic(err)
+	}
+
+	target, _ := url.Parse(s.httpServer.URL)
+	session.Client = &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &rewriteTransport{target: target, next: s.httpServer.Client().Transport},
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.State.User = s.BotUser
+	for _, guild := range s.guilds {
+		snapshot := *guild
+		snapshot.Channels = nil
+		snapshot.Members = nil
+		for _, channel := range s.channels {
+			if channel.GuildID == guild.ID {
+				snapshot.Channels = append(snapshot.Channels, channel)
+			}
+		}
+		for _, member := range s.members[guild.ID] {
+			snapshot.Members = append(snapshot.Members, member)
+		}
+		session.State.GuildAdd(&snapshot)
+	}
+
+	s.sessions = append(s.sessions, session)
+	return session
+}
+
+// rewriteTransport は discord.com 宛てのリクエストをローカルサーバーに転送します
+type rewriteTransport struct {
+	target *url.URL
+	next   http.RoundTripper
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	req.Host = t.target.Host
+	return t.next.RoundTrip(req)
+}
+
+// Requests はこれまでに受信したリクエストを返します
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// nextID は現在時刻に基づくスノーフレークIDを生成します（s.mu を保持して呼び出すこと）
+func (s *Server) nextID() string {
+	s.sequence++
+	ms := time.Now().UnixMilli() - discordEpoch
+	return fmt.Sprintf("%d", ms<<22|s.sequence&0xfff)
+}
+
+// AddUser はユーザーを登録します
+func (s *Server) AddUser(username string, bot bool) *discordgo.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := &discordgo.User{ID: s.nextID(), Username: username, Bot: bot}
+	s.users[user.ID] = user
+	return user
+}
+
+// AddGuild はギルドを作成します。@everyone ロールとボットのメンバー情報も作成されます。
+func (s *Server) AddGuild(name string, owner *discordgo.User) *discordgo.Guild {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID()
+	guild := &discordgo.Guild{
+		ID:      id,
+		Name:    name,
+		OwnerID: owner.ID,
+		Roles: []*discordgo.Role{{
+			ID:          id,
+			Name:        "@everyone",
+			Permissions: discordgo.PermissionViewChannel | discordgo.PermissionSendMessages | discordgo.PermissionAddReactions | discordgo.PermissionReadMessageHistory,
+		}},
+	}
+	s.guilds[id] = guild
+	s.users[owner.ID] = owner
+	s.members[id] = make(map[string]*discordgo.Member)
+
+	for _, session := range s.sessions {
+		session.State.GuildAdd(&discordgo.Guild{ID: id, Name: name, OwnerID: owner.ID, Roles: guild.Roles})
+	}
+
+	s.addMemberLocked(id, owner)
+	s.addMemberLocked(id, s.BotUser)
+	return guild
+}
+
+// ImportGuild は既存のギルド（GUILD_CREATE の内容など）をIDを保ったまま取り込みます
+func (s *Server) ImportGuild(guild *discordgo.Guild) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	imported := &discordgo.Guild{
+		ID:      guild.ID,
+		Name:    guild.Name,
+		OwnerID: guild.OwnerID,
+		Roles:   guild.Roles,
+	}
+	s.guilds[guild.ID] = imported
+	if s.members[guild.ID] == nil {
+		s.members[guild.ID] = make(map[string]*discordgo.Member)
+	}
+
+	for _, session := range s.sessions {
+		session.State.GuildAdd(&discordgo.Guild{ID: guild.ID, Name: guild.Name, OwnerID: guild.OwnerID, Roles: guild.Roles})
+	}
+
+	for _, channel := range guild.Channels {
+		channel.GuildID = guild.ID
+		s.channels[channel.ID] = channel
+		s.syncChannel(channel)
+	}
+	for _, member := range guild.Members {
+		if member.User == nil {
+			continue
+		}
+		member.GuildID = guild.ID
+		s.users[member.User.ID] = member.User
+		s.members[guild.ID][member.User.ID] = member
+		for _, session := range s.sessions {
+			session.State.MemberAdd(member)
+		}
+	}
+}
+
+// AddRole はギルドにロールを作成します
+func (s *Server) AddRole(guildID, name string, permissions int64) *discordgo.Role {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guild := s.guilds[guildID]
+	role := &discordgo.Role{
+		ID:          s.nextID(),
+		Name:        name,
+		Permissions: permissions,
+		Position:    len(guild.Roles),
+	}
+	guild.Roles = append(guild.Roles, role)
+	s.syncRole(guildID, role)
+	return role
+}
+
+// AddChannel はギルドにチャンネルを作成します
+func (s *Server) AddChannel(guildID, name string, channelType discordgo.ChannelType, parentID string) *discordgo.Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addChannelLocked(&discordgo.Channel{
+		GuildID:  guildID,
+		Name:     name,
+		Type:     channelType,
+		ParentID: parentID,
+	})
+}
+
+func (s *Server) addChannelLocked(channel *discordgo.Channel) *discordgo.Channel {
+	channel.ID = s.nextID()
+	s.channels[channel.ID] = channel
+	s.syncChannel(channel)
+	return channel
+}
+
+// AddMember はユーザーをギルドのメンバーとして追加します
+func (s *Server) AddMember(guildID string, user *discordgo.User, roleIDs ...string) *discordgo.Member {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[user.ID] = user
+	return s.addMemberLocked(guildID, user, roleIDs...)
+}
+
+func (s *Server) addMemberLocked(guildID string, user *discordgo.User, roleIDs ...string) *discordgo.Member {
+	member := &discordgo.Member{
+		GuildID:  guildID,
+		User:     user,
+		Roles:    roleIDs,
+		JoinedAt: time.Now(),
+	}
+	s.members[guildID][user.ID] = member
+	for _, session := range s.sessions {
+		session.State.MemberAdd(member)
+	}
+	return member
+}
+
+// AddMessage はチャンネルにメッセージを追加します
+func (s *Server) AddMessage(channelID string, author *discordgo.User, content string) *discordgo.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addMessageLocked(&discordgo.Message{
+		ChannelID: channelID,
+		Author:    author,
+		Content:   content,
+	})
+}
+
+func (s *Server) addMessageLocked(message *discordgo.Message) *discordgo.Message {
+	message.ID = s.nextID()
+	message.Timestamp = time.Now()
+	if channel, ok := s.channels[message.ChannelID]; ok {
+		message.GuildID = channel.GuildID
+	}
+	s.messages[message.ChannelID] = append(s.messages[message.ChannelID], message)
+	return message
+}
+
+// AddAuditLogEntry はギルドの監査ログにエントリを追加します（ID は現在時刻で採番されます）
+func (s *Server) AddAuditLogEntry(guildID string, entry *discordgo.AuditLogEntry) *discordgo.AuditLogEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.ID = s.nextID()
+	s.auditLogs[guildID] = append(s.auditLogs[guildID], entry)
+	return entry
+}
+
+// Guild はギルドの現在の状態を返します
+func (s *Server) Guild(guildID string) *discordgo.Guild {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.guilds[guildID]
+}
+
+// Guilds は登録されているギルドの一覧を返します
+func (s *Server) Guilds() []*discordgo.Guild {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guilds := make([]*discordgo.Guild, 0, len(s.guilds))
+	for _, guild := range s.guilds {
+		guilds = append(guilds, guild)
+	}
+	return guilds
+}
+
+// Channel はチャンネルの現在の状態を返します（削除済みの場合は nil）
+func (s *Server) Channel(channelID string) *discordgo.Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.channels[channelID]
+}
+
+// Channels はギルドのチャンネル一覧を返します
+func (s *Server) Channels(guildID string) []*discordgo.Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.guildChannelsLocked(guildID)
+}
+
+func (s *Server) guildChannelsLocked(guildID string) []*discordgo.Channel {
+	var channels []*discordgo.Channel
+	for _, channel := range s.channels {
+		if channel.GuildID == guildID {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// Messages はチャンネルのメッセージを古い順に返します
+func (s *Server) Messages(channelID string) []*discordgo.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*discordgo.Message(nil), s.messages[channelID]...)
+}
+
+// syncRole と syncChannel は作成済みセッションのステートに変更を反映します
+func (s *Server) syncRole(guildID string, role *discordgo.Role) {
+	for _, session := range s.sessions {
+		session.State.RoleAdd(guildID, role)
+	}
+}
+
+func (s *Server) syncChannel(channel *discordgo.Channel) {
+	for _, session := range s.sessions {
+		session.State.ChannelAdd(channel)
+	}
+}
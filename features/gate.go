@@ -0,0 +1,150 @@
+package features
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Sumire-Labs/Luna/database"
+)
+
+// Module はギルドごとに有効・無効を切り替えられる機能モジュールの情報です
+type Module struct {
+	Name        string
+	Description string
+	// Enabled は config.toml の features で有効になっているかどうかです
+	Enabled bool
+}
+
+// Gate はモジュールがギルドで有効かを判定します。
+// config.toml で無効なモジュールはどのギルドでも無効になり、有効なモジュールはギルドごとに無効にできます。
+type Gate struct {
+	db  database.GuildSettingsRepository
+	log *slog.Logger
+
+	mu      sync.RWMutex
+	modules map[string]*Module
+	guilds  map[string]map[string]bool // ギルドID → モジュール名 → 有効かどうか
+}
+
+func NewGate(db database.GuildSettingsRepository, logger *slog.Logger) *Gate {
+	return &Gate{
+		db:      db,
+		log:     logger,
+		modules: make(map[string]*Module),
+		guilds:  make(map[string]map[string]bool),
+	}
+}
+
+// Define はギルドごとに切り替えられるモジュールを登録します
+func (g *Gate) Define(name, description string, enabled bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.modules[name] = &Module{Name: name, Description: description, Enabled: enabled}
+}
+
+// SetGlobal は設定の再読み込みでモジュールを全体で有効・無効にします
+func (g *Gate) SetGlobal(name string, enabled bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if m, ok := g.modules[name]; ok {
+		m.Enabled = enabled
+	}
+}
+
+// Modules は登録済みのモジュールを名前順に返します
+func (g *Gate) Modules() []Module {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	modules := make([]Module, 0, len(g.modules))
+	for _, m := range g.modules {
+		modules = append(modules, *m)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
+	return modules
+}
+
+// Lookup は登録済みのモジュールを返します
+func (g *Gate) Lookup(name string) (Module, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	m, ok := g.modules[name]
+	if !ok {
+		return Module{}, false
+	}
+	return *m, true
+}
+
+// Enabled はモジュールがギルドで有効かを返します。登録されていないモジュールは常に有効です。
+func (g *Gate) Enabled(guildID, name string) bool {
+	g.mu.RLock()
+	m, ok := g.modules[name]
+	if !ok {
+		g.mu.RUnlock()
+		return true
+	}
+	if !m.Enabled {
+		g.mu.RUnlock()
+		return false
+	}
+	if guildID == "" {
+		g.mu.RUnlock()
+		return true
+	}
+	overrides, loaded := g.guilds[guildID]
+	g.mu.RUnlock()
+
+	if !loaded {
+		overrides = g.load(guildID)
+	}
+	if enabled, ok := overrides[name]; ok {
+		return enabled
+	}
+	return true
+}
+
+// SetEnabled はギルドでのモジュールの有効・無効を保存します
+func (g *Gate) SetEnabled(guildID, name string, enabled bool) error {
+	if err := g.db.SetGuildModule(guildID, name, enabled); err != nil {
+		return err
+	}
+
+	overrides := g.load(guildID)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	updated := make(map[string]bool, len(overrides)+1)
+	for k, v := range overrides {
+		updated[k] = v
+	}
+	updated[name] = enabled
+	g.guilds[guildID] = updated
+	return nil
+}
+
+// load はギルドの個別設定をデータベースから読み込んでキャッシュします
+func (g *Gate) load(guildID string) map[string]bool {
+	overrides, err := g.db.GetGuildModules(guildID)
+	if err != nil {
+		// 読み込めない場合は既定（有効）として扱い、次回再試行する
+		g.log.Warn("Failed to load module settings", "guild_id", guildID, "error", err)
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cached, ok := g.guilds[guildID]; ok {
+		return cached
+	}
+	g.guilds[guildID] = overrides
+	return overrides
+}
+
+// Allow はワーカープールのフィルターとして使用します。
+// タスク名の先頭（"logging.message_delete" の "logging"）をモジュール名とみなします。
+func (g *Gate) Allow(guildID, taskName string) bool {
+	name, _, _ := strings.Cut(taskName, ".")
+	return g.Enabled(guildID, name)
+}
@@ -0,0 +1,207 @@
+package interactions
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var callbackPath = regexp.MustCompile(`/interactions/(\d+)/[^/]+/callback$`)
+
+// pendingResponse はHTTPリクエストに対する最初の応答を待っているインタラクションです
+type pendingResponse struct {
+	mu           sync.Mutex
+	done         chan struct{}
+	responded    bool
+	expired      bool
+	contentType  string
+	body         []byte
+	appID        string
+	token        string
+	deferredType discordgo.InteractionResponseType
+
+	// written はHTTPレスポンス（応答または遅延応答）を書き終えると閉じられます
+	written     chan struct{}
+	writtenOnce sync.Once
+}
+
+func newPendingResponse(i *discordgo.Interaction) *pendingResponse {
+	deferredType := discordgo.InteractionResponseDeferredChannelMessageWithSource
+	if i.Type != discordgo.InteractionApplicationCommand {
+		deferredType = discordgo.InteractionResponseDeferredMessageUpdate
+	}
+
+	return &pendingResponse{
+		done:         make(chan struct{}),
+		written:      make(chan struct{}),
+		appID:        i.AppID,
+		token:        i.Token,
+		deferredType: deferredType,
+	}
+}
+
+// respond は応答を記録します。既に遅延応答を返していた場合は false を返します。
+func (p *pendingResponse) respond(contentType string, body []byte) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.expired || p.responded {
+		return false
+	}
+	p.responded = true
+	p.contentType = contentType
+	p.body = body
+	close(p.done)
+	return true
+}
+
+// markWritten はHTTPレスポンスを書き終えたことを記録します
+func (p *pendingResponse) markWritten() {
+	p.writtenOnce.Do(func() { close(p.written) })
+}
+
+// waitWritten はHTTPレスポンスが書き終わるまで待ちます。
+// Discord が応答を受け取る前に @original の編集などを送ると Unknown Interaction になるためです。
+func (p *pendingResponse) waitWritten(req *http.Request) error {
+	select {
+	case <-p.written:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// expire は応答期限切れを記録します。既に応答済みの場合は false を返します。
+func (p *pendingResponse) expire() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.responded {
+		return false
+	}
+	p.expired = true
+	return true
+}
+
+// responder はインタラクションのコールバックAPI呼び出しを横取りし、
+// HTTPリクエストへのレスポンスとして返す http.RoundTripper です。
+// これにより既存のコマンドは ctx.Session.InteractionRespond をそのまま使えます。
+type responder struct {
+	mu      sync.Mutex
+	pending map[string]*pendingResponse
+	next    http.RoundTripper
+}
+
+func newResponder(next http.RoundTripper) *responder {
+	return &responder{
+		pending: make(map[string]*pendingResponse),
+		next:    next,
+	}
+}
+
+func (r *responder) track(i *discordgo.Interaction) *pendingResponse {
+	p := newPendingResponse(i)
+
+	r.mu.Lock()
+	r.pending[i.ID] = p
+	r.mu.Unlock()
+
+	return p
+}
+
+// untrack は追跡を終了します。期限切れ後の応答を変換できるよう、遅延応答した場合は
+// トークンの有効期限まで残しておきます。
+func (r *responder) untrack(interactionID string) {
+	r.mu.Lock()
+	delete(r.pending, interactionID)
+	r.mu.Unlock()
+}
+
+func (r *responder) RoundTrip(req *http.Request) (*http.Response, error) {
+	match := callbackPath.FindStringSubmatch(req.URL.Path)
+	if req.Method != http.MethodPost || match == nil {
+		return r.next.RoundTrip(req)
+	}
+
+	r.mu.Lock()
+	p, ok := r.pending[match[1]]
+	delete(r.pending, match[1])
+	r.mu.Unlock()
+
+	if !ok {
+		return r.next.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	responded := p.respond(req.Header.Get("Content-Type"), body)
+	if err := p.waitWritten(req); err != nil {
+		return nil, err
+	}
+	if responded {
+		return noContent(req), nil
+	}
+
+	return r.lateResponse(req, p, body)
+}
+
+// lateResponse は遅延応答を返した後に届いた応答を、元のメッセージの編集またはフォローアップに変換します
+func (r *responder) lateResponse(req *http.Request, p *pendingResponse, body []byte) (*http.Response, error) {
+	var resp struct {
+		Type discordgo.InteractionResponseType `json:"type"`
+		Data json.RawMessage                   `json:"data"`
+	}
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") || json.Unmarshal(body, &resp) != nil {
+		// 添付ファイル付きの応答は変換できないためそのまま送信する（既に応答済みとしてエラーになる）
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		return r.next.RoundTrip(req)
+	}
+
+	var method, endpoint string
+	switch {
+	case resp.Type == p.deferredType ||
+		resp.Type == discordgo.InteractionResponseDeferredChannelMessageWithSource ||
+		resp.Type == discordgo.InteractionResponseDeferredMessageUpdate:
+		// 既に遅延応答済み
+		return noContent(req), nil
+	case resp.Type == discordgo.InteractionResponseChannelMessageWithSource &&
+		p.deferredType == discordgo.InteractionResponseDeferredMessageUpdate:
+		method, endpoint = http.MethodPost, discordgo.EndpointWebhookToken(p.appID, p.token)
+	case resp.Type == discordgo.InteractionResponseChannelMessageWithSource,
+		resp.Type == discordgo.InteractionResponseUpdateMessage:
+		method, endpoint = http.MethodPatch, discordgo.EndpointWebhookMessage(p.appID, p.token, "@original")
+	default:
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		return r.next.RoundTrip(req)
+	}
+
+	rewritten, err := http.NewRequestWithContext(req.Context(), method, endpoint, bytes.NewReader(resp.Data))
+	if err != nil {
+		return nil, err
+	}
+	rewritten.Header = req.Header.Clone()
+	return r.next.RoundTrip(rewritten)
+}
+
+func noContent(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "204 No Content",
+		StatusCode: http.StatusNoContent,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
@@ -0,0 +1,199 @@
+package interactions
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/commands"
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/worker"
+)
+
+const (
+	// Discordは3秒以内の応答を要求するため、余裕を持って遅延応答に切り替える
+	ackTimeout = 2500 * time.Millisecond
+	// インタラクショントークンの有効期限
+	tokenLifetime = 15 * time.Minute
+	maxBodySize   = 1 << 20
+)
+
+// Server はDiscordからのインタラクションをHTTPで受け付けるエンドポイントです。
+// 署名を検証したインタラクションをゲートウェイと同じ Registry に振り分け、
+// コマンドの最初の応答をHTTPレスポンスとして返します。
+type Server struct {
+	config    config.InteractionsConfig
+	publicKey ed25519.PublicKey
+	registry  *commands.Registry
+	session   *discordgo.Session
+	responder *responder
+	server    *http.Server
+	log       *slog.Logger
+}
+
+// NewServer はエンドポイントを作成します。primary のステートとレート制限を共有する
+// HTTP応答用のセッションを内部で作成します。
+func NewServer(cfg config.InteractionsConfig, primary *discordgo.Session, registry *commands.Registry, logger *slog.Logger) (*Server, error) {
+	publicKey, err := ParsePublicKey(cfg.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := discordgo.New(primary.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	next := primary.Client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp := newResponder(next)
+
+	session.State = primary.State
+	session.Ratelimiter = primary.Ratelimiter
+	session.Client = &http.Client{Timeout: primary.Client.Timeout, Transport: resp}
+
+	s := &Server{
+		config:    cfg,
+		publicKey: publicKey,
+		registry:  registry,
+		session:   session,
+		responder: resp,
+		log:       logger,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(cfg.Path, s.handleInteraction)
+	s.server = &http.Server{
+		Addr:              cfg.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s, nil
+}
+
+// Start はバックグラウンドでHTTPサーバーを起動します
+func (s *Server) Start() {
+	go func() {
+		s.log.Info("Interactions endpoint listening", "addr", s.config.ListenAddr, "path", s.config.Path)
+		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("Interactions endpoint stopped", "error", err)
+		}
+	}()
+}
+
+// Shutdown は新しいリクエストの受付を止め、応答待ちのリクエストを待ってから停止します
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Server) handleInteraction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	// Discordは署名が不正なリクエストに 401 を返すことを要求する
+	if !Verify(s.publicKey, r.Header.Get("X-Signature-Ed25519"), r.Header.Get("X-Signature-Timestamp"), body) {
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+
+	var interaction discordgo.Interaction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		http.Error(w, "invalid interaction", http.StatusBadRequest)
+		return
+	}
+
+	if interaction.Type == discordgo.InteractionPing {
+		s.writeJSON(w, http.StatusOK, &discordgo.InteractionResponse{Type: discordgo.InteractionResponsePong})
+		return
+	}
+
+	pending := s.responder.track(&interaction)
+	// コマンドからのコールバックは、このレスポンスを書き終えるまで待たせる
+	defer pending.markWritten()
+
+	if err := s.registry.Dispatch(s.session, &discordgo.InteractionCreate{Interaction: &interaction}); err != nil {
+		s.responder.untrack(interaction.ID)
+		s.log.Warn("Rejected HTTP interaction", "interaction_id", interaction.ID, "guild_id", interaction.GuildID, "error", err)
+		s.writeRejection(w, err)
+		return
+	}
+
+	timer := time.NewTimer(ackTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-pending.done:
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+
+	if pending.expire() {
+		// 期限内に応答がなかったため遅延応答を返す。後から届いた応答は responder が変換する
+		time.AfterFunc(tokenLifetime, func() { s.responder.untrack(interaction.ID) })
+		if r.Context().Err() == nil {
+			s.writeJSON(w, http.StatusOK, &discordgo.InteractionResponse{Type: pending.deferredType})
+		}
+		return
+	}
+
+	s.write(w, http.StatusOK, pending.contentType, pending.body)
+}
+
+// writeRejection はワーカープールに投入できなかったインタラクションに、ゲートウェイと同じ案内を返します
+func (s *Server) writeRejection(w http.ResponseWriter, err error) {
+	content := "⏳ 現在混み合っています。しばらくしてから再度お試しください。"
+	switch {
+	case errors.Is(err, worker.ErrPoolClosed):
+		content = "🔄 ボットを再起動しています。しばらくしてから再度お試しください。"
+	case !errors.Is(err, worker.ErrQueueFull):
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: content, Flags: discordgo.MessageFlagsEphemeral},
+	})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		s.log.Warn("Failed to encode interaction response", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.write(w, status, "application/json", body)
+}
+
+// write はレスポンスを書き込み、すぐに送信します。Content-Length を付けるため、
+// Flush の時点で Discord はレスポンス全体を受け取れます（その後にコマンドの編集などが送られる）。
+func (s *Server) write(w http.ResponseWriter, status int, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		s.log.Warn("Failed to write interaction response", "error", err)
+		return
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
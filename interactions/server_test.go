@@ -0,0 +1,254 @@
+package interactions
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/commands"
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/discordtest"
+	"github.com/Sumire-Labs/Luna/features"
+	"github.com/Sumire-Labs/Luna/shard"
+	"github.com/Sumire-Labs/Luna/worker"
+)
+
+// testCommand は run を実行するだけのコマンドです
+type testCommand struct {
+	run func(ctx *commands.Context) error
+}
+
+func (c *testCommand) Name() string                                   { return "test" }
+func (c *testCommand) Description() string                            { return "test" }
+func (c *testCommand) Usage() string                                  { return "/test" }
+func (c *testCommand) Category() string                               { return "test" }
+func (c *testCommand) Aliases() []string                              { return nil }
+func (c *testCommand) Permission() int64                              { return 0 }
+func (c *testCommand) Options() []*discordgo.ApplicationCommandOption { return nil }
+func (c *testCommand) Execute(ctx *commands.Context) error            { return c.run(ctx) }
+
+type testEnv struct {
+	srv     *discordtest.Server
+	server  *Server
+	key     ed25519.PrivateKey
+	owner   *discordgo.User
+	guild   *discordgo.Guild
+	channel *discordgo.Channel
+}
+
+func newTestEnv(t *testing.T, cmd *testCommand) *testEnv {
+	t.Helper()
+
+	srv := discordtest.NewServer()
+	t.Cleanup(srv.Close)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	owner := srv.AddUser("owner", false)
+	guild := srv.AddGuild("Test", owner)
+	channel := srv.AddChannel(guild.ID, "general", discordgo.ChannelTypeGuildText, "")
+	primary := srv.Session()
+
+	conn, err := database.Connect(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "luna.db"), MaxConnections: 1})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	db := database.NewService(conn, logger)
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	pool := worker.NewPool(config.WorkerConfig{Workers: 2}, logger)
+	pool.Start()
+	t.Cleanup(func() { pool.Shutdown(context.Background()) })
+
+	registry := commands.NewRegistry(shard.NewManagerFromSessions(primary), &config.Config{}, db,
+		database.NewWriteBuffer(db, config.DatabaseConfig{}, logger), pool, features.NewGate(db, logger), logger)
+	if err := registry.Register(cmd); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	server, err := NewServer(config.InteractionsConfig{Path: "/interactions", PublicKey: hex.EncodeToString(public)}, primary, registry, logger)
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	return &testEnv{srv: srv, server: server, key: private, owner: owner, guild: guild, channel: channel}
+}
+
+// ackWriter は Discord と同様に、送信されたレスポンスをその時点でインタラクションの応答として受け付けます
+type ackWriter struct {
+	*httptest.ResponseRecorder
+	env         *testEnv
+	interaction *discordgo.Interaction
+
+	once sync.Once
+}
+
+func (w *ackWriter) Flush() {
+	w.ResponseRecorder.Flush()
+	w.once.Do(func() {
+		if w.Code != http.StatusOK || w.interaction == nil {
+			return
+		}
+		url := w.env.srv.URL() + "/api/v10/interactions/" + w.interaction.ID + "/" + w.interaction.Token + "/callback"
+		resp, err := http.Post(url, "application/json", bytes.NewReader(w.Body.Bytes()))
+		if err == nil {
+			resp.Body.Close()
+		}
+	})
+}
+
+// send は署名したインタラクションを送信し、エンドポイントのレスポンスを返します
+func (e *testEnv) send(t *testing.T, i *discordgo.Interaction, sign bool) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, err := json.Marshal(i)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := Sign(e.key, timestamp, body)
+	if !sign {
+		signature = Sign(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)), timestamp, body)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/interactions", bytes.NewReader(body))
+	req.Header.Set("X-Signature-Ed25519", signature)
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+
+	w := &ackWriter{ResponseRecorder: httptest.NewRecorder(), env: e, interaction: i}
+	e.server.handleInteraction(w, req)
+	w.Flush()
+	return w.ResponseRecorder
+}
+
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder) *discordgo.InteractionResponse {
+	t.Helper()
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var resp discordgo.InteractionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return &resp
+}
+
+func TestRejectsInvalidSignature(t *testing.T) {
+	env := newTestEnv(t, &testCommand{run: func(ctx *commands.Context) error { return nil }})
+
+	w := env.send(t, &discordgo.Interaction{ID: "1", Type: discordgo.InteractionPing}, false)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", w.Code)
+	}
+}
+
+func TestRespondsToPing(t *testing.T) {
+	env := newTestEnv(t, &testCommand{run: func(ctx *commands.Context) error { return nil }})
+
+	resp := decodeResponse(t, env.send(t, &discordgo.Interaction{ID: "1", Type: discordgo.InteractionPing}, true))
+	if resp.Type != discordgo.InteractionResponsePong {
+		t.Errorf("response type = %d, want PONG", resp.Type)
+	}
+}
+
+func TestReturnsResponseInTime(t *testing.T) {
+	env := newTestEnv(t, &testCommand{run: func(ctx *commands.Context) error {
+		return ctx.Reply("pong")
+	}})
+
+	i := env.srv.CommandInteraction(env.guild.ID, env.channel.ID, env.owner, "test")
+	resp := decodeResponse(t, env.send(t, i.Interaction, true))
+	if resp.Type != discordgo.InteractionResponseChannelMessageWithSource || resp.Data == nil || resp.Data.Content != "pong" {
+		t.Errorf("response = %+v, want the command reply", resp)
+	}
+	// 応答はHTTPレスポンスとして返し、コールバックAPIは呼ばない（テストの ackWriter からの1回のみ）
+	if record := env.srv.Interaction(i.ID); record.Original == nil || record.Original.Content != "pong" {
+		t.Error("reply was not delivered")
+	}
+}
+
+func TestDeferThenEditWaitsForAck(t *testing.T) {
+	edited := make(chan error, 1)
+	env := newTestEnv(t, &testCommand{run: func(ctx *commands.Context) error {
+		ctx.DeferReply(true)
+		// 遅延応答の直後に編集しても、Discord が遅延応答を受け取った後に送られること
+		edited <- ctx.EditReply("done")
+		return nil
+	}})
+
+	i := env.srv.CommandInteraction(env.guild.ID, env.channel.ID, env.owner, "test")
+	resp := decodeResponse(t, env.send(t, i.Interaction, true))
+	if resp.Type != discordgo.InteractionResponseDeferredChannelMessageWithSource {
+		t.Errorf("response type = %d, want deferred", resp.Type)
+	}
+
+	select {
+	case err := <-edited:
+		if err != nil {
+			t.Fatalf("edit reply: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("command did not finish")
+	}
+	if record := env.srv.Interaction(i.ID); record.Original == nil || record.Original.Content != "done" {
+		t.Error("original response was not edited")
+	}
+}
+
+func TestLateResponseEditsOriginal(t *testing.T) {
+	replied := make(chan error, 1)
+	env := newTestEnv(t, &testCommand{run: func(ctx *commands.Context) error {
+		time.Sleep(ackTimeout + 500*time.Millisecond)
+		replied <- ctx.Reply("late")
+		return nil
+	}})
+
+	i := env.srv.CommandInteraction(env.guild.ID, env.channel.ID, env.owner, "test")
+	resp := decodeResponse(t, env.send(t, i.Interaction, true))
+	if resp.Type != discordgo.InteractionResponseDeferredChannelMessageWithSource {
+		t.Fatalf("response type = %d, want deferred after the timeout", resp.Type)
+	}
+
+	select {
+	case err := <-replied:
+		if err != nil {
+			t.Fatalf("reply: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("command did not finish")
+	}
+
+	record := env.srv.Interaction(i.ID)
+	if record.Original == nil || record.Original.Content != "late" {
+		t.Error("late reply did not edit the original response")
+	}
+	patched := false
+	for _, req := range env.srv.Requests() {
+		if req.Method == http.MethodPatch && req.Path == "/webhooks/"+i.AppID+"/"+i.Token+"/messages/@original" {
+			patched = true
+		}
+	}
+	if !patched {
+		t.Error("late reply was not rewritten to an @original edit")
+	}
+}
@@ -0,0 +1,55 @@
+package interactions
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// タイムスタンプの許容誤差（古いリクエストの再送を防ぐ）
+const maxTimestampSkew = 5 * time.Minute
+
+// ParsePublicKey は Developer Portal に表示される16進数の公開鍵を読み込みます
+func ParsePublicKey(hexKey string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key length: %d", len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Verify は X-Signature-Ed25519 / X-Signature-Timestamp ヘッダーの署名を検証します。
+// 署名対象はタイムスタンプとリクエストボディを連結したものです。
+func Verify(key ed25519.PublicKey, signature, timestamp string, body []byte) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > maxTimestampSkew || skew < -maxTimestampSkew {
+		return false
+	}
+
+	msg := make([]byte, 0, len(timestamp)+len(body))
+	msg = append(msg, timestamp...)
+	msg = append(msg, body...)
+	return ed25519.Verify(key, msg, sig)
+}
+
+// Sign は Discord と同じ形式でリクエストに署名します。
+// ローカルでの負荷試験などで偽のインタラクションを送る際に使用します。
+func Sign(key ed25519.PrivateKey, timestamp string, body []byte) string {
+	msg := make([]byte, 0, len(timestamp)+len(body))
+	msg = append(msg, timestamp...)
+	msg = append(msg, body...)
+	return hex.EncodeToString(ed25519.Sign(key, msg))
+}
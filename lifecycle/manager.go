@@ -0,0 +1,101 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Manager はサービスの停止処理を管理します。
+// 停止フックは登録と逆の順序（defer と同じ LIFO）で実行されるため、
+// 依存される側を先に登録しておけば依存関係の順に安全に停止できます。
+type Manager struct {
+	mu       sync.Mutex
+	hooks    []hook
+	ctx      context.Context
+	cancel   context.CancelFunc
+	timeout  time.Duration
+	shutdown sync.Once
+	err      error
+	log      *slog.Logger
+}
+
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func NewManager(timeout time.Duration, logger *slog.Logger) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		ctx:     ctx,
+		cancel:  cancel,
+		timeout: timeout,
+		log:     logger,
+	}
+}
+
+// OnStop は停止時に実行するフックを登録します
+func (m *Manager) OnStop(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Context はシャットダウン開始時にキャンセルされるコンテキストを返します
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Stopping はシャットダウンが開始されているかを返します
+func (m *Manager) Stopping() bool {
+	return m.ctx.Err() != nil
+}
+
+// Sleep は指定時間待機します。途中でシャットダウンが始まった場合は false を返します。
+func (m *Manager) Sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-m.ctx.Done():
+		return false
+	}
+}
+
+// Shutdown はタイマー類を停止させた後、登録されたフックを逆順に実行します。
+// 全体で timeout を超えた場合、残りのフックには期限切れのコンテキストが渡されます。
+func (m *Manager) Shutdown() error {
+	m.shutdown.Do(func() {
+		m.cancel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+
+		m.mu.Lock()
+		hooks := make([]hook, len(m.hooks))
+		copy(hooks, m.hooks)
+		m.mu.Unlock()
+
+		var errs []error
+		for i := len(hooks) - 1; i >= 0; i-- {
+			h := hooks[i]
+			start := time.Now()
+			if err := h.stop(ctx); err != nil {
+				m.log.Error("Failed to stop service", "service", h.name, "error", err)
+				errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+				continue
+			}
+			m.log.Info("Stopped service", "service", h.name, "duration", time.Since(start).Round(time.Millisecond))
+		}
+
+		m.err = errors.Join(errs...)
+	})
+
+	return m.err
+}
@@ -0,0 +1,314 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/embed"
+)
+
+const (
+	// auditWindow はイベントの直前に作成された監査ログのエントリだけを対応付けるための時間です
+	auditWindow = 15 * time.Second
+	// auditCountTTL は件数を覚えておく期間です（Discord が同じエントリにまとめる期間より長くする）
+	auditCountTTL = time.Hour
+	// auditFetchLimit は一度に取得するエントリ数です
+	auditFetchLimit = 10
+	// auditRecentLimit と auditRecentTTL は、対象を指定して探すために取得した全種類の最近のエントリの件数と、
+	// それを使い回す時間です（一括BANやチャンネルの連続削除で何度も取得しないように）
+	auditRecentLimit = 25
+	auditRecentTTL   = 3 * time.Second
+	// auditDelay は監査ログにエントリが反映されるまで待つ時間です（ゲートウェイのイベントの方が先に届く場合がある）
+	auditDelay = time.Second
+	// auditRetryDelay は件数の増加が見つからなかった場合に、もう一度取得するまで待つ時間です
+	auditRetryDelay = 2 * time.Second
+	// auditDeniedTTL は監査ログの取得を拒否されたギルドで、次に取得を試みるまでの時間です
+	auditDeniedTTL = 10 * time.Minute
+)
+
+// auditRecent はギルドの最近のエントリです
+type auditRecent struct {
+	fetchedAt time.Time
+	entries   []*discordgo.AuditLogEntry
+}
+
+// auditTracker は監査ログから操作を実行したモデレーターを探します。
+// BAN・キック・チャンネルやロールの操作は、対象のIDが一致し、イベントの直前に作成されたエントリを探します。
+// ボイスチャンネルからの切断・移動、メッセージの削除のように、同じモデレーターの操作が1つのエントリにまとめられる場合は
+// エントリの件数（count）の増加で新しい操作を検出します。監査ログの閲覧権限がない場合は何も返しません。
+type auditTracker struct {
+	session *discordgo.Session
+	log     *slog.Logger
+
+	mu       sync.Mutex
+	counts   map[string]int         // エントリID → 前回確認した件数
+	recent   map[string]auditRecent // ギルドID → 最近のエントリ
+	fetching map[string]*sync.Mutex // ギルドID → 最近のエントリの取得（同時に届いたイベントで1回にまとめる）
+	denied   map[string]time.Time   // ギルドID → 取得を拒否された時刻
+}
+
+func newAuditTracker(session *discordgo.Session, logger *slog.Logger) *auditTracker {
+	return &auditTracker{
+		session: session,
+		log:     logger,
+		counts:   make(map[string]int),
+		recent:   make(map[string]auditRecent),
+		fetching: make(map[string]*sync.Mutex),
+		denied:   make(map[string]time.Time),
+	}
+}
+
+// canView は監査ログを閲覧できるかどうかを返します。
+// ボットのロールから権限を確認し、state にギルドがない場合は取得を試みます（拒否された場合はしばらく取得しない）。
+func (a *auditTracker) canView(state *discordgo.State, guildID string) bool {
+	a.mu.Lock()
+	deniedAt, denied := a.denied[guildID]
+	a.mu.Unlock()
+	if denied && time.Since(deniedAt) < auditDeniedTTL {
+		return false
+	}
+
+	perms, err := guildPermissions(state, guildID)
+	if err != nil {
+		return true
+	}
+	return perms&(discordgo.PermissionViewAuditLogs|discordgo.PermissionAdministrator) != 0
+}
+
+// guildPermissions はボットのギルド全体の権限を state から計算します
+func guildPermissions(state *discordgo.State, guildID string) (int64, error) {
+	if state == nil || state.User == nil {
+		return 0, discordgo.ErrNilState
+	}
+	guild, err := state.Guild(guildID)
+	if err != nil {
+		return 0, err
+	}
+	if guild.OwnerID == state.User.ID {
+		return discordgo.PermissionAll, nil
+	}
+	member, err := state.Member(guildID, state.User.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	var perms int64
+	for _, role := range guild.Roles {
+		if role.ID == guildID || slices.Contains(member.Roles, role.ID) {
+			perms |= role.Permissions
+		}
+	}
+	return perms, nil
+}
+
+// findTarget は targetID に対する actions のいずれかの操作のうち、直前に作成されたエントリを返します。
+// 呼び出した時点より後に取得したエントリがあれば、見つからなかった場合も含めてそれを使い、
+// 一括BANやチャンネルの連続削除でもギルドごとに1回の取得にまとめます。
+func (a *auditTracker) findTarget(guildID, targetID string, actions ...discordgo.AuditLogAction) *discordgo.AuditLogEntry {
+	ready := time.Now()
+
+	a.mu.Lock()
+	lock, ok := a.fetching[guildID]
+	if !ok {
+		lock = &sync.Mutex{}
+		a.fetching[guildID] = lock
+	}
+	a.mu.Unlock()
+
+	// 同じギルドの取得中は待ち、その結果を使う
+	lock.Lock()
+	defer lock.Unlock()
+
+	a.mu.Lock()
+	recent, ok := a.recent[guildID]
+	a.mu.Unlock()
+	if ok && !recent.fetchedAt.Before(ready) {
+		return matchTarget(recent.entries, targetID, actions)
+	}
+
+	fetchedAt := time.Now()
+	entries := a.fetch(guildID, 0, auditRecentLimit)
+	a.mu.Lock()
+	a.recent[guildID] = auditRecent{fetchedAt: fetchedAt, entries: entries}
+	for id, r := range a.recent {
+		if fetchedAt.Sub(r.fetchedAt) > auditRecentTTL {
+			delete(a.recent, id)
+		}
+	}
+	a.mu.Unlock()
+
+	return matchTarget(entries, targetID, actions)
+}
+
+// matchTarget は entries のうち、targetID に対する actions の操作で auditWindow 以内に作成されたものを返します
+func matchTarget(entries []*discordgo.AuditLogEntry, targetID string, actions []discordgo.AuditLogAction) *discordgo.AuditLogEntry {
+	for _, entry := range entries {
+		if entry.TargetID != targetID || entry.ActionType == nil || time.Since(entryTime(entry)) > auditWindow {
+			continue
+		}
+		for _, action := range actions {
+			if *entry.ActionType == action {
+				return entry
+			}
+		}
+	}
+	return nil
+}
+
+// findAggregated は action のエントリのうち、match に一致し、前回から件数が増えた（または直前に作成された）ものを返します
+func (a *auditTracker) findAggregated(guildID string, action discordgo.AuditLogAction, match func(entry *discordgo.AuditLogEntry) bool) *discordgo.AuditLogEntry {
+	return a.matchAggregated(a.fetch(guildID, action, auditFetchLimit), match)
+}
+
+// matchAggregated は entries から件数が増えたエントリを探し、見つけたエントリの件数だけを記録します。
+// 一致しないエントリの増加は、同時に処理している別のイベントのものである場合があるため記録しません。
+// ただし、初めて見る古いエントリは直前に作成されたかでは判別できないため、次回に増加を検出できるよう件数を記録します。
+func (a *auditTracker) matchAggregated(entries []*discordgo.AuditLogEntry, match func(entry *discordgo.AuditLogEntry) bool) *discordgo.AuditLogEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	var found *discordgo.AuditLogEntry
+	for _, entry := range entries {
+		count := 1
+		if entry.Options != nil && entry.Options.Count != "" {
+			if n, err := strconv.Atoi(entry.Options.Count); err == nil {
+				count = n
+			}
+		}
+		previous, seen := a.counts[entry.ID]
+		recent := now.Sub(entryTime(entry)) <= auditWindow
+
+		if found == nil && match(entry) && ((seen && count > previous) || (!seen && recent)) {
+			found = entry
+			a.counts[entry.ID] = count
+			continue
+		}
+		if !seen && !recent {
+			a.counts[entry.ID] = count
+		}
+	}
+
+	for id := range a.counts {
+		if created, err := discordgo.SnowflakeTimestamp(id); err == nil && now.Sub(created) > auditCountTTL {
+			delete(a.counts, id)
+		}
+	}
+	return found
+}
+
+// fetch は action の最近のエントリを返します（action が 0 の場合はすべての種類、取得できない場合は nil）
+func (a *auditTracker) fetch(guildID string, action discordgo.AuditLogAction, limit int) []*discordgo.AuditLogEntry {
+	auditLog, err := a.session.GuildAuditLog(guildID, "", "", int(action), limit)
+	if err != nil {
+		var restErr *discordgo.RESTError
+		if errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusForbidden {
+			a.mu.Lock()
+			a.denied[guildID] = time.Now()
+			a.mu.Unlock()
+		}
+		a.log.Debug("Failed to fetch audit log", "guild_id", guildID, "action", int(action), "error", err)
+		return nil
+	}
+	return auditLog.AuditLogEntries
+}
+
+// entryTime はエントリが作成された時刻です
+func entryTime(entry *discordgo.AuditLogEntry) time.Time {
+	created, err := discordgo.SnowflakeTimestamp(entry.ID)
+	if err != nil {
+		return time.Time{}
+	}
+	return created
+}
+
+// auditQueue は監査ログを確認するタスクのワーカープールのキューです。
+// 確認には待ち時間があるため、ギルドのキューとは分けて、同じギルドの他のイベントや操作の応答を待たせないようにします。
+// モジュールの有効・無効はイベントの処理時に確認済みのため、タスク名は "audit." で始めてプールのフィルターの対象外にします。
+func auditQueue(guildID string) string {
+	return "audit:" + guildID
+}
+
+// withAudit は delays[0] 待ってから監査ログ用のキューで find を実行し、結果を send に渡します。
+// 見つからない場合は次の delays だけ待ってもう一度探します。
+// 監査ログを閲覧できない場合やキューに追加できない場合は、探さずに nil を渡します。
+func (l *Logger) withAudit(guildID, name string, delays []time.Duration, find func() *discordgo.AuditLogEntry, send func(entry *discordgo.AuditLogEntry)) {
+	if !l.audit.canView(l.shards.ForGuild(guildID).State, guildID) {
+		send(nil)
+		return
+	}
+
+	var attempt func(n int)
+	attempt = func(n int) {
+		err := l.pool.Submit(auditQueue(guildID), name, func(context.Context) {
+			entry := find()
+			if entry == nil && n+1 < len(delays) {
+				time.AfterFunc(delays[n+1], func() { attempt(n + 1) })
+				return
+			}
+			send(entry)
+		})
+		if err != nil {
+			l.log.Warn("Failed to queue audit log lookup", "task", name, "guild_id", guildID, "error", err)
+			send(nil)
+		}
+	}
+	if delays[0] > 0 {
+		time.AfterFunc(delays[0], func() { attempt(0) })
+	} else {
+		attempt(0)
+	}
+}
+
+// sendWithModerator は auditDelay 待ってから targetID に対する actions の操作を実行したモデレーターを探し、
+// ログに追加して送信します
+func (l *Logger) sendWithModerator(channelID string, embedBuilder *embed.Builder, guildID, targetID string, actions ...discordgo.AuditLogAction) {
+	l.withAudit(guildID, "audit.moderator", []time.Duration{auditDelay}, func() *discordgo.AuditLogEntry {
+		return l.audit.findTarget(guildID, targetID, actions...)
+	}, func(entry *discordgo.AuditLogEntry) {
+		addModerator(embedBuilder, entry)
+		l.sendLogMessage(channelID, embedBuilder.Build())
+	})
+}
+
+// removal はメンバーがギルドからいなくなった理由です
+type removal int
+
+const (
+	removalLeave removal = iota // 自分から退出した（監査ログで確認できない場合を含む）
+	removalKick
+	removalBan
+)
+
+// removalKind は findTarget でキックまたはBANを探した結果から、メンバーがいなくなった理由を返します
+func removalKind(entry *discordgo.AuditLogEntry) removal {
+	if entry == nil || entry.ActionType == nil {
+		return removalLeave
+	}
+	switch *entry.ActionType {
+	case discordgo.AuditLogActionMemberKick:
+		return removalKick
+	case discordgo.AuditLogActionMemberBanAdd:
+		return removalBan
+	}
+	return removalLeave
+}
+
+// addModerator は操作を実行したモデレーターと理由をログに追加します（entry が nil の場合は何もしない）
+func addModerator(embedBuilder *embed.Builder, entry *discordgo.AuditLogEntry) {
+	if entry == nil {
+		return
+	}
+	embedBuilder.AddField("🛡️ 実行者", fmt.Sprintf("<@%s>", entry.UserID), true)
+	if entry.Reason != "" {
+		embedBuilder.AddField("📝 理由", entry.Reason, false)
+	}
+}
@@ -0,0 +1,338 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/discordtest"
+	"github.com/Sumire-Labs/Luna/shard"
+	"github.com/Sumire-Labs/Luna/worker"
+)
+
+// entryAt は created に作成された監査ログのエントリを作ります（seq は同じ時刻のエントリを区別する）
+func entryAt(created time.Time, seq int64, action discordgo.AuditLogAction, targetID string) *discordgo.AuditLogEntry {
+	id := (created.UnixMilli()-1420070400000)<<22 | seq
+	return &discordgo.AuditLogEntry{ID: strconv.FormatInt(id, 10), ActionType: &action, TargetID: targetID, UserID: "100"}
+}
+
+// withCount はまとめられた操作の件数を設定します
+func withCount(entry *discordgo.AuditLogEntry, count int, channelID string) *discordgo.AuditLogEntry {
+	entry.Options = &discordgo.AuditLogOptions{Count: strconv.Itoa(count), ChannelID: channelID}
+	return entry
+}
+
+func TestMatchTarget(t *testing.T) {
+	now := time.Now()
+	kick := discordgo.AuditLogActionMemberKick
+	ban := discordgo.AuditLogActionMemberBanAdd
+
+	tests := []struct {
+		name    string
+		entries []*discordgo.AuditLogEntry
+		actions []discordgo.AuditLogAction
+		want    int // 一致するエントリの位置（-1 は一致なし）
+	}{
+		{"recent entry", []*discordgo.AuditLogEntry{entryAt(now.Add(-time.Second), 0, kick, "1")}, []discordgo.AuditLogAction{kick}, 0},
+		{"any of the actions", []*discordgo.AuditLogEntry{entryAt(now.Add(-time.Second), 0, ban, "1")}, []discordgo.AuditLogAction{kick, ban}, 0},
+		{"other target", []*discordgo.AuditLogEntry{entryAt(now.Add(-time.Second), 0, kick, "2")}, []discordgo.AuditLogAction{kick}, -1},
+		{"other action", []*discordgo.AuditLogEntry{entryAt(now.Add(-time.Second), 0, ban, "1")}, []discordgo.AuditLogAction{kick}, -1},
+		{"outside the window", []*discordgo.AuditLogEntry{entryAt(now.Add(-auditWindow-time.Second), 0, kick, "1")}, []discordgo.AuditLogAction{kick}, -1},
+		{"newest first", []*discordgo.AuditLogEntry{
+			entryAt(now.Add(-auditWindow-time.Second), 0, kick, "1"),
+			entryAt(now.Add(-time.Second), 0, kick, "1"),
+		}, []discordgo.AuditLogAction{kick}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchTarget(tt.entries, "1", tt.actions)
+			switch {
+			case tt.want < 0 && got != nil:
+				t.Errorf("matched %s, want no entry", got.ID)
+			case tt.want >= 0 && got != tt.entries[tt.want]:
+				t.Errorf("matched %v, want entry %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchAggregated(t *testing.T) {
+	now := time.Now()
+	move := discordgo.AuditLogActionMemberMove
+	recent := withCount(entryAt(now.Add(-time.Second), 0, move, ""), 1, "10")
+	old := withCount(entryAt(now.Add(-time.Minute), 1, move, ""), 3, "10")
+	other := withCount(entryAt(now.Add(-time.Minute), 2, move, ""), 5, "20")
+	inChannel := func(channelID string) func(*discordgo.AuditLogEntry) bool {
+		return func(entry *discordgo.AuditLogEntry) bool { return entry.Options.ChannelID == channelID }
+	}
+
+	tests := []struct {
+		name       string
+		counts     map[string]int
+		entries    []*discordgo.AuditLogEntry
+		match      func(*discordgo.AuditLogEntry) bool
+		want       *discordgo.AuditLogEntry
+		wantCounts map[string]int
+	}{
+		{
+			name:       "first sighting of a recent entry",
+			counts:     map[string]int{},
+			entries:    []*discordgo.AuditLogEntry{recent},
+			match:      inChannel("10"),
+			want:       recent,
+			wantCounts: map[string]int{recent.ID: 1},
+		},
+		{
+			name:       "first sighting of an old entry only records a baseline",
+			counts:     map[string]int{},
+			entries:    []*discordgo.AuditLogEntry{old},
+			match:      inChannel("10"),
+			wantCounts: map[string]int{old.ID: 3},
+		},
+		{
+			name:       "count increase",
+			counts:     map[string]int{old.ID: 2},
+			entries:    []*discordgo.AuditLogEntry{old},
+			match:      inChannel("10"),
+			want:       old,
+			wantCounts: map[string]int{old.ID: 3},
+		},
+		{
+			name:       "same count",
+			counts:     map[string]int{old.ID: 3},
+			entries:    []*discordgo.AuditLogEntry{old},
+			match:      inChannel("10"),
+			wantCounts: map[string]int{old.ID: 3},
+		},
+		{
+			name:       "unmatched increase is left for its own event",
+			counts:     map[string]int{old.ID: 3, other.ID: 4},
+			entries:    []*discordgo.AuditLogEntry{other, old},
+			match:      inChannel("10"),
+			wantCounts: map[string]int{old.ID: 3, other.ID: 4},
+		},
+		{
+			name:       "only the first match is consumed",
+			counts:     map[string]int{old.ID: 2},
+			entries:    []*discordgo.AuditLogEntry{recent, old},
+			match:      inChannel("10"),
+			want:       recent,
+			wantCounts: map[string]int{recent.ID: 1, old.ID: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuditTracker(nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			for id, count := range tt.counts {
+				a.counts[id] = count
+			}
+
+			if got := a.matchAggregated(tt.entries, tt.match); got != tt.want {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+			if len(a.counts) != len(tt.wantCounts) {
+				t.Errorf("counts = %v, want %v", a.counts, tt.wantCounts)
+			}
+			for id, want := range tt.wantCounts {
+				if got := a.counts[id]; got != want {
+					t.Errorf("count of %s = %d, want %d", id, got, want)
+				}
+			}
+		})
+	}
+
+	// 別のイベントに残した増加は、そのイベントで検出できる
+	a := newAuditTracker(nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	a.counts[old.ID] = 3
+	a.counts[other.ID] = 4
+	entries := []*discordgo.AuditLogEntry{other, old}
+	if got := a.matchAggregated(entries, inChannel("10")); got != nil {
+		t.Fatalf("matched %v for channel 10", got)
+	}
+	if got := a.matchAggregated(entries, inChannel("20")); got != other {
+		t.Errorf("matched %v for channel 20, want the entry skipped earlier", got)
+	}
+}
+
+func TestRemovalKind(t *testing.T) {
+	kick := discordgo.AuditLogActionMemberKick
+	ban := discordgo.AuditLogActionMemberBanAdd
+	prune := discordgo.AuditLogActionMemberPrune
+
+	tests := []struct {
+		name  string
+		entry *discordgo.AuditLogEntry
+		want  removal
+	}{
+		{"no entry", nil, removalLeave},
+		{"no action", &discordgo.AuditLogEntry{}, removalLeave},
+		{"kick", &discordgo.AuditLogEntry{ActionType: &kick}, removalKick},
+		{"ban", &discordgo.AuditLogEntry{ActionType: &ban}, removalBan},
+		{"other action", &discordgo.AuditLogEntry{ActionType: &prune}, removalLeave},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := removalKind(tt.entry); got != tt.want {
+				t.Errorf("removalKind = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// auditEnv は偽の Discord サーバーでログ機能を動かすテスト環境です
+type auditEnv struct {
+	srv    *discordtest.Server
+	logger *Logger
+	pool   *worker.Pool
+	guild  *discordgo.Guild
+	logCh  *discordgo.Channel
+	member *discordgo.User
+}
+
+// newAuditEnv はメンバーの退出・キックのログを有効にしたギルドを作ります。botPermissions はボットのロールの権限です。
+func newAuditEnv(t *testing.T, botPermissions int64) *auditEnv {
+	t.Helper()
+
+	srv := discordtest.NewServer()
+	t.Cleanup(srv.Close)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	conn, err := database.Connect(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "luna.db"), MaxConnections: 1})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	db := database.NewService(conn, log)
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	owner := srv.AddUser("owner", false)
+	guild := srv.AddGuild("Test", owner)
+	role := srv.AddRole(guild.ID, "Luna", botPermissions)
+	srv.AddMember(guild.ID, srv.BotUser, role.ID)
+	logCh := srv.AddChannel(guild.ID, "log", discordgo.ChannelTypeGuildText, "")
+	member := srv.AddUser("member", false)
+	srv.AddMember(guild.ID, member)
+
+	if err := db.UpsertGuild(guild.ID, guild.Name, "!"); err != nil {
+		t.Fatalf("upsert guild: %v", err)
+	}
+	settings, err := db.GetGuildSettings(guild.ID)
+	if err != nil {
+		t.Fatalf("get settings: %v", err)
+	}
+	settings.LoggingEnabled = true
+	settings.LogChannelID = logCh.ID
+	if err := db.UpsertGuildSettings(settings); err != nil {
+		t.Fatalf("update settings: %v", err)
+	}
+
+	pool := worker.NewPool(config.WorkerConfig{Workers: 2, QueueSize: 16, MaxPerGuild: 2}, log)
+	pool.Start()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		pool.Shutdown(ctx)
+	})
+
+	cfg := &config.Config{MessageCache: config.MessageCacheConfig{MemorySize: 10}}
+	return &auditEnv{
+		srv:    srv,
+		logger: NewLogger(shard.NewManagerFromSessions(srv.Session()), cfg, db, nil, pool, log),
+		pool:   pool,
+		guild:  guild,
+		logCh:  logCh,
+		member: member,
+	}
+}
+
+// remove はメンバーの退出イベントを処理し、監査ログを確認するタスクが終わるまで待ちます
+func (e *auditEnv) remove(t *testing.T) {
+	t.Helper()
+
+	session := e.logger.session
+	e.logger.onGuildMemberRemove(session, &discordgo.GuildMemberRemove{Member: &discordgo.Member{GuildID: e.guild.ID, User: e.member}})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for e.pool.Stats().Completed == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("audit log lookup did not run")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (e *auditEnv) auditRequests() int {
+	n := 0
+	for _, req := range e.srv.Requests() {
+		if req.Method == "GET" && req.Path == "/guilds/"+e.guild.ID+"/audit-logs" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestGuildMemberRemove(t *testing.T) {
+	tests := []struct {
+		name      string
+		action    discordgo.AuditLogAction // 0 の場合は監査ログに記録しない
+		wantTitle string                   // 空の場合はログを送らない
+	}{
+		{"leave", 0, "📤 メンバーが退出しました"},
+		{"kick", discordgo.AuditLogActionMemberKick, "👢 メンバーがキックされました"},
+		{"ban is left to the ban log", discordgo.AuditLogActionMemberBanAdd, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newAuditEnv(t, discordgo.PermissionAdministrator)
+			if tt.action != 0 {
+				action := tt.action
+				env.srv.AddAuditLogEntry(env.guild.ID, &discordgo.AuditLogEntry{ActionType: &action, TargetID: env.member.ID, UserID: "100"})
+			}
+
+			env.remove(t)
+
+			if n := env.auditRequests(); n != 1 {
+				t.Errorf("audit log requests = %d, want 1", n)
+			}
+			messages := env.srv.Messages(env.logCh.ID)
+			if tt.wantTitle == "" {
+				if len(messages) != 0 {
+					t.Errorf("sent %d log messages, want none", len(messages))
+				}
+				return
+			}
+			if len(messages) != 1 || len(messages[0].Embeds) != 1 {
+				t.Fatalf("sent %d log messages, want 1 embed", len(messages))
+			}
+			if got := messages[0].Embeds[0].Title; got != tt.wantTitle {
+				t.Errorf("title = %q, want %q", got, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestGuildMemberRemoveWithoutViewAuditLog(t *testing.T) {
+	env := newAuditEnv(t, discordgo.PermissionViewChannel|discordgo.PermissionSendMessages|discordgo.PermissionEmbedLinks)
+	kick := discordgo.AuditLogActionMemberKick
+	env.srv.AddAuditLogEntry(env.guild.ID, &discordgo.AuditLogEntry{ActionType: &kick, TargetID: env.member.ID, UserID: "100"})
+
+	// 監査ログを閲覧できない場合は待たずに退出として記録する
+	env.logger.onGuildMemberRemove(env.logger.session, &discordgo.GuildMemberRemove{Member: &discordgo.Member{GuildID: env.guild.ID, User: env.member}})
+
+	if n := env.auditRequests(); n != 0 {
+		t.Errorf("audit log requests = %d, want none", n)
+	}
+	messages := env.srv.Messages(env.logCh.ID)
+	if len(messages) != 1 || len(messages[0].Embeds) != 1 || messages[0].Embeds[0].Title != "📤 メンバーが退出しました" {
+		t.Errorf("log messages = %v, want one leave log", messages)
+	}
+}
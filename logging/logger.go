@@ -2,85 +2,165 @@ package logging
 
 import (
 	"fmt"
+	"log/slog"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/Sumire-Labs/Luna/config"
 	"github.com/Sumire-Labs/Luna/database"
 	"github.com/Sumire-Labs/Luna/embed"
+	"github.com/Sumire-Labs/Luna/shard"
+	"github.com/Sumire-Labs/Luna/worker"
 )
 
 type Logger struct {
 	session      *discordgo.Session
+	shards       *shard.Manager
 	config       *config.Config
 	db           *database.Service
+	pool         *worker.Pool
+	log          *slog.Logger
 	messageCache *MessageCache
-}
-
-// メッセージキャッシュ構造体
-type MessageCache struct {
-	mu       sync.RWMutex
-	messages map[string]*CachedMessage
-}
-
-// キャッシュされるメッセージ情報
-type CachedMessage struct {
-	Content     string
-	AuthorID    string
-	AuthorName  string
-	Attachments []string
-	Embeds      int
-	Timestamp   time.Time
+	audit        *auditTracker
+	voice        *voiceSessions // ボイスチャンネルに参加した時刻（退出時の滞在時間の計算用）
 }
 
 type LogEvent string
 
 const (
-	EventMessageEdit   LogEvent = "message_edit"
-	EventMessageDelete LogEvent = "message_delete"
-	EventMemberJoin    LogEvent = "member_join"
-	EventMemberLeave   LogEvent = "member_leave"
-	EventChannelCreate LogEvent = "channel_create"
-	EventChannelDelete LogEvent = "channel_delete"
-	EventChannelUpdate LogEvent = "channel_update"
-	EventRoleCreate    LogEvent = "role_create"
-	EventRoleDelete    LogEvent = "role_delete"
-	EventRoleUpdate    LogEvent = "role_update"
-	EventMemberBan     LogEvent = "member_ban"
-	EventMemberUnban   LogEvent = "member_unban"
-	EventMemberKick    LogEvent = "member_kick"
+	EventMessageEdit      LogEvent = "message_edit"
+	EventMessageDelete    LogEvent = "message_delete"
+	EventMemberJoin       LogEvent = "member_join"
+	EventMemberLeave      LogEvent = "member_leave"
+	EventChannelCreate    LogEvent = "channel_create"
+	EventChannelDelete    LogEvent = "channel_delete"
+	EventChannelUpdate    LogEvent = "channel_update"
+	EventRoleCreate       LogEvent = "role_create"
+	EventRoleDelete       LogEvent = "role_delete"
+	EventRoleUpdate       LogEvent = "role_update"
+	EventMemberBan        LogEvent = "member_ban"
+	EventMemberUnban      LogEvent = "member_unban"
+	EventMemberKick       LogEvent = "member_kick"
+	EventVoiceJoin        LogEvent = "voice_join"
+	EventVoiceLeave       LogEvent = "voice_leave"
+	EventVoiceMove        LogEvent = "voice_move"
+	EventVoiceState       LogEvent = "voice_state"
+	EventNicknameChange   LogEvent = "nickname_change"
+	EventMemberRoleUpdate LogEvent = "member_role_update"
+	EventMemberTimeout    LogEvent = "member_timeout"
 )
 
-func NewLogger(session *discordgo.Session, cfg *config.Config, db *database.Service) *Logger {
+// NewLogger はログ機能を作成します。store が nil の場合、メッセージはメモリにのみ保持します。
+func NewLogger(shards *shard.Manager, cfg *config.Config, db *database.Service, store *database.MessageStore, pool *worker.Pool, logger *slog.Logger) *Logger {
 	return &Logger{
-		session: session,
-		config:  cfg,
-		db:      db,
-		messageCache: &MessageCache{
-			messages: make(map[string]*CachedMessage),
-		},
+		session:      shards.Primary(),
+		shards:       shards,
+		config:       cfg,
+		db:           db,
+		pool:         pool,
+		log:          logger,
+		messageCache: NewMessageCache(cfg.MessageCache.MemorySize, store),
+		audit:        newAuditTracker(shards.Primary(), logger),
+		voice:        newVoiceSessions(),
 	}
 }
 
 func (l *Logger) RegisterHandlers() {
-	l.session.AddHandler(l.onMessageCreate)  // メッセージをキャッシュ
-	l.session.AddHandler(l.onMessageUpdate)
-	l.session.AddHandler(l.onMessageDelete)
-	l.session.AddHandler(l.onGuildMemberAdd)
-	l.session.AddHandler(l.onGuildMemberRemove)
-	l.session.AddHandler(l.onChannelCreate)
-	l.session.AddHandler(l.onChannelDelete)
-	l.session.AddHandler(l.onChannelUpdate)
-	l.session.AddHandler(l.onGuildRoleCreate)
-	l.session.AddHandler(l.onGuildRoleDelete)
-	l.session.AddHandler(l.onGuildRoleUpdate)
-	l.session.AddHandler(l.onGuildBanAdd)
-	l.session.AddHandler(l.onGuildBanRemove)
-	
-	// 古いメッセージを定期的にクリーンアップ
-	go l.cleanupOldMessages()
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.message_create", l.onMessageCreate))  // メッセージをキャッシュ
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.message_update", l.onMessageUpdate))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.message_delete", l.onMessageDelete))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.guild_member_add", l.onGuildMemberAdd))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.guild_member_remove", l.onGuildMemberRemove))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.guild_member_update", l.onGuildMemberUpdate))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.channel_create", l.onChannelCreate))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.channel_delete", l.onChannelDelete))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.channel_update", l.onChannelUpdate))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.guild_role_create", l.onGuildRoleCreate))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.guild_role_delete", l.onGuildRoleDelete))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.guild_role_update", l.onGuildRoleUpdate))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.guild_ban_add", l.onGuildBanAdd))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.guild_ban_remove", l.onGuildBanRemove))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.voice_state_update", l.onVoiceStateUpdate))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.guild_create", l.onGuildCreate))
+	l.shards.AddHandler(worker.Handler(l.pool, "logging.guild_delete", l.onGuildDelete))
+}
+
+// onGuildCreate は再接続などで受け取ったギルドの状態に合わせて、ボイスの参加記録を整理し、
+// メンバーの更新ログに必要なメンバーを要求します
+func (l *Logger) onGuildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
+	l.voice.resetGuild(g.ID, g.VoiceStates)
+	l.requestMembers(s, g.Guild)
+}
+
+// onGuildDelete はボットが退出したギルドのボイスの参加記録を消します（一時的に利用できない場合は残す）
+func (l *Logger) onGuildDelete(s *discordgo.Session, g *discordgo.GuildDelete) {
+	if g.Unavailable {
+		return
+	}
+	l.voice.resetGuild(g.ID, nil)
+}
+
+// MessageCache は編集・削除ログ用に保持しているメッセージを返します
+func (l *Logger) MessageCache() *MessageCache {
+	return l.messageCache
+}
+
+// retentionDays はギルドのメッセージを保存する日数です。
+// サーバーの設定（0 の場合は config.toml の既定値）を max_retention_days までに制限します。
+func (l *Logger) retentionDays(guildID string) int {
+	cfg := l.config.MessageCache
+	days := cfg.RetentionDays
+	if settings, err := l.db.GetGuildSettings(guildID); err == nil && settings.LogMessageRetentionDays > 0 {
+		days = settings.LogMessageRetentionDays
+	}
+	if cfg.MaxRetentionDays > 0 && days > cfg.MaxRetentionDays {
+		days = cfg.MaxRetentionDays
+	}
+	return days
+}
+
+// PruneMessages は保存期間を過ぎたメッセージを削除します（retention.Task）。
+// 編集・削除ログを無効にしたギルドのメッセージは期間に関係なく削除します。
+func (l *Logger) PruneMessages(now time.Time) (int64, error) {
+	if l.messageCache.store == nil {
+		return 0, nil
+	}
+	return l.messageCache.store.Prune(now, func(guildID string) int {
+		if settings, err := l.db.GetGuildSettings(guildID); err == nil && !cachesMessages(settings) {
+			return 0
+		}
+		return l.retentionDays(guildID)
+	})
+}
+
+// cachesMessages はギルドのメッセージを編集・削除ログのために保持するかどうかを返します
+func cachesMessages(settings *database.GuildSettings) bool {
+	return settings.LoggingEnabled && settings.LogChannelID != "" &&
+		(settings.LogMessageEdits || settings.LogMessageDeletes)
+}
+
+// cachedMessage は保存期間内のメッセージを返します（ない場合は nil）
+func (l *Logger) cachedMessage(guildID, messageID string) *database.StoredMessage {
+	msg, err := l.messageCache.Get(messageID)
+	if err != nil {
+		l.log.Warn("Failed to load cached message", "guild_id", guildID, "message_id", messageID, "error", err)
+		return nil
+	}
+	if msg == nil || time.Since(msg.CreatedAt) > time.Duration(l.retentionDays(guildID))*24*time.Hour {
+		return nil
+	}
+	return msg
+}
+
+// Intents はログ機能が購読するイベントに必要なゲートウェイインテントを返します
+func (l *Logger) Intents() discordgo.Intent {
+	return discordgo.IntentGuilds |
+		discordgo.IntentGuildMessages |
+		discordgo.IntentMessageContent |
+		discordgo.IntentGuildMembers |
+		discordgo.IntentGuildModeration |
+		discordgo.IntentGuildVoiceStates
 }
 
 func (l *Logger) shouldLog(guildID string, eventType LogEvent) (bool, string) {
@@ -102,6 +182,14 @@ func (l *Logger) shouldLog(guildID string, eventType LogEvent) (bool, string) {
 		return settings.LogMemberJoins, settings.LogChannelID
 	case EventMemberLeave:
 		return settings.LogMemberLeaves, settings.LogChannelID
+	case EventVoiceJoin, EventVoiceLeave, EventVoiceMove, EventVoiceState:
+		return settings.LogVoiceEvents, settings.LogChannelID
+	case EventNicknameChange:
+		return settings.LogNicknameChanges, settings.LogChannelID
+	case EventMemberRoleUpdate:
+		return settings.LogRoleEvents, settings.LogChannelID
+	case EventMemberTimeout:
+		return settings.LogModerationEvents, settings.LogChannelID
 	default:
 		return true, settings.LogChannelID
 	}
@@ -111,27 +199,27 @@ func (l *Logger) sendLogMessage(channelID string, embed *discordgo.MessageEmbed)
 	// チャンネルの権限を確認
 	channel, err := l.session.Channel(channelID)
 	if err != nil {
-		fmt.Printf("Failed to get log channel: %v\n", err)
+		l.log.Warn("Failed to get log channel", "channel_id", channelID, "error", err)
 		return
 	}
 	
 	// ボットの権限を確認
 	botPerms, err := l.session.UserChannelPermissions(l.session.State.User.ID, channelID)
 	if err != nil {
-		fmt.Printf("Failed to get bot permissions: %v\n", err)
+		l.log.Warn("Failed to get bot permissions", "guild_id", channel.GuildID, "channel_id", channelID, "error", err)
 		return
 	}
 	
 	// 必要な権限をチェック
 	requiredPerms := int64(discordgo.PermissionViewChannel | discordgo.PermissionSendMessages | discordgo.PermissionEmbedLinks)
 	if botPerms&requiredPerms != requiredPerms {
-		fmt.Printf("Bot lacks permissions for log channel %s in guild %s (has: %d, needs: %d)\n", 
-			channelID, channel.GuildID, botPerms, requiredPerms)
+		l.log.Warn("Bot lacks permissions for log channel",
+			"guild_id", channel.GuildID, "channel_id", channelID, "has", botPerms, "needs", requiredPerms)
 		
 		// 権限不足の場合、ログを無効化（オプション）
 		if settings, err := l.db.GetGuildSettings(channel.GuildID); err == nil && settings.LoggingEnabled {
 			// エラーメッセージを一度だけ出力
-			fmt.Printf("Disabling logging for guild %s due to permission issues\n", channel.GuildID)
+			l.log.Warn("Disabling logging due to permission issues", "guild_id", channel.GuildID)
 		}
 		return
 	}
@@ -139,50 +227,40 @@ func (l *Logger) sendLogMessage(channelID string, embed *discordgo.MessageEmbed)
 	_, err = l.session.ChannelMessageSendEmbed(channelID, embed)
 	if err != nil {
 		// ログチャンネルへの送信に失敗した場合のエラーハンドリング
-		fmt.Printf("Failed to send log message: %v\n", err)
+		l.log.Error("Failed to send log message", "guild_id", channel.GuildID, "channel_id", channelID, "error", err)
 	}
 }
 
-// メッセージ作成時にキャッシュ
+// メッセージ作成時にキャッシュ（編集・削除ログが有効なギルドのみ）
 func (l *Logger) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.Author == nil || m.Author.Bot {
-		return // ボットのメッセージは無視
+	if m.GuildID == "" || m.Author == nil || m.Author.Bot {
+		return // DM・ボットのメッセージは無視
+	}
+	if settings, err := l.db.GetGuildSettings(m.GuildID); err != nil || !cachesMessages(settings) {
+		return
 	}
-	
-	// メッセージ情報をキャッシュ
-	l.messageCache.mu.Lock()
-	defer l.messageCache.mu.Unlock()
 	
 	attachments := make([]string, len(m.Attachments))
 	for i, att := range m.Attachments {
 		attachments[i] = att.Filename
 	}
+
+	createdAt := m.Timestamp
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
 	
-	l.messageCache.messages[m.ID] = &CachedMessage{
-		Content:     m.Content,
+	l.messageCache.Add(&database.StoredMessage{
+		ID:          m.ID,
+		GuildID:     m.GuildID,
+		ChannelID:   m.ChannelID,
 		AuthorID:    m.Author.ID,
 		AuthorName:  m.Author.Username,
+		Content:     m.Content,
 		Attachments: attachments,
 		Embeds:      len(m.Embeds),
-		Timestamp:   time.Now(),
-	}
-}
-
-// 古いメッセージを定期的に削除
-func (l *Logger) cleanupOldMessages() {
-	ticker := time.NewTicker(30 * time.Minute)
-	defer ticker.Stop()
-	
-	for range ticker.C {
-		l.messageCache.mu.Lock()
-		now := time.Now()
-		for id, msg := range l.messageCache.messages {
-			if now.Sub(msg.Timestamp) > 2*time.Hour {
-				delete(l.messageCache.messages, id)
-			}
-		}
-		l.messageCache.mu.Unlock()
-	}
+		CreatedAt:   createdAt,
+	})
 }
 
 // メッセージ編集ログ
@@ -198,14 +276,10 @@ func (l *Logger) onMessageUpdate(s *discordgo.Session, m *discordgo.MessageUpdat
 
 	// キャッシュから元のメッセージを取得
 	var oldContent string
-	var cachedMsg *CachedMessage
-	
-	l.messageCache.mu.RLock()
-	if cached, exists := l.messageCache.messages[m.ID]; exists {
-		cachedMsg = cached
-		oldContent = cached.Content
+	cachedMsg := l.cachedMessage(m.GuildID, m.ID)
+	if cachedMsg != nil {
+		oldContent = cachedMsg.Content
 	}
-	l.messageCache.mu.RUnlock()
 	
 	// BeforeUpdateがあれば優先
 	if m.BeforeUpdate != nil && m.BeforeUpdate.Content != "" {
@@ -218,9 +292,8 @@ func (l *Logger) onMessageUpdate(s *discordgo.Session, m *discordgo.MessageUpdat
 	
 	// キャッシュを更新
 	if cachedMsg != nil {
-		l.messageCache.mu.Lock()
-		l.messageCache.messages[m.ID].Content = m.Content
-		l.messageCache.mu.Unlock()
+		cachedMsg.Content = m.Content
+		l.messageCache.Add(cachedMsg)
 	}
 
 	embedBuilder := embed.New().
@@ -261,6 +334,13 @@ func (l *Logger) onMessageDelete(s *discordgo.Session, m *discordgo.MessageDelet
 		return
 	}
 
+	// 削除されたメッセージの内容はログの有無に関係なく保持しない
+	defer func() {
+		if err := l.messageCache.Remove(m.ID); err != nil {
+			l.log.Warn("Failed to remove cached message", "guild_id", m.GuildID, "message_id", m.ID, "error", err)
+		}
+	}()
+
 	shouldLog, channelID := l.shouldLog(m.GuildID, EventMessageDelete)
 	if !shouldLog {
 		return
@@ -273,12 +353,15 @@ func (l *Logger) onMessageDelete(s *discordgo.Session, m *discordgo.MessageDelet
 		AddField("🕐 削除時刻", fmt.Sprintf("<t:%d:F>", time.Now().Unix()), true)
 
 	// キャッシュから削除されたメッセージ情報を取得
-	var cachedMsg *CachedMessage
-	l.messageCache.mu.RLock()
-	if cached, exists := l.messageCache.messages[m.ID]; exists {
-		cachedMsg = cached
+	cachedMsg := l.cachedMessage(m.GuildID, m.ID)
+
+	var authorID string
+	switch {
+	case m.BeforeDelete != nil && m.BeforeDelete.Author != nil:
+		authorID = m.BeforeDelete.Author.ID
+	case cachedMsg != nil:
+		authorID = cachedMsg.AuthorID
 	}
-	l.messageCache.mu.RUnlock()
 
 	// BeforeDeleteが利用可能な場合はそちらを優先
 	if m.BeforeDelete != nil {
@@ -335,17 +418,23 @@ func (l *Logger) onMessageDelete(s *discordgo.Session, m *discordgo.MessageDelet
 		// キャッシュもBeforeDeleteも利用できない場合
 		embedBuilder.AddField("📜 削除されたメッセージ", "*メッセージ情報を取得できませんでした*", false)
 	}
-	
-	// キャッシュから削除
-	if cachedMsg != nil {
-		l.messageCache.mu.Lock()
-		delete(l.messageCache.messages, m.ID)
-		l.messageCache.mu.Unlock()
-	}
 
 	embedBuilder.SetFooter(fmt.Sprintf("メッセージID: %s", m.ID), "")
 
-	l.sendLogMessage(channelID, embedBuilder.Build())
+	// 他人のメッセージの削除だけが監査ログに記録される（作成者が分からない場合は探さない）。
+	// 削除は頻繁に起きるため、反映の遅れを待たずに1回だけ確認する。
+	if authorID == "" {
+		l.sendLogMessage(channelID, embedBuilder.Build())
+		return
+	}
+	l.withAudit(m.GuildID, "audit.message_delete", []time.Duration{0}, func() *discordgo.AuditLogEntry {
+		return l.audit.findAggregated(m.GuildID, discordgo.AuditLogActionMessageDelete, func(entry *discordgo.AuditLogEntry) bool {
+			return entry.TargetID == authorID && entry.Options != nil && entry.Options.ChannelID == m.ChannelID
+		})
+	}, func(entry *discordgo.AuditLogEntry) {
+		addModerator(embedBuilder, entry)
+		l.sendLogMessage(channelID, embedBuilder.Build())
+	})
 }
 
 // メンバー参加ログ
@@ -382,41 +471,68 @@ func (l *Logger) onGuildMemberAdd(s *discordgo.Session, m *discordgo.GuildMember
 	l.sendLogMessage(channelID, embedBuilder.Build())
 }
 
-// メンバー退出ログ
+// メンバー退出・キックログ
 func (l *Logger) onGuildMemberRemove(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
-	shouldLog, channelID := l.shouldLog(m.GuildID, EventMemberLeave)
-	if !shouldLog {
+	logLeave, leaveChannelID := l.shouldLog(m.GuildID, EventMemberLeave)
+	logKick, kickChannelID := l.shouldLog(m.GuildID, EventMemberKick)
+	if !logLeave && !logKick {
 		return
 	}
 
-	embedBuilder := embed.New().
-		SetTitle("📤 メンバーが退出しました").
-		SetColor(embed.M3Colors.Error).
-		AddField("👤 ユーザー", fmt.Sprintf("<@%s>", m.User.ID), true).
-		AddField("🆔 ユーザーID", m.User.ID, true).
-		AddField("🕐 退出時刻", fmt.Sprintf("<t:%d:F>", time.Now().Unix()), true)
-
-	if m.User.Avatar != "" {
-		embedBuilder.SetThumbnail(m.User.AvatarURL("256"))
-	}
-
-	// 参加期間を計算（可能であれば）
-	guild, err := s.State.Guild(m.GuildID)
-	if err == nil {
+	// 参加期間は退出したメンバーが状態から消える前に計算する
+	var joinedAt time.Time
+	if guild, err := s.State.Guild(m.GuildID); err == nil {
 		for _, member := range guild.Members {
-			if member.User.ID == m.User.ID && !member.JoinedAt.IsZero() {
-				joinTime := member.JoinedAt
-				err := (error)(nil)
-				if err == nil {
-					duration := time.Since(joinTime)
-					embedBuilder.AddField("⏱️ 参加期間", fmt.Sprintf("%.0f日間", duration.Hours()/24), true)
-				}
+			if member.User.ID == m.User.ID {
+				joinedAt = member.JoinedAt
 				break
 			}
 		}
 	}
 
-	l.sendLogMessage(channelID, embedBuilder.Build())
+	// キック・BANは監査ログで自分からの退出と区別する（BANはBANログに記録する）
+	l.withAudit(m.GuildID, "audit.guild_member_remove", []time.Duration{auditDelay}, func() *discordgo.AuditLogEntry {
+		return l.audit.findTarget(m.GuildID, m.User.ID, discordgo.AuditLogActionMemberKick, discordgo.AuditLogActionMemberBanAdd)
+	}, func(entry *discordgo.AuditLogEntry) {
+		var embedBuilder *embed.Builder
+		var channelID string
+		switch removalKind(entry) {
+		case removalBan:
+			return
+		case removalKick:
+			if !logKick {
+				return
+			}
+			channelID = kickChannelID
+			embedBuilder = embed.New().
+				SetTitle("👢 メンバーがキックされました").
+				SetColor(embed.M3Colors.Error).
+				AddField("👤 ユーザー", fmt.Sprintf("<@%s>", m.User.ID), true).
+				AddField("🆔 ユーザーID", m.User.ID, true).
+				AddField("🕐 キック時刻", fmt.Sprintf("<t:%d:F>", time.Now().Unix()), true)
+			addModerator(embedBuilder, entry)
+		default:
+			if !logLeave {
+				return
+			}
+			channelID = leaveChannelID
+			embedBuilder = embed.New().
+				SetTitle("📤 メンバーが退出しました").
+				SetColor(embed.M3Colors.Error).
+				AddField("👤 ユーザー", fmt.Sprintf("<@%s>", m.User.ID), true).
+				AddField("🆔 ユーザーID", m.User.ID, true).
+				AddField("🕐 退出時刻", fmt.Sprintf("<t:%d:F>", time.Now().Unix()), true)
+		}
+
+		if m.User.Avatar != "" {
+			embedBuilder.SetThumbnail(m.User.AvatarURL("256"))
+		}
+		if !joinedAt.IsZero() {
+			embedBuilder.AddField("⏱️ 参加期間", fmt.Sprintf("%.0f日間", time.Since(joinedAt).Hours()/24), true)
+		}
+
+		l.sendLogMessage(channelID, embedBuilder.Build())
+	})
 }
 
 // チャンネル作成ログ
@@ -446,7 +562,7 @@ func (l *Logger) onChannelCreate(s *discordgo.Session, c *discordgo.ChannelCreat
 
 	embedBuilder.SetFooter(fmt.Sprintf("チャンネルID: %s", c.ID), "")
 
-	l.sendLogMessage(channelID, embedBuilder.Build())
+	l.sendWithModerator(channelID, embedBuilder, c.GuildID, c.ID, discordgo.AuditLogActionChannelCreate)
 }
 
 // チャンネル削除ログ
@@ -475,7 +591,7 @@ func (l *Logger) onChannelDelete(s *discordgo.Session, c *discordgo.ChannelDelet
 
 	embedBuilder.SetFooter(fmt.Sprintf("チャンネルID: %s", c.ID), "")
 
-	l.sendLogMessage(channelID, embedBuilder.Build())
+	l.sendWithModerator(channelID, embedBuilder, c.GuildID, c.ID, discordgo.AuditLogActionChannelDelete)
 }
 
 // チャンネル更新ログ
@@ -507,7 +623,12 @@ func (l *Logger) onChannelUpdate(s *discordgo.Session, c *discordgo.ChannelUpdat
 
 	embedBuilder.SetFooter(fmt.Sprintf("チャンネルID: %s", c.ID), "")
 
-	l.sendLogMessage(channelID, embedBuilder.Build())
+	// 権限の上書きの変更は別の種類のエントリになる
+	l.sendWithModerator(channelID, embedBuilder, c.GuildID, c.ID,
+		discordgo.AuditLogActionChannelUpdate,
+		discordgo.AuditLogActionChannelOverwriteCreate,
+		discordgo.AuditLogActionChannelOverwriteUpdate,
+		discordgo.AuditLogActionChannelOverwriteDelete)
 }
 
 // ロール作成ログ
@@ -529,7 +650,7 @@ func (l *Logger) onGuildRoleCreate(s *discordgo.Session, r *discordgo.GuildRoleC
 
 	embedBuilder.SetFooter(fmt.Sprintf("ロールID: %s", r.Role.ID), "")
 
-	l.sendLogMessage(channelID, embedBuilder.Build())
+	l.sendWithModerator(channelID, embedBuilder, r.GuildID, r.Role.ID, discordgo.AuditLogActionRoleCreate)
 }
 
 // ロール削除ログ
@@ -546,7 +667,7 @@ func (l *Logger) onGuildRoleDelete(s *discordgo.Session, r *discordgo.GuildRoleD
 
 	embedBuilder.SetFooter(fmt.Sprintf("ロールID: %s", r.RoleID), "")
 
-	l.sendLogMessage(channelID, embedBuilder.Build())
+	l.sendWithModerator(channelID, embedBuilder, r.GuildID, r.RoleID, discordgo.AuditLogActionRoleDelete)
 }
 
 // ロール更新ログ
@@ -564,10 +685,11 @@ func (l *Logger) onGuildRoleUpdate(s *discordgo.Session, r *discordgo.GuildRoleU
 		AddField("🎭 ロール", fmt.Sprintf("<@&%s>", r.Role.ID), true).
 		AddField("🏷️ ロール名", r.Role.Name, true).
 		AddField("🎨 カラー", fmt.Sprintf("#%06X", r.Role.Color), true).
-		AddField("🕐 更新時刻", fmt.Sprintf("<t:%d:F>", time.Now().Unix()), true).
-		SetFooter(fmt.Sprintf("ロールID: %s", r.Role.ID), "")
+		AddField("🕐 更新時刻", fmt.Sprintf("<t:%d:F>", time.Now().Unix()), true)
 
-	l.sendLogMessage(channelID, embedBuilder.Build())
+	embedBuilder.SetFooter(fmt.Sprintf("ロールID: %s", r.Role.ID), "")
+
+	l.sendWithModerator(channelID, embedBuilder, r.GuildID, r.Role.ID, discordgo.AuditLogActionRoleUpdate)
 }
 
 // BANログ
@@ -588,7 +710,7 @@ func (l *Logger) onGuildBanAdd(s *discordgo.Session, b *discordgo.GuildBanAdd) {
 		embedBuilder.SetThumbnail(b.User.AvatarURL("256"))
 	}
 
-	l.sendLogMessage(channelID, embedBuilder.Build())
+	l.sendWithModerator(channelID, embedBuilder, b.GuildID, b.User.ID, discordgo.AuditLogActionMemberBanAdd)
 }
 
 // BAN解除ログ
@@ -609,7 +731,7 @@ func (l *Logger) onGuildBanRemove(s *discordgo.Session, b *discordgo.GuildBanRem
 		embedBuilder.SetThumbnail(b.User.AvatarURL("256"))
 	}
 
-	l.sendLogMessage(channelID, embedBuilder.Build())
+	l.sendWithModerator(channelID, embedBuilder, b.GuildID, b.User.ID, discordgo.AuditLogActionMemberBanRemove)
 }
 
 // ヘルパー関数
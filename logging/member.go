@@ -0,0 +1,174 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/embed"
+)
+
+// メンバー更新ログ（ニックネーム・サーバーアバター・ロール・タイムアウト）。
+// 変更前の状態は State のキャッシュから取得するため、キャッシュされていないメンバーは記録しない
+// （メンバーのログが有効なギルドでは、GUILD_CREATE で全メンバーを要求してキャッシュする）。
+func (l *Logger) onGuildMemberUpdate(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+	if m.Member == nil || m.User == nil || m.BeforeUpdate == nil {
+		return
+	}
+	before, after := m.BeforeUpdate, m.Member
+
+	groups := []struct {
+		event   LogEvent
+		title   string
+		color   int
+		changes []ChangeInfo
+	}{
+		{EventNicknameChange, "✏️ メンバーのプロフィールが変更されました", embed.M3Colors.Info, l.detectProfileChanges(before, after)},
+		{EventMemberRoleUpdate, "🎭 メンバーのロールが変更されました", embed.M3Colors.Warning, l.detectMemberRoleChanges(before, after)},
+		{EventMemberTimeout, "⏳ メンバーのタイムアウトが変更されました", embed.M3Colors.Warning, l.detectTimeoutChanges(before, after)},
+	}
+
+	for _, group := range groups {
+		if len(group.changes) == 0 {
+			continue
+		}
+		shouldLog, channelID := l.shouldLog(m.GuildID, group.event)
+		if !shouldLog {
+			continue
+		}
+
+		embedBuilder := embed.New().
+			SetTitle(group.title).
+			SetColor(group.color).
+			AddField("👤 ユーザー", fmt.Sprintf("<@%s>", after.User.ID), true).
+			AddField("🕐 更新時刻", fmt.Sprintf("<t:%d:F>", time.Now().Unix()), true).
+			SetThumbnail(after.AvatarURL("256"))
+
+		for _, change := range group.changes {
+			embedBuilder.AddField(change.Field, change.Description, false)
+		}
+
+		embedBuilder.SetFooter(fmt.Sprintf("ユーザーID: %s", after.User.ID), "")
+
+		l.sendLogMessage(channelID, embedBuilder.Build())
+	}
+}
+
+// requestMembers はメンバーの更新ログが有効なギルドの全メンバーを要求します。
+// 大きなギルドの GUILD_CREATE には一部のメンバーしか含まれないため、そのままでは変更前の状態が分かりません。
+// 受け取ったメンバー（GUILD_MEMBERS_CHUNK）は State に追加されます。
+func (l *Logger) requestMembers(s *discordgo.Session, g *discordgo.Guild) {
+	if g.MemberCount > 0 && len(g.Members) >= g.MemberCount {
+		return
+	}
+	settings, err := l.db.GetGuildSettings(g.ID)
+	if err != nil || !settings.LoggingEnabled || settings.LogChannelID == "" {
+		return
+	}
+	if !settings.LogNicknameChanges && !settings.LogRoleEvents && !settings.LogModerationEvents {
+		return
+	}
+
+	if err := s.RequestGuildMembers(g.ID, "", 0, "", false); err != nil {
+		l.log.Warn("Failed to request guild members", "guild_id", g.ID, "error", err)
+	}
+}
+
+// detectProfileChanges はニックネームとサーバーアバターの変更を返します
+func (l *Logger) detectProfileChanges(before, after *discordgo.Member) []ChangeInfo {
+	var changes []ChangeInfo
+
+	if before.Nick != after.Nick {
+		beforeNick := before.Nick
+		if beforeNick == "" {
+			beforeNick = "未設定"
+		}
+		afterNick := after.Nick
+		if afterNick == "" {
+			afterNick = "未設定"
+		}
+		changes = append(changes, ChangeInfo{
+			Field:       "📝 ニックネーム",
+			Description: fmt.Sprintf("`%s` → `%s`", beforeNick, afterNick),
+		})
+	}
+
+	if before.Avatar != after.Avatar {
+		description := "変更しました"
+		switch {
+		case before.Avatar == "":
+			description = "設定しました"
+		case after.Avatar == "":
+			description = "削除しました"
+		}
+		changes = append(changes, ChangeInfo{
+			Field:       "🖼️ サーバーアバター",
+			Description: description,
+		})
+	}
+
+	return changes
+}
+
+// detectMemberRoleChanges は追加・削除されたロールを返します
+func (l *Logger) detectMemberRoleChanges(before, after *discordgo.Member) []ChangeInfo {
+	var changes []ChangeInfo
+
+	if added := roleDifference(after.Roles, before.Roles); len(added) > 0 {
+		changes = append(changes, ChangeInfo{
+			Field:       "➕ 追加されたロール",
+			Description: strings.Join(added, " "),
+		})
+	}
+
+	if removed := roleDifference(before.Roles, after.Roles); len(removed) > 0 {
+		changes = append(changes, ChangeInfo{
+			Field:       "➖ 削除されたロール",
+			Description: strings.Join(removed, " "),
+		})
+	}
+
+	return changes
+}
+
+// detectTimeoutChanges はタイムアウトの設定・解除を返します（期限切れによる解除は Discord から通知されない）
+func (l *Logger) detectTimeoutChanges(before, after *discordgo.Member) []ChangeInfo {
+	var changes []ChangeInfo
+
+	now := time.Now()
+	wasTimedOut := before.CommunicationDisabledUntil != nil && before.CommunicationDisabledUntil.After(now)
+	isTimedOut := after.CommunicationDisabledUntil != nil && after.CommunicationDisabledUntil.After(now)
+
+	switch {
+	case isTimedOut && (!wasTimedOut || !before.CommunicationDisabledUntil.Equal(*after.CommunicationDisabledUntil)):
+		until := after.CommunicationDisabledUntil.Unix()
+		changes = append(changes, ChangeInfo{
+			Field:       "🔇 タイムアウト",
+			Description: fmt.Sprintf("<t:%d:F> まで（<t:%d:R>）", until, until),
+		})
+	case wasTimedOut && !isTimedOut:
+		changes = append(changes, ChangeInfo{
+			Field:       "🔊 タイムアウト解除",
+			Description: "タイムアウトが解除されました",
+		})
+	}
+
+	return changes
+}
+
+// roleDifference は a にあって b にないロールをメンション形式で返します
+func roleDifference(a, b []string) []string {
+	exists := make(map[string]bool, len(b))
+	for _, id := range b {
+		exists[id] = true
+	}
+
+	var mentions []string
+	for _, id := range a {
+		if !exists[id] {
+			mentions = append(mentions, fmt.Sprintf("<@&%s>", id))
+		}
+	}
+	return mentions
+}
@@ -0,0 +1,150 @@
+package logging
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/Sumire-Labs/Luna/database"
+)
+
+// MessageCache は編集・削除ログで元の内容を表示するために最近のメッセージを保持します。
+// メモリには最大 size 件を LRU で保持し、store がある場合は SQLite にも保存するため、
+// メモリから追い出された後や再起動後でも保存期間内であれば元の内容を返せます。
+type MessageCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List               // 先頭ほど最近使用したメッセージ（値は *database.StoredMessage）
+	items map[string]*list.Element // メッセージID → order の要素
+	store *database.MessageStore   // nil の場合はメモリのみ
+}
+
+func NewMessageCache(size int, store *database.MessageStore) *MessageCache {
+	if size <= 0 {
+		size = 10000
+	}
+	return &MessageCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+		store: store,
+	}
+}
+
+// Add はメッセージを保持します（同じIDは上書き）
+func (c *MessageCache) Add(msg *database.StoredMessage) {
+	c.mu.Lock()
+	c.remember(msg)
+	c.mu.Unlock()
+
+	if c.store != nil {
+		c.store.Put(msg)
+	}
+}
+
+// remember はメモリに保持し、上限を超えた分を古い順に追い出します（c.mu を保持して呼び出す）
+func (c *MessageCache) remember(msg *database.StoredMessage) {
+	copied := *msg
+	if elem, ok := c.items[msg.ID]; ok {
+		elem.Value = &copied
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[msg.ID] = c.order.PushFront(&copied)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*database.StoredMessage).ID)
+	}
+}
+
+// Get はメッセージを返します。メモリにない場合は store から読み込みます（どちらにもない場合は nil）。
+func (c *MessageCache) Get(id string) (*database.StoredMessage, error) {
+	c.mu.Lock()
+	if elem, ok := c.items[id]; ok {
+		c.order.MoveToFront(elem)
+		copied := *elem.Value.(*database.StoredMessage)
+		c.mu.Unlock()
+		return &copied, nil
+	}
+	c.mu.Unlock()
+
+	if c.store == nil {
+		return nil, nil
+	}
+	msg, err := c.store.Get(id)
+	if err != nil || msg == nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.remember(msg)
+	c.mu.Unlock()
+	return msg, nil
+}
+
+// Remove はメッセージを削除します
+func (c *MessageCache) Remove(id string) error {
+	c.mu.Lock()
+	if elem, ok := c.items[id]; ok {
+		c.order.Remove(elem)
+		delete(c.items, id)
+	}
+	c.mu.Unlock()
+
+	if c.store == nil {
+		return nil
+	}
+	return c.store.Delete(id)
+}
+
+// ExportAuthor はユーザーが作成したメッセージを返します（database.MessageArchive）
+func (c *MessageCache) ExportAuthor(userID string) ([]*database.StoredMessage, error) {
+	if c.store != nil {
+		return c.store.ExportAuthor(userID)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var messages []*database.StoredMessage
+	for elem := c.order.Back(); elem != nil; elem = elem.Prev() {
+		if msg := elem.Value.(*database.StoredMessage); msg.AuthorID == userID {
+			copied := *msg
+			messages = append(messages, &copied)
+		}
+	}
+	return messages, nil
+}
+
+// DeleteAuthor はユーザーが作成したメッセージを削除します（database.MessageArchive）
+func (c *MessageCache) DeleteAuthor(userID string) (int64, error) {
+	removed := c.forget(func(msg *database.StoredMessage) bool { return msg.AuthorID == userID })
+	if c.store == nil {
+		return removed, nil
+	}
+	return c.store.DeleteAuthor(userID)
+}
+
+// DeleteGuild はギルドのメッセージを削除します（database.MessageArchive）
+func (c *MessageCache) DeleteGuild(guildID string) (int64, error) {
+	removed := c.forget(func(msg *database.StoredMessage) bool { return msg.GuildID == guildID })
+	if c.store == nil {
+		return removed, nil
+	}
+	return c.store.DeleteGuild(guildID)
+}
+
+// forget は match に一致するメッセージをメモリから削除し、削除した件数を返します
+func (c *MessageCache) forget(match func(msg *database.StoredMessage) bool) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var removed int64
+	for id, elem := range c.items {
+		if match(elem.Value.(*database.StoredMessage)) {
+			c.order.Remove(elem)
+			delete(c.items, id)
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,224 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/embed"
+)
+
+// voiceKey はボイスの参加状況を記録するギルドとユーザーの組です
+type voiceKey struct {
+	guildID, userID string
+}
+
+// voiceSessions はボイスチャンネルに参加した時刻を記録し、退出時に滞在時間を求めます。
+// 起動前から参加していたユーザーの参加時刻は分かりません。切断中に退出したユーザーの記録が残らないよう、
+// GUILD_CREATE ごとにその時点で参加していないユーザーの記録を消します。
+type voiceSessions struct {
+	mu     sync.Mutex
+	joined map[voiceKey]time.Time
+}
+
+func newVoiceSessions() *voiceSessions {
+	return &voiceSessions{joined: make(map[voiceKey]time.Time)}
+}
+
+func (v *voiceSessions) join(key voiceKey, at time.Time) {
+	v.mu.Lock()
+	v.joined[key] = at
+	v.mu.Unlock()
+}
+
+// leave は参加していた時間を返します（参加時刻が分からない場合は false）
+func (v *voiceSessions) leave(key voiceKey, at time.Time) (time.Duration, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	joined, ok := v.joined[key]
+	if !ok {
+		return 0, false
+	}
+	delete(v.joined, key)
+	return at.Sub(joined), true
+}
+
+// resetGuild はギルドの記録のうち、states（GUILD_CREATE のボイスの状態）に含まれないユーザーの分を消します
+func (v *voiceSessions) resetGuild(guildID string, states []*discordgo.VoiceState) {
+	present := make(map[string]bool, len(states))
+	for _, state := range states {
+		if state.ChannelID != "" {
+			present[state.UserID] = true
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for key := range v.joined {
+		if key.guildID == guildID && !present[key.userID] {
+			delete(v.joined, key)
+		}
+	}
+}
+
+// ボイスチャンネルの参加・退出・移動・状態の変更ログ
+func (l *Logger) onVoiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+	if v.GuildID == "" || (v.Member != nil && v.Member.User != nil && v.Member.User.Bot) {
+		return
+	}
+
+	var before discordgo.VoiceState
+	if v.BeforeUpdate != nil {
+		before = *v.BeforeUpdate
+	}
+	after := v.VoiceState
+	now := time.Now()
+	key := voiceKey{guildID: v.GuildID, userID: v.UserID}
+
+	var event LogEvent
+	var duration time.Duration
+	var hasDuration bool
+	switch {
+	case before.ChannelID == "" && after.ChannelID != "":
+		event = EventVoiceJoin
+		// 滞在時間はログの設定に関係なく記録する（途中で有効にしても退出時に表示できるように）
+		l.voice.join(key, now)
+	case before.ChannelID != "" && after.ChannelID == "":
+		event = EventVoiceLeave
+		duration, hasDuration = l.voice.leave(key, now)
+	case before.ChannelID != after.ChannelID:
+		event = EventVoiceMove
+	default:
+		event = EventVoiceState
+	}
+
+	shouldLog, channelID := l.shouldLog(v.GuildID, event)
+	if !shouldLog {
+		return
+	}
+
+	embedBuilder := embed.New().
+		AddField("👤 ユーザー", fmt.Sprintf("<@%s>", v.UserID), true).
+		SetFooter(fmt.Sprintf("ユーザーID: %s", v.UserID), "")
+
+	switch event {
+	case EventVoiceJoin:
+		embedBuilder.
+			SetTitle("🔊 ボイスチャンネルに参加しました").
+			SetColor(embed.M3Colors.Success).
+			AddField("📍 チャンネル", fmt.Sprintf("<#%s>", after.ChannelID), true).
+			AddField("🕐 参加時刻", fmt.Sprintf("<t:%d:F>", now.Unix()), true)
+
+	case EventVoiceLeave:
+		embedBuilder.
+			SetTitle("🔇 ボイスチャンネルから退出しました").
+			SetColor(embed.M3Colors.Error).
+			AddField("📍 チャンネル", fmt.Sprintf("<#%s>", before.ChannelID), true).
+			AddField("🕐 退出時刻", fmt.Sprintf("<t:%d:F>", now.Unix()), true)
+		if hasDuration {
+			embedBuilder.AddField("⏱️ 滞在時間", formatDuration(duration), true)
+		}
+		// モデレーターによる切断は監査ログで判別する。切断のエントリには対象のユーザーが含まれないため、
+		// 同じ時間帯に自分で退出したユーザーを切断されたと表示する場合がある（ベストエフォート）。
+		// 処理するまでに別のチャンネルへ参加し直している場合は切断ではないため確認しない。
+		if _, err := s.State.VoiceState(v.GuildID, v.UserID); err != nil {
+			l.withAudit(v.GuildID, "audit.voice_state_update", []time.Duration{0, auditRetryDelay}, func() *discordgo.AuditLogEntry {
+				return l.audit.findAggregated(v.GuildID, discordgo.AuditLogActionMemberDisconnect, matchAny)
+			}, func(entry *discordgo.AuditLogEntry) {
+				if entry != nil {
+					embedBuilder.SetTitle("⛔ ボイスチャンネルから切断されました")
+					addModerator(embedBuilder, entry)
+				}
+				l.sendLogMessage(channelID, embedBuilder.Build())
+			})
+			return
+		}
+
+	case EventVoiceMove:
+		embedBuilder.
+			SetTitle("🔀 ボイスチャンネルを移動しました").
+			SetColor(embed.M3Colors.Info).
+			AddField("📍 チャンネル", fmt.Sprintf("<#%s> → <#%s>", before.ChannelID, after.ChannelID), false).
+			AddField("🕐 移動時刻", fmt.Sprintf("<t:%d:F>", now.Unix()), true)
+		l.withAudit(v.GuildID, "audit.voice_state_update", []time.Duration{0, auditRetryDelay}, func() *discordgo.AuditLogEntry {
+			return l.audit.findAggregated(v.GuildID, discordgo.AuditLogActionMemberMove, func(entry *discordgo.AuditLogEntry) bool {
+				return entry.Options != nil && entry.Options.ChannelID == after.ChannelID
+			})
+		}, func(entry *discordgo.AuditLogEntry) {
+			addModerator(embedBuilder, entry)
+			l.sendLogMessage(channelID, embedBuilder.Build())
+		})
+		return
+
+	case EventVoiceState:
+		changes := l.detectVoiceChanges(&before, after)
+		if len(changes) == 0 {
+			return // 自分のミュート・スピーカーミュートなどは記録しない
+		}
+		embedBuilder.
+			SetTitle("🎛️ ボイスの状態が変わりました").
+			SetColor(embed.M3Colors.Warning).
+			AddField("📍 チャンネル", fmt.Sprintf("<#%s>", after.ChannelID), true).
+			AddField("🕐 変更時刻", fmt.Sprintf("<t:%d:F>", now.Unix()), true)
+		for _, change := range changes {
+			embedBuilder.AddField(change.Field, change.Description, false)
+		}
+	}
+
+	l.sendLogMessage(channelID, embedBuilder.Build())
+}
+
+// detectVoiceChanges はサーバーミュート・サーバースピーカーミュート・配信の変更を返します
+func (l *Logger) detectVoiceChanges(before, after *discordgo.VoiceState) []ChangeInfo {
+	var changes []ChangeInfo
+
+	if before.Mute != after.Mute {
+		changes = append(changes, ChangeInfo{
+			Field:       "🎙️ サーバーミュート",
+			Description: fmt.Sprintf("`%s` → `%s`", l.getBoolString(before.Mute), l.getBoolString(after.Mute)),
+		})
+	}
+
+	if before.Deaf != after.Deaf {
+		changes = append(changes, ChangeInfo{
+			Field:       "🎧 サーバースピーカーミュート",
+			Description: fmt.Sprintf("`%s` → `%s`", l.getBoolString(before.Deaf), l.getBoolString(after.Deaf)),
+		})
+	}
+
+	if before.SelfStream != after.SelfStream {
+		description := "配信を開始しました"
+		if !after.SelfStream {
+			description = "配信を終了しました"
+		}
+		changes = append(changes, ChangeInfo{
+			Field:       "📺 画面共有",
+			Description: description,
+		})
+	}
+
+	return changes
+}
+
+func matchAny(entry *discordgo.AuditLogEntry) bool {
+	return true
+}
+
+// formatDuration は滞在時間を「1時間23分」のように表示します
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	seconds := int(d.Seconds()) % 60
+
+	switch {
+	case hours > 0:
+		return fmt.Sprintf("%d時間%d分", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%d分%d秒", minutes, seconds)
+	default:
+		return fmt.Sprintf("%d秒", seconds)
+	}
+}
@@ -0,0 +1,119 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/shard"
+	"github.com/Sumire-Labs/Luna/worker"
+)
+
+// リプレイできるイベントと対応する構造体
+var eventTypes = map[string]func() interface{}{
+	"READY":                        func() interface{} { return &discordgo.Ready{} },
+	"GUILD_CREATE":                 func() interface{} { return &discordgo.GuildCreate{} },
+	"GUILD_UPDATE":                 func() interface{} { return &discordgo.GuildUpdate{} },
+	"GUILD_DELETE":                 func() interface{} { return &discordgo.GuildDelete{} },
+	"GUILD_MEMBER_ADD":             func() interface{} { return &discordgo.GuildMemberAdd{} },
+	"GUILD_MEMBER_UPDATE":          func() interface{} { return &discordgo.GuildMemberUpdate{} },
+	"GUILD_MEMBER_REMOVE":          func() interface{} { return &discordgo.GuildMemberRemove{} },
+	"GUILD_ROLE_CREATE":            func() interface{} { return &discordgo.GuildRoleCreate{} },
+	"GUILD_ROLE_UPDATE":            func() interface{} { return &discordgo.GuildRoleUpdate{} },
+	"GUILD_ROLE_DELETE":            func() interface{} { return &discordgo.GuildRoleDelete{} },
+	"GUILD_BAN_ADD":                func() interface{} { return &discordgo.GuildBanAdd{} },
+	"GUILD_BAN_REMOVE":             func() interface{} { return &discordgo.GuildBanRemove{} },
+	"GUILD_AUDIT_LOG_ENTRY_CREATE": func() interface{} { return &discordgo.GuildAuditLogEntryCreate{} },
+	"CHANNEL_CREATE":               func() interface{} { return &discordgo.ChannelCreate{} },
+	"CHANNEL_UPDATE":               func() interface{} { return &discordgo.ChannelUpdate{} },
+	"CHANNEL_DELETE":               func() interface{} { return &discordgo.ChannelDelete{} },
+	"MESSAGE_CREATE":               func() interface{} { return &discordgo.MessageCreate{} },
+	"MESSAGE_UPDATE":               func() interface{} { return &discordgo.MessageUpdate{} },
+	"MESSAGE_DELETE":               func() interface{} { return &discordgo.MessageDelete{} },
+	"MESSAGE_DELETE_BULK":          func() interface{} { return &discordgo.MessageDeleteBulk{} },
+	"INTERACTION_CREATE":           func() interface{} { return &discordgo.InteractionCreate{} },
+	"VOICE_STATE_UPDATE":           func() interface{} { return &discordgo.VoiceStateUpdate{} },
+}
+
+// Decode は記録されたイベントを discordgo の型に変換します
+func Decode(rec *Record) (interface{}, error) {
+	newEvent, ok := eventTypes[rec.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported event type: %s", rec.Type)
+	}
+
+	event := newEvent()
+	if err := json.Unmarshal(rec.Data, event); err != nil {
+		return nil, fmt.Errorf("failed to decode %s event: %w", rec.Type, err)
+	}
+	return event, nil
+}
+
+// ReadRecords は記録ファイルを先頭から1件ずつ読み込みます。fn がエラーを返すと中断します。
+func ReadRecords(path string, fn func(line int, rec *Record) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// GUILD_CREATE は大きくなるためバッファを広げる
+	scanner.Buffer(make([]byte, 0, 1<<20), 64<<20)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(line, &rec); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Player は記録されたイベントを登録済みのハンドラーに1件ずつ渡します
+type Player struct {
+	shards *shard.Manager
+	pool   *worker.Pool
+}
+
+func NewPlayer(shards *shard.Manager, pool *worker.Pool) *Player {
+	return &Player{
+		shards: shards,
+		pool:   pool,
+	}
+}
+
+// Play はイベントをステートに反映してからハンドラーに渡し、処理が終わるまで待ちます
+func (p *Player) Play(event interface{}) {
+	session := p.shards.Primary()
+
+	// ゲートウェイ受信時と同様に、ハンドラーより先にステートを更新する
+	// （見つからないギルドなどのエラーは実際の受信時と同じく無視する）
+	session.State.OnInterface(session, event)
+
+	p.shards.Dispatch(session, event)
+	p.waitIdle()
+}
+
+func (p *Player) waitIdle() {
+	for {
+		// 取り出し直後のタスクは Running にも Queued にも現れないため、累計数で判定する
+		stats := p.pool.Stats()
+		if stats.Completed+stats.TimedOut+stats.Skipped >= stats.Submitted {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,146 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/shard"
+)
+
+const redactedValue = "[REDACTED]"
+
+// 常に伏せるフィールド（インタラクションのトークンは15分間有効な認証情報のため）
+var alwaysRedacted = []string{"token"}
+
+// リプレイ時にギルドの状態を復元するため、フィルターに関係なく記録するイベント
+var alwaysRecorded = []string{"READY", "GUILD_CREATE"}
+
+// Record はJSONLファイルの1行分のイベントです
+type Record struct {
+	Time  time.Time       `json:"time"`
+	Shard int             `json:"shard"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Recorder はゲートウェイから受信した生のイベントをJSONLファイルに書き出します
+type Recorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+	events  map[string]bool
+	redact  map[string]bool
+	log     *slog.Logger
+}
+
+func NewRecorder(cfg config.RecorderConfig, logger *slog.Logger) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	file, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording file: %w", err)
+	}
+
+	r := &Recorder{
+		file:    file,
+		encoder: json.NewEncoder(file),
+		redact:  make(map[string]bool),
+		log:     logger,
+	}
+
+	if len(cfg.Events) > 0 {
+		r.events = make(map[string]bool)
+		for _, name := range append(cfg.Events, alwaysRecorded...) {
+			r.events[strings.ToUpper(name)] = true
+		}
+	}
+	for _, field := range append(cfg.RedactFields, alwaysRedacted...) {
+		r.redact[field] = true
+	}
+
+	logger.Info("Recording gateway events", "path", cfg.Path)
+	return r, nil
+}
+
+// Attach は全シャードのイベントを記録するハンドラーを登録します
+func (r *Recorder) Attach(shards *shard.Manager) {
+	shards.AddHandler(r.onEvent)
+}
+
+func (r *Recorder) onEvent(s *discordgo.Session, e *discordgo.Event) {
+	if e.Type == "" || (r.events != nil && !r.events[e.Type]) {
+		return
+	}
+
+	data, err := r.redactFields(e.RawData)
+	if err != nil {
+		r.log.Warn("Failed to redact event", "event", e.Type, "shard", s.ShardID, "error", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return
+	}
+	if err := r.encoder.Encode(&Record{Time: time.Now(), Shard: s.ShardID, Type: e.Type, Data: data}); err != nil {
+		r.log.Warn("Failed to record event", "event", e.Type, "shard", s.ShardID, "error", err)
+	}
+}
+
+// redactFields は指定されたフィールドの値を再帰的に伏せ字にします
+func (r *Recorder) redactFields(raw json.RawMessage) (json.RawMessage, error) {
+	// 数値は精度を落とさないよう json.Number のまま扱う
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r.redactValue(v))
+}
+
+func (r *Recorder) redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			if r.redact[key] {
+				if _, isString := value.(string); isString {
+					t[key] = redactedValue
+					continue
+				}
+			}
+			t[key] = r.redactValue(value)
+		}
+	case []interface{}:
+		for i, value := range t {
+			t[i] = r.redactValue(value)
+		}
+	}
+	return v
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
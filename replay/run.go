@@ -0,0 +1,198 @@
+package replay
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/bot"
+	"github.com/Sumire-Labs/Luna/bump"
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/discordtest"
+	"github.com/Sumire-Labs/Luna/lifecycle"
+	"github.com/Sumire-Labs/Luna/logging"
+	"github.com/Sumire-Labs/Luna/shard"
+	"github.com/Sumire-Labs/Luna/worker"
+)
+
+var errQuit = errors.New("replay stopped")
+
+// Run は `luna replay` を実行します。
+// 記録したイベントを一時データベースとオフラインのDiscord APIに対して再生し、
+// ボットが行ったAPI呼び出しをイベントごとに表示します。
+func Run(cfg *config.Config, logger *slog.Logger, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	file := fs.String("file", cfg.Recorder.Path, "再生するイベント記録 (JSONL)")
+	// 一時データベースは常に SQLite（PostgreSQL の場合は空のデータベースから始める）
+	defaultSource := cfg.Database.Path
+	if cfg.Database.Driver == database.DriverPostgres {
+		defaultSource = ""
+	}
+	source := fs.String("db", defaultSource, "一時データベースの元にする SQLite データベース（空の場合は空のデータベース）")
+	step := fs.Bool("step", false, "1イベントごとに Enter で進める")
+	guildID := fs.String("guild", "", "指定したギルドのイベントのみ再生する")
+	keep := fs.Bool("keep", false, "終了後も一時データベースを残す")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "luna-replay-")
+	if err != nil {
+		return err
+	}
+	if *keep {
+		defer fmt.Printf("Scratch database kept at %s\n", filepath.Join(dir, "luna.db"))
+	} else {
+		defer os.RemoveAll(dir)
+	}
+
+	db, err := openScratchDatabase(*source, filepath.Join(dir, "luna.db"))
+	if err != nil {
+		return err
+	}
+	dbService := database.NewService(db, logger)
+	defer dbService.Close()
+
+	if err := dbService.Migrate(); err != nil {
+		return err
+	}
+
+	srv := discordtest.NewServer()
+	defer srv.Close()
+
+	session := srv.Session()
+	shards := shard.NewManagerFromSessions(session)
+
+	// 再生順を保つため1ワーカーで実行する
+	pool := worker.NewPool(config.WorkerConfig{Workers: 1, MaxPerGuild: 1, TaskTimeout: cfg.Worker.TaskTimeout}, logger)
+	pool.Start()
+	lc := lifecycle.NewManager(5*time.Second, logger)
+	defer lc.Shutdown()
+	writes := database.NewWriteBuffer(dbService, cfg.Database, logger)
+	writes.Start()
+	lc.OnStop("write buffer", writes.Close)
+	lc.OnStop("worker pool", pool.Shutdown)
+
+	bot.New(shards, cfg, dbService, writes, pool, logger).RegisterHandlers()
+	bump.NewHandler(shards, dbService, pool, lc).RegisterHandlers()
+	if cfg.Features.EnableLogging {
+		// 再生中のメッセージは本番のメッセージの保存先に書き込まない
+		logging.NewLogger(shards, cfg, dbService, nil, pool, logger).RegisterHandlers()
+	}
+
+	player := NewPlayer(shards, pool)
+	stdin := bufio.NewReader(os.Stdin)
+	seen := 0
+	played := 0
+
+	err = ReadRecords(*file, func(line int, rec *Record) error {
+		event, err := Decode(rec)
+		if err != nil {
+			fmt.Printf("#%d %s: skipped (%v)\n", line, rec.Type, err)
+			return nil
+		}
+
+		// 再生前にギルドの状態をオフラインAPIに取り込む
+		switch e := event.(type) {
+		case *discordgo.Ready:
+			srv.BotUser = e.User
+		case *discordgo.GuildCreate:
+			srv.ImportGuild(e.Guild)
+		}
+
+		eventGuild := guildOf(event)
+		if *guildID != "" && eventGuild != *guildID {
+			return nil
+		}
+
+		fmt.Printf("#%d %s %s guild=%s\n", line, rec.Time.Format(time.RFC3339), rec.Type, eventGuild)
+		player.Play(event)
+		played++
+
+		requests := srv.Requests()
+		for _, req := range requests[seen:] {
+			fmt.Printf("    → %s %s\n", req.Method, req.Path)
+		}
+		seen = len(requests)
+
+		if *step {
+			fmt.Print("  [Enter: 次へ / q: 終了] ")
+			input, _ := stdin.ReadString('\n')
+			if strings.TrimSpace(input) == "q" {
+				return errQuit
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errQuit) {
+		return err
+	}
+
+	fmt.Printf("Replayed %d events.\n", played)
+	cache := dbService.SettingsCacheStats()
+	fmt.Printf("Guild settings cache: %d hits, %d misses\n", cache.Hits, cache.Misses)
+	printSentMessages(srv)
+	return nil
+}
+
+// guildOf はイベントのギルドIDを返します（GUILD_* イベントはギルド自体のIDを使用）
+func guildOf(event interface{}) string {
+	switch e := event.(type) {
+	case *discordgo.GuildCreate:
+		return e.ID
+	case *discordgo.GuildUpdate:
+		return e.ID
+	case *discordgo.GuildDelete:
+		return e.ID
+	}
+	return worker.GuildID(event)
+}
+
+// openScratchDatabase は元のデータベースのスナップショットを一時ファイルに作成して開きます
+func openScratchDatabase(source, scratch string) (*database.DB, error) {
+	if source != "" {
+		if _, err := os.Stat(source); err == nil {
+			src, err := sql.Open("sqlite", "file:"+source+"?mode=ro")
+			if err != nil {
+				return nil, err
+			}
+			// VACUUM INTO はWAL中のデータも含めた一貫したコピーを作成する
+			_, err = src.Exec("VACUUM INTO ?", scratch)
+			src.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to snapshot %s: %w", source, err)
+			}
+			fmt.Printf("Using a snapshot of %s\n", source)
+		}
+	}
+
+	return database.Connect(config.DatabaseConfig{Path: scratch, MaxConnections: 2})
+}
+
+// printSentMessages はボットがオフラインAPIに送信したメッセージを一覧表示します
+func printSentMessages(srv *discordtest.Server) {
+	for _, guild := range srv.Guilds() {
+		for _, channel := range srv.Channels(guild.ID) {
+			for _, message := range srv.Messages(channel.ID) {
+				if message.Author == nil || message.Author.ID != srv.BotUser.ID {
+					continue
+				}
+
+				summary := message.Content
+				for _, e := range message.Embeds {
+					summary = strings.TrimSpace(summary + " [" + e.Title + "]")
+				}
+				fmt.Printf("#%s: %s\n", channel.Name, summary)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Sumire-Labs/Luna/config"
+	"github.com/Sumire-Labs/Luna/database"
+	"github.com/Sumire-Labs/Luna/lifecycle"
+)
+
+const (
+	// firstRunDelay は起動直後の処理と重ならないよう、最初の実行を遅らせる時間です
+	firstRunDelay = time.Minute
+	// runInterval は保持期間を過ぎたデータを確認する間隔です
+	runInterval = 6 * time.Hour
+)
+
+// Task は保持期間を過ぎたデータを削除する処理です。now を基準に削除し、削除した件数を返します。
+type Task struct {
+	Name string
+	Run  func(now time.Time) (int64, error)
+}
+
+// Janitor は登録された Task を一定間隔で実行します
+type Janitor struct {
+	tasks     []Task
+	lifecycle *lifecycle.Manager
+	log       *slog.Logger
+
+	// done は定期実行の終了時に閉じられる（Start していない場合は nil）
+	done chan struct{}
+}
+
+// NewJanitor は config.toml の [retention] に従って、コマンド履歴・設定変更履歴・削除されたギルドのデータの削除を登録します
+func NewJanitor(cfg config.RetentionConfig, db *database.Service, lc *lifecycle.Manager, logger *slog.Logger) *Janitor {
+	j := &Janitor{lifecycle: lc, log: logger}
+
+	if cfg.CommandUsageDays > 0 {
+		j.Add(Task{Name: "command_usage", Run: func(now time.Time) (int64, error) {
+			return db.RollupCommandUsage(database.RetentionCutoff(now, cfg.CommandUsageDays))
+		}})
+	}
+	if cfg.ConfigAuditDays > 0 {
+		j.Add(Task{Name: "config_audit", Run: func(now time.Time) (int64, error) {
+			return db.PruneConfigAudit(database.RetentionCutoff(now, cfg.ConfigAuditDays))
+		}})
+	}
+	if cfg.RemovedGuildDays > 0 {
+		j.Add(Task{Name: "removed_guilds", Run: func(now time.Time) (int64, error) {
+			return db.PurgeRemovedGuilds(now.AddDate(0, 0, -cfg.RemovedGuildDays))
+		}})
+	}
+	return j
+}
+
+// Add は Task を登録します（Start の前に呼び出す）
+func (j *Janitor) Add(task Task) {
+	j.tasks = append(j.tasks, task)
+}
+
+// Start は定期実行を開始します
+func (j *Janitor) Start() {
+	j.done = make(chan struct{})
+	go j.run(j.done)
+}
+
+func (j *Janitor) run(done chan struct{}) {
+	defer close(done)
+
+	wait := firstRunDelay
+	for j.lifecycle.Sleep(wait) {
+		j.RunOnce(time.Now())
+		wait = runInterval
+	}
+}
+
+// RunOnce は登録された Task を順に実行します。失敗した Task があっても残りは実行します。
+func (j *Janitor) RunOnce(now time.Time) {
+	for _, task := range j.tasks {
+		start := time.Now()
+		removed, err := task.Run(now)
+		if err != nil {
+			j.log.Error("Retention task failed", "task", task.Name, "error", err)
+			continue
+		}
+		if removed > 0 {
+			j.log.Info("Expired data removed", "task", task.Name, "rows", removed, "latency", time.Since(start))
+		}
+	}
+}
+
+// Stop は実行中の Task の完了を待ちます（データベースを閉じる前に呼び出す）
+func (j *Janitor) Stop(ctx context.Context) error {
+	if j.done == nil {
+		return nil
+	}
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,201 @@
+package shard
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/config"
+)
+
+// Discordが許可するIDENTIFYの間隔（max_concurrency ごと）
+const identifyInterval = 5 * time.Second
+
+// Manager は1プロセス内で複数のシャードのセッションを管理します。
+// REST呼び出しには Primary() を使い、イベントハンドラーは AddHandler で全シャードに登録します。
+type Manager struct {
+	sessions       []*discordgo.Session
+	shardCount     int
+	maxConcurrency int
+	handlers       []interface{}
+}
+
+// NewManager は設定に従ってシャードごとのセッションを作成します。
+// shard_count が 0 の場合は /gateway/bot の推奨シャード数を使用します。
+func NewManager(cfg config.DiscordConfig, logger *slog.Logger) (*Manager, error) {
+	primary, err := discordgo.New("Bot " + cfg.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	shardCount := cfg.ShardCount
+	maxConcurrency := 1
+	if gateway, err := primary.GatewayBot(); err != nil {
+		if shardCount <= 0 {
+			logger.Warn("Failed to fetch recommended shard count, using 1 shard", "error", err)
+			shardCount = 1
+		}
+	} else {
+		if shardCount <= 0 {
+			shardCount = gateway.Shards
+		}
+		if gateway.SessionStartLimit.MaxConcurrency > 0 {
+			maxConcurrency = gateway.SessionStartLimit.MaxConcurrency
+		}
+	}
+	if shardCount <= 0 {
+		shardCount = 1
+	}
+
+	shardIDs := cfg.ShardIDs
+	if len(shardIDs) == 0 {
+		shardIDs = make([]int, shardCount)
+		for i := range shardIDs {
+			shardIDs[i] = i
+		}
+	}
+
+	m := &Manager{
+		shardCount:     shardCount,
+		maxConcurrency: maxConcurrency,
+	}
+
+	for i, id := range shardIDs {
+		if id < 0 || id >= shardCount {
+			return nil, fmt.Errorf("shard id %d is out of range (shard count %d)", id, shardCount)
+		}
+
+		session := primary
+		if i > 0 {
+			session, err = discordgo.New("Bot " + cfg.Token)
+			if err != nil {
+				return nil, err
+			}
+		}
+		session.ShardID = id
+		session.ShardCount = shardCount
+		// ハンドラーはワーカープールに投入するだけなので同期実行で十分
+		session.SyncEvents = true
+
+		m.sessions = append(m.sessions, session)
+	}
+
+	logger.Info("Managing shards", "shards", len(m.sessions), "shard_count", shardCount, "shard_ids", shardIDs)
+	return m, nil
+}
+
+// NewManagerFromSessions は作成済みのセッションから Manager を作成します（リプレイなどゲートウェイに接続しない用途向け）
+func NewManagerFromSessions(sessions ...*discordgo.Session) *Manager {
+	return &Manager{
+		sessions:       sessions,
+		shardCount:     len(sessions),
+		maxConcurrency: 1,
+	}
+}
+
+// Primary はREST呼び出しに使う代表セッションを返します
+func (m *Manager) Primary() *discordgo.Session {
+	return m.sessions[0]
+}
+
+func (m *Manager) Sessions() []*discordgo.Session {
+	return m.sessions
+}
+
+func (m *Manager) ShardCount() int {
+	return m.shardCount
+}
+
+// SetIntents は全シャードのIDENTIFYに使うインテントを設定します
+func (m *Manager) SetIntents(intents discordgo.Intent) {
+	for _, s := range m.sessions {
+		s.Identify.Intents = intents
+	}
+}
+
+// AddHandler はイベントハンドラーを全シャードに登録します
+func (m *Manager) AddHandler(handler interface{}) {
+	m.handlers = append(m.handlers, handler)
+	for _, s := range m.sessions {
+		s.AddHandler(handler)
+	}
+}
+
+// Dispatch はゲートウェイを経由せずに、イベントの型に一致する登録済みハンドラーを呼び出します。
+// 記録したイベントのリプレイに使用します。
+func (m *Manager) Dispatch(s *discordgo.Session, event interface{}) {
+	eventType := reflect.TypeOf(event)
+	args := []reflect.Value{reflect.ValueOf(s), reflect.ValueOf(event)}
+
+	for _, handler := range m.handlers {
+		h := reflect.ValueOf(handler)
+		if h.Kind() != reflect.Func || h.Type().NumIn() != 2 {
+			continue
+		}
+		if in := h.Type().In(1); in == eventType || (in.Kind() == reflect.Interface && eventType.Implements(in)) {
+			h.Call(args)
+		}
+	}
+}
+
+// Open は max_concurrency ごとに間隔を空けて全シャードに接続します
+func (m *Manager) Open() error {
+	for i, s := range m.sessions {
+		if i > 0 && i%m.maxConcurrency == 0 {
+			time.Sleep(identifyInterval)
+		}
+		if err := s.Open(); err != nil {
+			return fmt.Errorf("failed to open shard %d: %w", s.ShardID, err)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) Close() error {
+	var firstErr error
+	for _, s := range m.sessions {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close shard %d: %w", s.ShardID, err)
+		}
+	}
+	return firstErr
+}
+
+// ForGuild はギルドを担当するシャードのセッションを返します（このプロセスの担当外なら Primary）
+func (m *Manager) ForGuild(guildID string) *discordgo.Session {
+	id := ShardForGuild(guildID, m.shardCount)
+	for _, s := range m.sessions {
+		if s.ShardID == id {
+			return s
+		}
+	}
+	return m.Primary()
+}
+
+// GuildCount は全シャードのステートに載っているギルド数の合計を返します
+func (m *Manager) GuildCount() int {
+	count := 0
+	for _, s := range m.sessions {
+		if s.State != nil {
+			s.State.RLock()
+			count += len(s.State.Guilds)
+			s.State.RUnlock()
+		}
+	}
+	return count
+}
+
+// ShardForGuild はDiscordの規則 (guild_id >> 22) % shard_count でシャードIDを計算します
+func ShardForGuild(guildID string, shardCount int) int {
+	if shardCount <= 1 {
+		return 0
+	}
+	id, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return int((id >> 22) % uint64(shardCount))
+}
@@ -0,0 +1,445 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/Sumire-Labs/Luna/config"
+)
+
+var (
+	ErrPoolClosed = errors.New("worker pool is shut down")
+	ErrQueueFull  = errors.New("guild queue is full")
+)
+
+// Pool はコマンドとイベントハンドラーを同時実行数の上限付きで実行するワーカープールです。
+// タスクはギルドごとのキューに積まれ、ギルド間でラウンドロビンに取り出されるため、
+// 忙しいギルドが他のギルドの処理を止めることはありません。
+type Pool struct {
+	workers     int
+	queueSize   int
+	maxPerGuild int
+	timeout     time.Duration
+	interval    time.Duration
+	filter      func(guildID, name string) bool
+	log         *slog.Logger
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queues  map[string]*guildQueue
+	ring    []string
+	next    int
+	pending int
+	closed  bool
+	wg      sync.WaitGroup
+	stop    chan struct{}
+
+	running   atomic.Int64
+	submitted atomic.Int64
+	completed atomic.Int64
+	rejected  atomic.Int64
+	timedOut  atomic.Int64
+	panicked  atomic.Int64
+	skipped   atomic.Int64
+}
+
+type guildQueue struct {
+	tasks   []*task
+	running int
+}
+
+type task struct {
+	guildID  string
+	name     string
+	fn       func(ctx context.Context)
+	enqueued time.Time
+}
+
+// Stats はプールの現在の状態と累計値です
+type Stats struct {
+	Workers      int
+	Running      int64
+	Queued       int
+	QueuedGuilds map[string]int
+	Submitted    int64
+	Completed    int64
+	Rejected     int64
+	TimedOut     int64
+	Panicked     int64
+	Skipped      int64
+}
+
+func NewPool(cfg config.WorkerConfig, logger *slog.Logger) *Pool {
+	p := &Pool{
+		log:         logger,
+		workers:     cfg.Workers,
+		queueSize:   cfg.QueueSize,
+		maxPerGuild: cfg.MaxPerGuild,
+		timeout:     time.Duration(cfg.TaskTimeout) * time.Second,
+		interval:    time.Duration(cfg.StatsInterval) * time.Second,
+		queues:      make(map[string]*guildQueue),
+		stop:        make(chan struct{}),
+	}
+	if p.workers <= 0 {
+		p.workers = 1
+	}
+	if p.maxPerGuild <= 0 || p.maxPerGuild > p.workers {
+		p.maxPerGuild = p.workers
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// SetFilter はタスクの実行前に呼ばれる判定を設定します。false を返したタスクは実行せずに破棄されます。
+// Start より前に呼び出してください。
+func (p *Pool) SetFilter(filter func(guildID, name string) bool) {
+	p.filter = filter
+}
+
+// Start はワーカーと、キューの状態を定期的にログに出力する処理を起動します
+func (p *Pool) Start() {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	if p.interval > 0 {
+		go p.report()
+	}
+}
+
+// report は interval ごとにキューの長さと累計値をログに出力します（前回から変化がない場合は出力しない）
+func (p *Pool) report() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	var last Stats
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		stats := p.Stats()
+		if stats.Submitted == last.Submitted && stats.Running == 0 && stats.Queued == 0 {
+			continue
+		}
+
+		busiest, depth := "", 0
+		for guildID, n := range stats.QueuedGuilds {
+			if n > depth {
+				busiest, depth = guildID, n
+			}
+		}
+		p.log.Info("Worker pool stats",
+			"workers", stats.Workers,
+			"running", stats.Running,
+			"queued", stats.Queued,
+			"queued_guilds", len(stats.QueuedGuilds),
+			"busiest_guild_id", busiest,
+			"busiest_queue", depth,
+			"submitted", stats.Submitted-last.Submitted,
+			"completed", stats.Completed-last.Completed,
+			"rejected", stats.Rejected-last.Rejected,
+			"timed_out", stats.TimedOut-last.TimedOut,
+			"panicked", stats.Panicked-last.Panicked,
+		)
+		last = stats
+	}
+}
+
+// Submit はタスクをギルドのキューに追加します。キューが満杯の場合やシャットダウン後はエラーを返します。
+func (p *Pool) Submit(guildID, name string, fn func(ctx context.Context)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		p.rejected.Add(1)
+		return ErrPoolClosed
+	}
+
+	q, ok := p.queues[guildID]
+	if !ok {
+		q = &guildQueue{}
+		p.queues[guildID] = q
+		p.ring = append(p.ring, guildID)
+	}
+
+	if p.queueSize > 0 && len(q.tasks) >= p.queueSize {
+		p.rejected.Add(1)
+		return fmt.Errorf("%w: %s (%d tasks)", ErrQueueFull, guildID, len(q.tasks))
+	}
+
+	q.tasks = append(q.tasks, &task{
+		guildID:  guildID,
+		name:     name,
+		fn:       fn,
+		enqueued: time.Now(),
+	})
+	p.pending++
+	p.submitted.Add(1)
+	p.cond.Signal()
+
+	return nil
+}
+
+// Shutdown は新しいタスクの受付を停止し、キュー内と実行中のタスクが終わるまで待機します
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		close(p.stop)
+	}
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("worker pool did not drain: %d running, %d queued: %w", p.running.Load(), p.queued(), ctx.Err())
+	}
+}
+
+// Stats は現在のキュー長と累計の実行数を返します
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	queued := make(map[string]int, len(p.queues))
+	for guildID, q := range p.queues {
+		if len(q.tasks) > 0 {
+			queued[guildID] = len(q.tasks)
+		}
+	}
+	pending := p.pending
+	p.mu.Unlock()
+
+	return Stats{
+		Workers:      p.workers,
+		Running:      p.running.Load(),
+		Queued:       pending,
+		QueuedGuilds: queued,
+		Submitted:    p.submitted.Load(),
+		Completed:    p.completed.Load(),
+		Rejected:     p.rejected.Load(),
+		TimedOut:     p.timedOut.Load(),
+		Panicked:     p.panicked.Load(),
+		Skipped:      p.skipped.Load(),
+	}
+}
+
+func (p *Pool) queued() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pending
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+
+	for {
+		p.mu.Lock()
+		var t *task
+		for {
+			if t = p.dequeueLocked(); t != nil {
+				break
+			}
+			if p.closed && p.pending == 0 {
+				p.mu.Unlock()
+				return
+			}
+			p.cond.Wait()
+		}
+		p.mu.Unlock()
+
+		p.run(t)
+
+		p.mu.Lock()
+		if q, ok := p.queues[t.guildID]; ok {
+			q.running--
+			p.removeIfIdleLocked(t.guildID, q)
+		}
+		// ギルドの同時実行枠が空いたので待機中のワーカーを起こす
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}
+}
+
+// dequeueLocked は同時実行数の上限に達していないギルドからラウンドロビンでタスクを取り出します
+func (p *Pool) dequeueLocked() *task {
+	n := len(p.ring)
+	for i := 0; i < n; i++ {
+		idx := (p.next + i) % n
+		guildID := p.ring[idx]
+		q := p.queues[guildID]
+		if len(q.tasks) == 0 || q.running >= p.maxPerGuild {
+			continue
+		}
+
+		t := q.tasks[0]
+		q.tasks[0] = nil
+		q.tasks = q.tasks[1:]
+		q.running++
+		p.pending--
+		p.next = idx + 1
+		return t
+	}
+	return nil
+}
+
+func (p *Pool) removeIfIdleLocked(guildID string, q *guildQueue) {
+	if len(q.tasks) > 0 || q.running > 0 {
+		return
+	}
+	delete(p.queues, guildID)
+	for i, id := range p.ring {
+		if id == guildID {
+			p.ring = append(p.ring[:i], p.ring[i+1:]...)
+			if p.next > i {
+				p.next--
+			}
+			break
+		}
+	}
+}
+
+// run はタスクをタイムアウト付きで実行します。
+// タイムアウトするとタスクのコンテキストを取り消しますが、タスクが戻るまではワーカーとギルドの実行枠を保持します
+// （止まったタスクの横で同じギルドの次のイベントが実行され、順序が入れ替わらないように）。
+func (p *Pool) run(t *task) {
+	if p.filter != nil && !p.filter(t.guildID, t.name) {
+		p.skipped.Add(1)
+		return
+	}
+
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if p.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	p.running.Add(1)
+	defer p.running.Add(-1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				p.panicked.Add(1)
+				p.log.Error("Task panicked", "task", t.name, "guild_id", t.guildID, "panic", r, "stack", string(debug.Stack()))
+			}
+		}()
+		t.fn(ctx)
+	}()
+
+	select {
+	case <-done:
+		p.completed.Add(1)
+		return
+	case <-ctx.Done():
+	}
+
+	p.timedOut.Add(1)
+	p.log.Warn("Task timed out", "task", t.name, "guild_id", t.guildID, "timeout", p.timeout, "latency", time.Since(t.enqueued).Round(time.Millisecond))
+	<-done
+	p.log.Warn("Timed out task returned", "task", t.name, "guild_id", t.guildID, "latency", time.Since(t.enqueued).Round(time.Millisecond))
+}
+
+// Handler はdiscordgoのイベントハンドラーをプール上で実行するようにラップします。
+// イベントのGuildIDフィールドでキューが振り分けられます。
+func Handler[T any](p *Pool, name string, fn func(*discordgo.Session, T)) func(*discordgo.Session, T) {
+	return HandlerContext(p, name, func(_ context.Context, s *discordgo.Session, event T) {
+		fn(s, event)
+	})
+}
+
+// HandlerContext は Handler と同じですが、タイムアウト・シャットダウンで取り消されるタスクのコンテキストをハンドラーに渡します
+func HandlerContext[T any](p *Pool, name string, fn func(context.Context, *discordgo.Session, T)) func(*discordgo.Session, T) {
+	return func(s *discordgo.Session, event T) {
+		guildID := GuildID(event)
+		received := time.Now()
+
+		err := p.Submit(guildID, name, func(ctx context.Context) {
+			fn(ctx, s, event)
+			// latency はゲートウェイで受信してから処理が終わるまでの時間（キュー待ちを含む）
+			p.log.Debug("Event handled", "event", name, "guild_id", guildID, "user_id", UserID(event), "latency", time.Since(received))
+		})
+		if err != nil {
+			p.log.Warn("Dropped event", "event", name, "guild_id", guildID, "user_id", UserID(event), "error", err)
+		}
+	}
+}
+
+// GuildID はイベント構造体からGuildIDフィールドを取り出します（存在しない場合は空文字）
+func GuildID(event interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(event))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+
+	field, ok := v.Type().FieldByName("GuildID")
+	if !ok {
+		return ""
+	}
+
+	fv, err := v.FieldByIndexErr(field.Index)
+	if err != nil || fv.Kind() != reflect.String {
+		return ""
+	}
+	return fv.String()
+}
+
+// UserID はイベントを発生させたユーザーのIDを取り出します（UserID・Author・Member・User の順に参照）
+func UserID(event interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(event))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+
+	if id, ok := fieldByName(v, "UserID"); ok && id.Kind() == reflect.String {
+		return id.String()
+	}
+	for _, name := range []string{"Author", "User"} {
+		if user, ok := fieldByName(v, name); ok {
+			if u, ok := user.Interface().(*discordgo.User); ok && u != nil {
+				return u.ID
+			}
+		}
+	}
+	if member, ok := fieldByName(v, "Member"); ok {
+		if m, ok := member.Interface().(*discordgo.Member); ok && m != nil && m.User != nil {
+			return m.User.ID
+		}
+	}
+	return ""
+}
+
+// fieldByName は埋め込みポインターが nil の場合にパニックせずにフィールドを取り出します
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	field, ok := v.Type().FieldByName(name)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	fv, err := v.FieldByIndexErr(field.Index)
+	if err != nil {
+		return reflect.Value{}, false
+	}
+	return fv, true
+}
@@ -0,0 +1,159 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sumire-Labs/Luna/config"
+)
+
+func newTestPool(t *testing.T, cfg config.WorkerConfig) *Pool {
+	t.Helper()
+
+	p := NewPool(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		p.Shutdown(ctx)
+	})
+	return p
+}
+
+func TestPoolAlternatesBetweenGuilds(t *testing.T) {
+	p := newTestPool(t, config.WorkerConfig{Workers: 1})
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func(context.Context) {
+		return func(context.Context) {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		}
+	}
+
+	// 先に積まれたギルドのタスクがすべて終わるまで待たされないこと
+	for _, name := range []string{"a1", "a2", "a3"} {
+		if err := p.Submit("a", name, record(name)); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+	for _, name := range []string{"b1", "b2"} {
+		if err := p.Submit("b", name, record(name)); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+
+	p.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	want := []string{"a1", "b1", "a2", "b2", "a3"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestPoolRejectsWhenGuildQueueIsFull(t *testing.T) {
+	p := newTestPool(t, config.WorkerConfig{Workers: 1, QueueSize: 2})
+	noop := func(context.Context) {}
+
+	for i := 0; i < 2; i++ {
+		if err := p.Submit("a", "task", noop); err != nil {
+			t.Fatalf("submit %d: %v", i, err)
+		}
+	}
+	if err := p.Submit("a", "task", noop); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("third submit error = %v, want ErrQueueFull", err)
+	}
+	// キューはギルドごとなので他のギルドは受け付ける
+	if err := p.Submit("b", "task", noop); err != nil {
+		t.Errorf("submit to another guild: %v", err)
+	}
+	if stats := p.Stats(); stats.Rejected != 1 || stats.Queued != 3 {
+		t.Errorf("stats = %+v, want 1 rejected and 3 queued", stats)
+	}
+}
+
+func TestPoolTimedOutTaskKeepsGuildSlot(t *testing.T) {
+	p := newTestPool(t, config.WorkerConfig{Workers: 2, MaxPerGuild: 1, TaskTimeout: 1})
+	p.Start()
+
+	cancelled := make(chan struct{})
+	release := make(chan struct{})
+	// コンテキストの取り消し後もすぐには戻らないタスク
+	if err := p.Submit("a", "slow", func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+		<-release
+	}); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	started := make(chan struct{})
+	if err := p.Submit("a", "next", func(context.Context) { close(started) }); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task context was not cancelled")
+	}
+	select {
+	case <-started:
+		t.Fatal("next task of the same guild started before the timed out task returned")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if stats := p.Stats(); stats.TimedOut != 1 || stats.Running != 1 {
+		t.Errorf("stats = %+v, want 1 timed out task still running", stats)
+	}
+
+	close(release)
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("next task did not start after the timed out task returned")
+	}
+}
+
+func TestPoolShutdownDrainsQueue(t *testing.T) {
+	p := newTestPool(t, config.WorkerConfig{Workers: 2})
+
+	var mu sync.Mutex
+	ran := 0
+	for i := 0; i < 10; i++ {
+		guildID := []string{"a", "b"}[i%2]
+		if err := p.Submit(guildID, "task", func(context.Context) {
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			ran++
+			mu.Unlock()
+		}); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+
+	p.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if ran != 10 {
+		t.Errorf("ran %d tasks, want all 10 queued tasks", ran)
+	}
+	if err := p.Submit("a", "task", func(context.Context) {}); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("submit after shutdown error = %v, want ErrPoolClosed", err)
+	}
+}